@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const branchPageSize = 100
+
+// ListBranches returns all the branches in a repository.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	branches := []*scm.Reference{}
+	opts := scm.ListOptions{Page: 1, Size: branchPageSize}
+	for {
+		page, r, err := c.scmClient.Git.ListBranches(ctx, repo, opts)
+		if err := responseError(fmt.Sprintf("failed to list branches in repo %s", repo), r, err); err != nil {
+			return nil, err
+		}
+		branches = append(branches, page...)
+		if r.Page.Next == 0 {
+			return branches, nil
+		}
+		opts.Page = r.Page.Next
+	}
+}
+
+// DeleteBranch deletes a branch from a repository.
+//
+// This is supported for GitHub and GitLab, other drivers return an error
+// wrapping scm.ErrNotSupported.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) DeleteBranch(ctx context.Context, repo, branch string) error {
+	defer c.cache.invalidateRef(repo, branch)
+	msg := fmt.Sprintf("failed to delete branch %s in repo %s", branch, repo)
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		_, err := c.do(ctx, msg, "DELETE", fmt.Sprintf("repos/%s/git/refs/heads/%s", repo, branch), nil, nil)
+		return err
+	case scm.DriverGitlab:
+		_, err := c.do(ctx, msg, "DELETE", fmt.Sprintf("api/v4/projects/%s/repository/branches/%s",
+			encodeGitLabRepo(repo), url.PathEscape(branch)), nil, nil)
+		return err
+	}
+	return fmt.Errorf("deleting branches with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+}
+
+// GetCommit returns the commit identified by the ref.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	commit, r, err := c.scmClient.Git.FindCommit(ctx, repo, ref)
+	if err := responseError(fmt.Sprintf("failed to get commit %s in repo %s", ref, repo), r, err); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
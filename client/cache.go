@@ -0,0 +1,266 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const defaultCacheEntries = 1000
+
+// CacheOptions configures the caching of files and branch heads read with an
+// SCMClient.
+type CacheOptions struct {
+	// MaxEntries is the number of files and branch heads that are cached,
+	// the least recently used are evicted first, defaults to 1000.
+	MaxEntries int
+	// TTL is how long cached files and branch heads are returned without
+	// checking whether they changed. If it's zero, the upstream service is
+	// checked each time, but unchanged files are not downloaded again if the
+	// service supports conditional requests.
+	TTL time.Duration
+}
+
+// Cache is an option func for the SCMClient creation function.
+//
+// It configures the SCMClient to cache the files read with GetFile, and the
+// heads read with GetBranchHead.
+//
+// Files read at a commit SHA can't change, so they are returned from the cache
+// until they are evicted. Other entries are returned until the TTL expires,
+// after which they are revalidated with a conditional request using the ETag
+// of the cached response, where the upstream service supports it, GitHub
+// doesn't count these against the rate limit if nothing changed.
+//
+// Entries are invalidated when they are changed with the SCMClient, changes
+// made by other clients are only seen once the TTL expires.
+//
+// The HTTP client of the wrapped scm.Client is replaced, see New.
+func Cache(opts CacheOptions) ClientFunc {
+	return func(c *SCMClient) {
+		httpClient := &http.Client{}
+		if c.scmClient.Client != nil {
+			*httpClient = *c.scmClient.Client
+		}
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		httpClient.Transport = &conditionalTransport{base: base}
+		c.scmClient.Client = httpClient
+		c.cache = newResponseCache(opts)
+	}
+}
+
+// conditional is added to the context of a request to make it conditional on
+// the ETag of a cached response, and to capture the ETag of the response.
+//
+// Only the first request made with the context, and any retries of it, are
+// conditional, as some drivers make additional requests for metadata.
+type conditional struct {
+	mu       sync.Mutex
+	etag     string
+	url      string
+	received string
+}
+
+type conditionalKey struct{}
+
+// conditionalTransport sets the If-None-Match header of requests with a
+// conditional in their context.
+type conditionalTransport struct {
+	base http.RoundTripper
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cond, ok := req.Context().Value(conditionalKey{}).(*conditional)
+	if !ok || req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	cond.mu.Lock()
+	if cond.url == "" {
+		cond.url = req.URL.String()
+	}
+	first := cond.url == req.URL.String()
+	cond.mu.Unlock()
+	if !first {
+		return t.base.RoundTrip(req)
+	}
+
+	if cond.etag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cond.etag)
+	}
+	res, err := t.base.RoundTrip(req)
+	if err == nil {
+		cond.mu.Lock()
+		cond.received = res.Header.Get("ETag")
+		cond.mu.Unlock()
+	}
+	return res, err
+}
+
+func (cond *conditional) responseETag() string {
+	cond.mu.Lock()
+	defer cond.mu.Unlock()
+	return cond.received
+}
+
+func notModified(r *scm.Response) bool {
+	return r != nil && r.Status == http.StatusNotModified
+}
+
+// responseCache is a size bounded LRU cache of files and branch heads, the
+// methods do nothing if the cache is nil, so that caching is optional.
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	key       string
+	repo      string
+	ref       string
+	value     interface{}
+	etag      string
+	expires   time.Time
+	immutable bool
+}
+
+func newResponseCache(opts CacheOptions) *responseCache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultCacheEntries
+	}
+	return &responseCache{
+		maxEntries: opts.MaxEntries,
+		ttl:        opts.TTL,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// lookup returns the cached value and whether it can be used without
+// revalidating it, and a context for revalidating it otherwise.
+func (c *responseCache) lookup(ctx context.Context, key string) (interface{}, bool, context.Context, *conditional) {
+	if c == nil {
+		return nil, false, ctx, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cond := &conditional{}
+	ctx = context.WithValue(ctx, conditionalKey{}, cond)
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, ctx, cond
+	}
+	c.lru.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	if e.immutable || c.now().Before(e.expires) {
+		return e.value, true, ctx, cond
+	}
+	cond.etag = e.etag
+	return e.value, false, ctx, cond
+}
+
+// store adds or replaces the value in the cache, along with the ETag from the
+// response.
+func (c *responseCache) store(key, repo, ref string, value interface{}, cond *conditional, immutable bool) {
+	if c == nil {
+		return
+	}
+	etag := cond.responseETag()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{key: key, repo: repo, ref: ref, value: value, etag: etag, expires: c.now().Add(c.ttl), immutable: immutable}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// refresh restarts the TTL of an entry that was revalidated.
+func (c *responseCache) refresh(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).expires = c.now().Add(c.ttl)
+	}
+}
+
+// invalidate removes the entries with the keys.
+func (c *responseCache) invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateRef removes all the entries for the ref in the repo, or all the
+// entries for the repo that can change if the ref is empty.
+func (c *responseCache) invalidateRef(repo, ref string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if e := el.Value.(*cacheEntry); e.repo == repo && !e.immutable && (ref == "" || e.ref == ref) {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func fileCacheKey(repo, ref, path string) string {
+	return "file:" + repo + ":" + ref + ":" + path
+}
+
+func headCacheKey(repo, branch string) string {
+	return "head:" + repo + ":" + branch
+}
+
+// isCommitSHA returns true if the ref is a full SHA-1 or SHA-256 commit ID,
+// which always refers to the same content.
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, r := range ref {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func copyContent(c *scm.Content) *scm.Content {
+	cp := *c
+	cp.Data = append([]byte(nil), c.Data...)
+	return &cp
+}
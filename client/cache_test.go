@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/go-scm/scm/factory"
+)
+
+const testCommitSHA = "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"
+
+func TestCachedGetFile(t *testing.T) {
+	cacheTests := []struct {
+		name  string
+		opts  CacheOptions
+		ref   string
+		paths []string
+		want  []string
+	}{
+		{"revalidates with the ETag", CacheOptions{}, "master", []string{"a.yaml", "a.yaml"},
+			[]string{"GET a.yaml ref=master", `GET a.yaml ref=master If-None-Match="a.yaml"`}},
+		{"uses fresh entries", CacheOptions{TTL: time.Minute}, "master", []string{"a.yaml", "a.yaml"},
+			[]string{"GET a.yaml ref=master"}},
+		{"uses entries for commits", CacheOptions{}, testCommitSHA, []string{"a.yaml", "a.yaml"},
+			[]string{"GET a.yaml ref=" + testCommitSHA}},
+		{"evicts least recently used", CacheOptions{TTL: time.Minute, MaxEntries: 1}, "master", []string{"a.yaml", "b.yaml", "a.yaml"},
+			[]string{"GET a.yaml ref=master", "GET b.yaml ref=master", "GET a.yaml ref=master"}},
+	}
+
+	for _, tt := range cacheTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			s := newCacheTestServer(rt)
+			c := s.client(rt, Cache(tt.opts))
+
+			for _, path := range tt.paths {
+				content, err := c.GetFile(context.Background(), "Codertocat/Hello-World", tt.ref, path)
+				if err != nil {
+					rt.Fatal(err)
+				}
+				if s := string(content.Data); s != "body:\n  key:\n    env:\n      val: testing\n" {
+					rt.Fatalf("got content %q", s)
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, s.requests()); diff != "" {
+				rt.Fatalf("incorrect requests:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCachedGetBranchHead(t *testing.T) {
+	s := newCacheTestServer(t)
+	c := s.client(t, Cache(CacheOptions{TTL: time.Minute}))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		sha, err := c.GetBranchHead(ctx, "Codertocat/Hello-World", "master")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sha != testCommitSHA {
+			t.Fatalf("got sha %s", sha)
+		}
+	}
+	err := c.UpdateFile(ctx, "Codertocat/Hello-World", "master", "a.yaml", "updating", "", scm.Signature{}, []byte("testing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBranchHead(ctx, "Codertocat/Hello-World", "master"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"GET branch master", "PUT a.yaml", "GET branch master"}
+	if diff := cmp.Diff(want, s.requests()); diff != "" {
+		t.Fatalf("incorrect requests:\n%s", diff)
+	}
+}
+
+func TestGetFileWithoutCache(t *testing.T) {
+	s := newCacheTestServer(t)
+	c := s.client(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetFile(context.Background(), "Codertocat/Hello-World", testCommitSHA, "a.yaml"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(s.requests()); n != 2 {
+		t.Fatalf("got %d requests, want 2", n)
+	}
+}
+
+// cacheTestServer is a fake GitHub API that returns the same content for all
+// files, with the path as the ETag, and records the requests.
+type cacheTestServer struct {
+	*httptest.Server
+	mu  sync.Mutex
+	log []string
+}
+
+func newCacheTestServer(t *testing.T) *cacheTestServer {
+	s := &cacheTestServer{}
+	content, err := ioutil.ReadFile("testdata/content.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch, err := ioutil.ReadFile("testdata/github_get_branch.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentsPath := strings.SplitN(r.URL.Path, "/contents/", 2)
+		switch {
+		case r.Method == http.MethodPut && len(contentsPath) == 2:
+			s.record("PUT " + contentsPath[1])
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{}`))
+		case len(contentsPath) == 2:
+			path := contentsPath[1]
+			req := "GET " + path + " ref=" + r.URL.Query().Get("ref")
+			etag := `"` + path + `"`
+			if inm := r.Header.Get("If-None-Match"); inm != "" {
+				req += " If-None-Match=" + inm
+			}
+			s.record(req)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(content)
+		case strings.Contains(r.URL.Path, "/branches/"):
+			s.record("GET branch " + r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(branch)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *cacheTestServer) client(t *testing.T, opts ...ClientFunc) *SCMClient {
+	scmClient, err := factory.NewClient("github", s.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(scmClient, opts...)
+}
+
+func (s *cacheTestServer) record(req string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, req)
+}
+
+func (s *cacheTestServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.log...)
+}
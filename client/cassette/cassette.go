@@ -0,0 +1,158 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+// sentinels are the errors that are matched with errors.Is by callers, they
+// are recorded by name so that replayed errors still match them.
+var sentinels = map[string]error{
+	"not_supported":     scm.ErrNotSupported,
+	"not_authorized":    scm.ErrNotAuthorized,
+	"not_found":         scm.ErrNotFound,
+	"canceled":          context.Canceled,
+	"deadline_exceeded": context.DeadlineExceeded,
+}
+
+// args are the arguments of a call by name.
+type args map[string]interface{}
+
+// Cassette is a recording of the calls made to a client.GitClient.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded call, and its result or error.
+type Interaction struct {
+	Operation string                 `json:"operation"` // the name of the GitClient method, e.g. GetFile
+	Args      map[string]interface{} `json:"args"`      // the arguments by name, except for the context
+	Result    json.RawMessage        `json:"result,omitempty"`
+	Error     *Error                 `json:"error,omitempty"`
+}
+
+// Error is a recorded error, SCMErrors are recorded in full so that replayed
+// errors can be classified with client.IsNotFound etc.
+type Error struct {
+	Message  string           `json:"message"`
+	SCMError *client.SCMError `json:"scmError,omitempty"`
+	Sentinel string           `json:"sentinel,omitempty"` // the name of a wrapped sentinel error, e.g. not_supported
+}
+
+// Load reads a Cassette from a file written with Save.
+func Load(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the Cassette to a file.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// newInteraction returns an Interaction for a call, the arguments and result
+// are normalised to the form they have after a round trip through JSON, so
+// that they can be compared with the arguments of replayed calls.
+func newInteraction(operation string, a args, result interface{}, err error) (Interaction, error) {
+	i := Interaction{Operation: operation}
+	normalized, encodeErr := normalize(a)
+	if encodeErr != nil {
+		return i, fmt.Errorf("failed to encode arguments for %s: %w", operation, encodeErr)
+	}
+	i.Args = normalized
+	if result != nil {
+		if i.Result, encodeErr = json.Marshal(result); encodeErr != nil {
+			return i, fmt.Errorf("failed to encode result for %s: %w", operation, encodeErr)
+		}
+	}
+	if err != nil {
+		i.Error = recordError(err)
+	}
+	return i, nil
+}
+
+// matches returns true if the interaction is for the same operation, with the
+// same arguments.
+func (i Interaction) matches(operation string, a map[string]interface{}) bool {
+	return i.Operation == operation && reflect.DeepEqual(i.Args, a)
+}
+
+func normalize(a args) (map[string]interface{}, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func recordError(err error) *Error {
+	e := &Error{Message: err.Error()}
+	var scmErr client.SCMError
+	if errors.As(err, &scmErr) {
+		e.SCMError = &scmErr
+	}
+	for name, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			e.Sentinel = name
+		}
+	}
+	return e
+}
+
+// err returns an error with the recorded message, which wraps the recorded
+// SCMError or sentinel error.
+func (e *Error) err() error {
+	var cause error
+	switch {
+	case e.SCMError != nil:
+		cause = *e.SCMError
+	case e.Sentinel != "":
+		cause = sentinels[e.Sentinel]
+	}
+	if cause == nil {
+		return errors.New(e.Message)
+	}
+	if cause.Error() == e.Message {
+		return cause
+	}
+	return replayedError{msg: e.Message, cause: cause}
+}
+
+type replayedError struct {
+	msg   string
+	cause error
+}
+
+func (e replayedError) Error() string {
+	return e.msg
+}
+
+func (e replayedError) Unwrap() error {
+	return e.cause
+}
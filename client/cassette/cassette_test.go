@@ -0,0 +1,187 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/updater"
+)
+
+const (
+	testRepo = "testorg/testrepo"
+	testSHA  = "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	m := mock.New(t)
+	m.AddBranchHead(testRepo, "main", testSHA)
+	m.AddFileContents(testRepo, "README.md", "main", []byte("testing"))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	inp := &scm.PullRequestInput{Title: "testing", Source: "test-branch", Target: "main"}
+
+	calls := func(c client.GitClient) []interface{} {
+		ctx := context.Background()
+		content, err := c.GetFile(ctx, testRepo, "main", "README.md")
+		_, notFoundErr := c.GetFile(ctx, testRepo, "main", "unknown.md")
+		head, headErr := c.GetBranchHead(ctx, testRepo, "main")
+		branchErr := c.CreateBranch(ctx, testRepo, "test-branch", head)
+		pr, prErr := c.CreatePullRequest(ctx, testRepo, inp)
+		return []interface{}{content, err, client.IsNotFound(notFoundErr), notFoundErr.Error(), head, headErr, branchErr, pr, prErr}
+	}
+
+	r := NewRecorder(m)
+	want := calls(r)
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPlayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := calls(p)
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("replayed calls don't match:\n%s", diff)
+	}
+	if u := p.Unplayed(); len(u) != 0 {
+		t.Fatalf("got unplayed interactions %v", u)
+	}
+}
+
+func TestReplayWithUnrecordedCall(t *testing.T) {
+	m := mock.New(t)
+	m.AddBranchHead(testRepo, "main", testSHA)
+	r := NewRecorder(m)
+	if _, err := r.GetBranchHead(context.Background(), testRepo, "main"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.Cassette()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(c)
+
+	_, err = p.GetBranchHead(context.Background(), testRepo, "release")
+
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("got %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestReplayRepeatedCalls(t *testing.T) {
+	m := mock.New(t)
+	m.AddBranchHead(testRepo, "main", testSHA)
+	r := NewRecorder(m)
+	ctx := context.Background()
+	if _, err := r.GetBranchHead(ctx, testRepo, "main"); err != nil {
+		t.Fatal(err)
+	}
+	m.AddBranchHead(testRepo, "main", "updated-sha")
+	if _, err := r.GetBranchHead(ctx, testRepo, "main"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.Cassette()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(c)
+
+	var heads []string
+	for i := 0; i < 3; i++ {
+		head, err := p.GetBranchHead(ctx, testRepo, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		heads = append(heads, head)
+	}
+
+	if diff := cmp.Diff([]string{testSHA, "updated-sha", "updated-sha"}, heads); diff != "" {
+		t.Fatalf("incorrect heads:\n%s", diff)
+	}
+}
+
+func TestReplayedErrors(t *testing.T) {
+	errorTests := []struct {
+		name  string
+		err   error
+		match func(error) bool
+	}{
+		{"scm error", fmt.Errorf("failed to get file: %w", client.SCMError{Msg: "not found", Status: 404}), client.IsNotFound},
+		{"not supported", fmt.Errorf("failed to get file: %w", scm.ErrNotSupported), client.IsNotSupported},
+		{"canceled", context.Canceled, func(err error) bool { return errors.Is(err, context.Canceled) }},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			m := mock.New(rt)
+			m.GetFileErr = tt.err
+			r := NewRecorder(m)
+			_, _ = r.GetFile(context.Background(), testRepo, "main", "README.md")
+			c, err := r.Cassette()
+			if err != nil {
+				rt.Fatal(err)
+			}
+
+			_, err = NewPlayer(c).GetFile(context.Background(), testRepo, "main", "README.md")
+
+			if err.Error() != tt.err.Error() {
+				rt.Errorf("got error %q, want %q", err, tt.err)
+			}
+			if !tt.match(err) {
+				rt.Errorf("replayed error %#v does not match the recorded error", err)
+			}
+		})
+	}
+}
+
+func TestReplayWithUpdater(t *testing.T) {
+	m := mock.New(t)
+	m.AddBranchHead(testRepo, "main", testSHA)
+	m.AddFileContents(testRepo, "README.md", "main", []byte("testing"))
+	input := updater.CommitInput{
+		Repo:               testRepo,
+		Filename:           "README.md",
+		Branch:             "main",
+		BranchGenerateName: "test-branch-",
+		CommitMessage:      "updating",
+	}
+	update := func(c client.GitClient) (*updater.UpdateResult, error) {
+		u := updater.New(zap.New(), c, updater.NameGenerator(stubNameGenerator{"a"}))
+		return u.ApplyUpdate(context.Background(), input, updater.ReplaceContents([]byte("updated")))
+	}
+
+	r := NewRecorder(m)
+	want, err := update(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.Cassette()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := update(NewPlayer(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("replayed update doesn't match:\n%s", diff)
+	}
+}
+
+type stubNameGenerator struct {
+	name string
+}
+
+func (s stubNameGenerator) PrefixedName(p string) string {
+	return p + s.name
+}
@@ -0,0 +1,249 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+var _ client.GitClient = (*Player)(nil)
+
+// ErrNotRecorded is returned by a Player when there's no recorded interaction
+// for a call.
+var ErrNotRecorded = errors.New("no recorded interaction")
+
+// Player is a client.GitClient that replays the calls recorded in a Cassette,
+// without making any requests.
+//
+// Calls are matched with the recorded interactions by the operation and all
+// the arguments other than the context. Each interaction is replayed once, in
+// the order they were recorded, and once all the matching interactions have
+// been replayed, the last of them is replayed for any further calls.
+type Player struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed []bool
+}
+
+// NewPlayer creates and returns a new Player that replays the Cassette.
+func NewPlayer(c *Cassette) *Player {
+	return &Player{cassette: c, replayed: make([]bool, len(c.Interactions))}
+}
+
+// LoadPlayer creates and returns a new Player that replays the Cassette saved
+// in the file.
+func LoadPlayer(path string) (*Player, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(c), nil
+}
+
+// Unplayed returns the recorded interactions that have not been replayed.
+func (p *Player) Unplayed() []Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var unplayed []Interaction
+	for i, interaction := range p.cassette.Interactions {
+		if !p.replayed[i] {
+			unplayed = append(unplayed, interaction)
+		}
+	}
+	return unplayed
+}
+
+// GetFile implements the client.GitClient interface.
+func (p *Player) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	var content *scm.Content
+	err := p.replay("GetFile", args{"repo": repo, "ref": ref, "path": path}, &content)
+	return content, err
+}
+
+// UpdateFile implements the client.GitClient interface.
+func (p *Player) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	return p.replay("UpdateFile", fileArgs(repo, branch, path, message, previousSHA, signature, content), nil)
+}
+
+// DeleteFile implements the client.GitClient interface.
+func (p *Player) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	return p.replay("DeleteFile", fileArgs(repo, branch, path, message, previousSHA, signature, content), nil)
+}
+
+// MoveFile implements the client.GitClient interface.
+func (p *Player) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	return p.replay("MoveFile", moveArgs(repo, branch, path, newPath, message, previousSHA, signature, content), nil)
+}
+
+// ListFiles implements the client.GitClient interface.
+func (p *Player) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	var files []*client.FileInfo
+	err := p.replay("ListFiles", args{"repo": repo, "ref": ref, "dir": dir, "recursive": recursive}, &files)
+	return files, err
+}
+
+// CommitFiles implements the client.GitClient interface.
+func (p *Player) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	var sha string
+	err := p.replay("CommitFiles", args{"repo": repo, "branch": branch, "message": message, "signature": signature, "changes": changes}, &sha)
+	return sha, err
+}
+
+// CreatePullRequest implements the client.GitClient interface.
+func (p *Player) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	var pr *scm.PullRequest
+	err := p.replay("CreatePullRequest", args{"repo": repo, "input": inp}, &pr)
+	return pr, err
+}
+
+// CreatePullRequestWithOptions implements the client.GitClient interface.
+func (p *Player) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	var pr *scm.PullRequest
+	err := p.replay("CreatePullRequestWithOptions", args{"repo": repo, "input": inp, "options": opts}, &pr)
+	return pr, err
+}
+
+// FindPullRequest implements the client.GitClient interface.
+func (p *Player) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	var pr *scm.PullRequest
+	err := p.replay("FindPullRequest", args{"repo": repo, "number": number}, &pr)
+	return pr, err
+}
+
+// ListPullRequests implements the client.GitClient interface.
+func (p *Player) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	var prs []*scm.PullRequest
+	err := p.replay("ListPullRequests", args{"repo": repo, "options": opts}, &prs)
+	return prs, err
+}
+
+// UpdatePullRequest implements the client.GitClient interface.
+func (p *Player) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	var pr *scm.PullRequest
+	err := p.replay("UpdatePullRequest", args{"repo": repo, "number": number, "input": inp}, &pr)
+	return pr, err
+}
+
+// MergePullRequest implements the client.GitClient interface.
+func (p *Player) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	return p.replay("MergePullRequest", args{"repo": repo, "number": number, "options": opts}, nil)
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (p *Player) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	var statuses []*scm.Status
+	err := p.replay("ListStatuses", args{"repo": repo, "ref": ref}, &statuses)
+	return statuses, err
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (p *Player) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	var status *client.CombinedStatus
+	err := p.replay("GetCombinedStatus", args{"repo": repo, "ref": ref}, &status)
+	return status, err
+}
+
+// CreateBranch implements the client.GitClient interface.
+func (p *Player) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	return p.replay("CreateBranch", args{"repo": repo, "branch": branch, "sha": sha}, nil)
+}
+
+// GetBranchHead implements the client.GitClient interface.
+func (p *Player) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	var sha string
+	err := p.replay("GetBranchHead", args{"repo": repo, "branch": branch}, &sha)
+	return sha, err
+}
+
+// ListBranches implements the client.GitClient interface.
+func (p *Player) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	var branches []*scm.Reference
+	err := p.replay("ListBranches", args{"repo": repo}, &branches)
+	return branches, err
+}
+
+// DeleteBranch implements the client.GitClient interface.
+func (p *Player) DeleteBranch(ctx context.Context, repo, branch string) error {
+	return p.replay("DeleteBranch", args{"repo": repo, "branch": branch}, nil)
+}
+
+// CreateFork implements the client.GitClient interface.
+func (p *Player) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	var fork string
+	err := p.replay("CreateFork", args{"repo": repo, "owner": owner}, &fork)
+	return fork, err
+}
+
+// SyncFork implements the client.GitClient interface.
+func (p *Player) SyncFork(ctx context.Context, fork, branch string) error {
+	return p.replay("SyncFork", args{"fork": fork, "branch": branch}, nil)
+}
+
+// GetCommit implements the client.GitClient interface.
+func (p *Player) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	var commit *scm.Commit
+	err := p.replay("GetCommit", args{"repo": repo, "ref": ref}, &commit)
+	return commit, err
+}
+
+// replay finds the interaction for the call, decodes its result into result,
+// which must be a pointer, and returns its error.
+func (p *Player) replay(operation string, a args, result interface{}) error {
+	normalized, err := normalize(a)
+	if err != nil {
+		return fmt.Errorf("failed to encode arguments for %s: %w", operation, err)
+	}
+
+	p.mu.Lock()
+	found := -1
+	for i, interaction := range p.cassette.Interactions {
+		if !interaction.matches(operation, normalized) {
+			continue
+		}
+		found = i
+		if !p.replayed[i] {
+			break
+		}
+	}
+	if found < 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("%w for %s with arguments %v", ErrNotRecorded, operation, normalized)
+	}
+	p.replayed[found] = true
+	interaction := p.cassette.Interactions[found]
+	p.mu.Unlock()
+
+	if result != nil && len(interaction.Result) > 0 {
+		if err := json.Unmarshal(interaction.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result for %s: %w", operation, err)
+		}
+	}
+	if interaction.Error != nil {
+		return interaction.Error.err()
+	}
+	return nil
+}
+
+func fileArgs(repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) args {
+	return args{
+		"repo":        repo,
+		"branch":      branch,
+		"path":        path,
+		"message":     message,
+		"previousSHA": previousSHA,
+		"signature":   signature,
+		"content":     content,
+	}
+}
+
+func moveArgs(repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) args {
+	a := fileArgs(repo, branch, path, message, previousSHA, signature, content)
+	a["newPath"] = newPath
+	return a
+}
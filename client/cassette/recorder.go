@@ -0,0 +1,210 @@
+package cassette
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+var _ client.GitClient = (*Recorder)(nil)
+
+// Recorder is a client.GitClient that records each call made with the wrapped
+// GitClient, and its result or error, so that they can be replayed with a
+// Player.
+type Recorder struct {
+	gitClient client.GitClient
+
+	mu       sync.Mutex
+	cassette Cassette
+	err      error
+}
+
+// NewRecorder creates and returns a new Recorder that records the calls made
+// with the GitClient.
+func NewRecorder(c client.GitClient) *Recorder {
+	return &Recorder{gitClient: c}
+}
+
+// Cassette returns the calls that were recorded so far, or the first error
+// encountered when encoding the calls.
+func (r *Recorder) Cassette() (*Cassette, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}, nil
+}
+
+// Save writes the calls that were recorded so far to a file.
+func (r *Recorder) Save(path string) error {
+	c, err := r.Cassette()
+	if err != nil {
+		return err
+	}
+	return c.Save(path)
+}
+
+// GetFile implements the client.GitClient interface.
+func (r *Recorder) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	content, err := r.gitClient.GetFile(ctx, repo, ref, path)
+	r.record("GetFile", args{"repo": repo, "ref": ref, "path": path}, content, err)
+	return content, err
+}
+
+// UpdateFile implements the client.GitClient interface.
+func (r *Recorder) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	err := r.gitClient.UpdateFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+	r.record("UpdateFile", fileArgs(repo, branch, path, message, previousSHA, signature, content), nil, err)
+	return err
+}
+
+// DeleteFile implements the client.GitClient interface.
+func (r *Recorder) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	err := r.gitClient.DeleteFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+	r.record("DeleteFile", fileArgs(repo, branch, path, message, previousSHA, signature, content), nil, err)
+	return err
+}
+
+// MoveFile implements the client.GitClient interface.
+func (r *Recorder) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	err := r.gitClient.MoveFile(ctx, repo, branch, path, newPath, message, previousSHA, signature, content)
+	r.record("MoveFile", moveArgs(repo, branch, path, newPath, message, previousSHA, signature, content), nil, err)
+	return err
+}
+
+// ListFiles implements the client.GitClient interface.
+func (r *Recorder) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	files, err := r.gitClient.ListFiles(ctx, repo, ref, dir, recursive)
+	r.record("ListFiles", args{"repo": repo, "ref": ref, "dir": dir, "recursive": recursive}, files, err)
+	return files, err
+}
+
+// CommitFiles implements the client.GitClient interface.
+func (r *Recorder) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	sha, err := r.gitClient.CommitFiles(ctx, repo, branch, message, signature, changes)
+	r.record("CommitFiles", args{"repo": repo, "branch": branch, "message": message, "signature": signature, "changes": changes}, sha, err)
+	return sha, err
+}
+
+// CreatePullRequest implements the client.GitClient interface.
+func (r *Recorder) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	pr, err := r.gitClient.CreatePullRequest(ctx, repo, inp)
+	r.record("CreatePullRequest", args{"repo": repo, "input": inp}, pr, err)
+	return pr, err
+}
+
+// CreatePullRequestWithOptions implements the client.GitClient interface.
+func (r *Recorder) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	pr, err := r.gitClient.CreatePullRequestWithOptions(ctx, repo, inp, opts)
+	r.record("CreatePullRequestWithOptions", args{"repo": repo, "input": inp, "options": opts}, pr, err)
+	return pr, err
+}
+
+// FindPullRequest implements the client.GitClient interface.
+func (r *Recorder) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	pr, err := r.gitClient.FindPullRequest(ctx, repo, number)
+	r.record("FindPullRequest", args{"repo": repo, "number": number}, pr, err)
+	return pr, err
+}
+
+// ListPullRequests implements the client.GitClient interface.
+func (r *Recorder) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	prs, err := r.gitClient.ListPullRequests(ctx, repo, opts)
+	r.record("ListPullRequests", args{"repo": repo, "options": opts}, prs, err)
+	return prs, err
+}
+
+// UpdatePullRequest implements the client.GitClient interface.
+func (r *Recorder) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	pr, err := r.gitClient.UpdatePullRequest(ctx, repo, number, inp)
+	r.record("UpdatePullRequest", args{"repo": repo, "number": number, "input": inp}, pr, err)
+	return pr, err
+}
+
+// MergePullRequest implements the client.GitClient interface.
+func (r *Recorder) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	err := r.gitClient.MergePullRequest(ctx, repo, number, opts)
+	r.record("MergePullRequest", args{"repo": repo, "number": number, "options": opts}, nil, err)
+	return err
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (r *Recorder) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	statuses, err := r.gitClient.ListStatuses(ctx, repo, ref)
+	r.record("ListStatuses", args{"repo": repo, "ref": ref}, statuses, err)
+	return statuses, err
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (r *Recorder) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	status, err := r.gitClient.GetCombinedStatus(ctx, repo, ref)
+	r.record("GetCombinedStatus", args{"repo": repo, "ref": ref}, status, err)
+	return status, err
+}
+
+// CreateBranch implements the client.GitClient interface.
+func (r *Recorder) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	err := r.gitClient.CreateBranch(ctx, repo, branch, sha)
+	r.record("CreateBranch", args{"repo": repo, "branch": branch, "sha": sha}, nil, err)
+	return err
+}
+
+// GetBranchHead implements the client.GitClient interface.
+func (r *Recorder) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	sha, err := r.gitClient.GetBranchHead(ctx, repo, branch)
+	r.record("GetBranchHead", args{"repo": repo, "branch": branch}, sha, err)
+	return sha, err
+}
+
+// ListBranches implements the client.GitClient interface.
+func (r *Recorder) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	branches, err := r.gitClient.ListBranches(ctx, repo)
+	r.record("ListBranches", args{"repo": repo}, branches, err)
+	return branches, err
+}
+
+// DeleteBranch implements the client.GitClient interface.
+func (r *Recorder) DeleteBranch(ctx context.Context, repo, branch string) error {
+	err := r.gitClient.DeleteBranch(ctx, repo, branch)
+	r.record("DeleteBranch", args{"repo": repo, "branch": branch}, nil, err)
+	return err
+}
+
+// CreateFork implements the client.GitClient interface.
+func (r *Recorder) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	fork, err := r.gitClient.CreateFork(ctx, repo, owner)
+	r.record("CreateFork", args{"repo": repo, "owner": owner}, fork, err)
+	return fork, err
+}
+
+// SyncFork implements the client.GitClient interface.
+func (r *Recorder) SyncFork(ctx context.Context, fork, branch string) error {
+	err := r.gitClient.SyncFork(ctx, fork, branch)
+	r.record("SyncFork", args{"fork": fork, "branch": branch}, nil, err)
+	return err
+}
+
+// GetCommit implements the client.GitClient interface.
+func (r *Recorder) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	commit, err := r.gitClient.GetCommit(ctx, repo, ref)
+	r.record("GetCommit", args{"repo": repo, "ref": ref}, commit, err)
+	return commit, err
+}
+
+func (r *Recorder) record(operation string, a args, result interface{}, err error) {
+	i, encodeErr := newInteraction(operation, a, result, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if encodeErr != nil {
+		if r.err == nil {
+			r.err = encodeErr
+		}
+		return
+	}
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+}
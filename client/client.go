@@ -3,45 +3,73 @@ package client
 import (
 	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/ocraviotto/go-scm/scm"
 )
 
 // New creates and returns a new SCMClient.
-func New(c *scm.Client) *SCMClient {
-	return &SCMClient{scmClient: c}
+//
+// The scm.Client belongs to the SCMClient from then on, options such as Cache
+// and Retries replace its HTTP client in place, as the go-scm services refer
+// to the scm.Client, not a copy. It should not be used directly, or passed to
+// New again, which would add the transports again.
+func New(c *scm.Client, opts ...ClientFunc) *SCMClient {
+	sc := &SCMClient{scmClient: c}
+	for _, o := range opts {
+		o(sc)
+	}
+	return sc
 }
 
 // SCMClient is a wrapper for the go-scm scm.Client with a simplified API.
 type SCMClient struct {
-	scmClient *scm.Client
+	scmClient   *scm.Client
+	cache       *responseCache
+	signer      CommitSigner
+	maxFileSize int
 }
 
 // GetFile reads the specific revision of a file from a repository.
 //
+// The content of large files that is not returned by the contents API is
+// fetched with the Git data API for GitHub, other drivers return an error
+// wrapping ErrFileTooLarge, as do files larger than the MaxFileSize.
+//
 // If an HTTP error is returned by the upstream service, an error with the
 // response status code is returned.
 func (c *SCMClient) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	key := fileCacheKey(repo, ref, path)
+	cached, fresh, ctx, cond := c.cache.lookup(ctx, key)
+	if fresh {
+		return copyContent(cached.(*scm.Content)), nil
+	}
 	content, r, err := c.scmClient.Contents.Find(ctx, repo, path, ref)
-	if r != nil && isErrorStatus(r.Status) {
-		e := newSCMError(fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref), r.Status, r.Body)
-		if r.Status == http.StatusNotFound {
-			return content, e
+	if cached != nil && notModified(r) {
+		c.cache.refresh(key)
+		return copyContent(cached.(*scm.Content)), nil
+	}
+	if err := responseError(fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref), r, err); err != nil {
+		if IsNotFound(err) {
+			return content, err
 		}
-		return nil, e
+		return nil, err
 	}
-	if err != nil {
+	if err := c.completeContent(ctx, repo, ref, path, content); err != nil {
 		return nil, err
 	}
+	c.cache.store(key, repo, ref, copyContent(content), cond, isCommitSHA(ref))
 	return content, nil
 }
 
 // CreateBranch will create a new branch in the repo from the SHA.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
 func (c *SCMClient) CreateBranch(ctx context.Context, repo, branch, sha string) error {
 	params := &scm.CreateBranch{Name: branch, Sha: sha}
-	_, err := c.scmClient.Git.CreateBranch(ctx, repo, params)
-	return err
+	r, err := c.scmClient.Git.CreateBranch(ctx, repo, params)
+	c.cache.invalidate(headCacheKey(repo, branch))
+	return responseError(fmt.Sprintf("failed to create branch %s in repo %s", branch, repo), r, err)
 }
 
 // CreatePullRequest creates a PullRequest with the provided input.
@@ -49,15 +77,27 @@ func (c *SCMClient) CreateBranch(ctx context.Context, repo, branch, sha string)
 // If an HTTP error is returned by the upstream service, an error with the
 // response status code is returned.
 func (c *SCMClient) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
-	pr, _, err := c.scmClient.PullRequests.Create(ctx, repo, inp)
-	return pr, err
+	pr, r, err := c.scmClient.PullRequests.Create(ctx, repo, inp)
+	if err := responseError(fmt.Sprintf("failed to create pull request in repo %s", repo), r, err); err != nil {
+		return nil, err
+	}
+	return pr, nil
 }
 
 // UpdateFile updates an existing file in a repository.
 //
+// If commits are signed, the file is updated with CommitFiles, see
+// SignCommits.
+//
 // If an HTTP error is returned by the upstream service, an error with the
 // response status code is returned.
 func (c *SCMClient) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	if c.signer != nil {
+		_, err := c.CommitFiles(ctx, repo, branch, message, signature, []FileChange{
+			{Action: FileUpdate, Path: path, Content: content, PreviousSHA: previousSHA},
+		})
+		return err
+	}
 	params := scm.ContentParams{
 		Message:   message,
 		Data:      content,
@@ -67,20 +107,24 @@ func (c *SCMClient) UpdateFile(ctx context.Context, repo, branch, path, message,
 		Signature: signature,
 	}
 	r, err := c.scmClient.Contents.Update(ctx, repo, path, &params)
-	if r != nil && isErrorStatus(r.Status) {
-		return newSCMError(fmt.Sprintf("failed to update file %s in repo %s branch %s", path, repo, branch), r.Status, r.Body)
-	}
-	if err != nil {
-		return err
-	}
-	return nil
+	c.cache.invalidate(fileCacheKey(repo, branch, path), headCacheKey(repo, branch))
+	return responseError(fmt.Sprintf("failed to update file %s in repo %s branch %s", path, repo, branch), r, err)
 }
 
 // DeleteFile deletes a file in a repository
 //
+// If commits are signed, the file is deleted with CommitFiles, see
+// SignCommits.
+//
 // If an HTTP error is returned by the upstream service, an error with the
 // response status code is returned.
 func (c *SCMClient) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	if c.signer != nil {
+		_, err := c.CommitFiles(ctx, repo, branch, message, signature, []FileChange{
+			{Action: FileDelete, Path: path, PreviousSHA: previousSHA},
+		})
+		return err
+	}
 	params := scm.ContentParams{
 		Message:   message,
 		Data:      content,
@@ -90,13 +134,22 @@ func (c *SCMClient) DeleteFile(ctx context.Context, repo, branch, path, message,
 		Signature: signature,
 	}
 	r, err := c.scmClient.Contents.Delete(ctx, repo, path, &params)
-	if r != nil && isErrorStatus(r.Status) {
-		return newSCMError(fmt.Sprintf("failed to delete file %s in repo %s branch %s", path, repo, branch), r.Status, r.Body)
-	}
-	if err != nil {
-		return err
-	}
-	return nil
+	c.cache.invalidate(fileCacheKey(repo, branch, path), headCacheKey(repo, branch))
+	return responseError(fmt.Sprintf("failed to delete file %s in repo %s branch %s", path, repo, branch), r, err)
+}
+
+// MoveFile moves an existing file in a repository to the newPath, in a single
+// commit, and replaces its content unless the content is nil.
+//
+// This is supported for GitHub and GitLab, see CommitFiles.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []FileChange{
+		{Action: FileMove, Path: newPath, PreviousPath: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
 }
 
 // GetBranchHead gets the head SHA for a specific branch.
@@ -104,8 +157,21 @@ func (c *SCMClient) DeleteFile(ctx context.Context, repo, branch, path, message,
 // If an HTTP error is returned by the upstream service, an error with the
 // response status code is returned.
 func (c *SCMClient) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
-	ref, _, err := c.scmClient.Git.FindBranch(ctx, repo, branch)
-	return ref.Sha, err
+	key := headCacheKey(repo, branch)
+	cached, fresh, ctx, cond := c.cache.lookup(ctx, key)
+	if fresh {
+		return cached.(string), nil
+	}
+	ref, r, err := c.scmClient.Git.FindBranch(ctx, repo, branch)
+	if cached != nil && notModified(r) {
+		c.cache.refresh(key)
+		return cached.(string), nil
+	}
+	if err := responseError(fmt.Sprintf("failed to get branch %s in repo %s", branch, repo), r, err); err != nil {
+		return "", err
+	}
+	c.cache.store(key, repo, branch, ref.Sha, cond, false)
+	return ref.Sha, nil
 }
 
 func isErrorStatus(i int) bool {
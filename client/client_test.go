@@ -1,9 +1,12 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -288,6 +291,30 @@ func TestCreateBranchInGitLab(t *testing.T) {
 	}
 }
 
+func TestCreateBranchThatExists(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/refs").
+		Reply(http.StatusUnprocessableEntity).
+		Type("application/json").
+		BodyString(`{"message":"Reference already exists"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.CreateBranch(context.Background(), "Codertocat/Hello-World", "new-feature", "aa218f56b14c9653891f9e74264a383fa43fefbd")
+	if !IsValidation(err) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	var e SCMError
+	if !errors.As(err, &e) || e.Message != "Reference already exists" {
+		t.Fatalf("incorrect error message: %#v", err)
+	}
+}
+
 func TestCreatePullRequest(t *testing.T) {
 	title := "Amazing new feature"
 	body := "Please pull these awesome changes in!"
@@ -352,24 +379,1082 @@ func TestGetBranchHead(t *testing.T) {
 
 }
 
-func mustParseJSONAsContent(t *testing.T, filename string) *scm.Content {
-	t.Helper()
-	body, err := ioutil.ReadFile(filename)
+func TestGetBranchHeadWithMissingBranch(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/unknown").
+		Reply(http.StatusNotFound).
+		Type("application/json").
+		BodyString(`{"message":"Branch not found"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	data := map[string]interface{}{}
-	err = json.Unmarshal(body, &data)
+	client := New(scmClient)
+
+	_, err = client.GetBranchHead(context.Background(), "Codertocat/Hello-World", "unknown")
+	if !IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestRequestWithFieldErrors(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		Reply(http.StatusUnprocessableEntity).
+		Type("application/json").
+		BodyString(`{"message":"Validation Failed","errors":[{"resource":"Tree","field":"tree","code":"invalid"}]}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	content, err := base64.StdEncoding.DecodeString(data["content"].(string))
+	client := New(scmClient)
+
+	_, err = client.do(context.Background(), "failed to create tree", "POST", "repos/Codertocat/Hello-World/git/trees", map[string]string{}, nil)
+	var e SCMError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want an SCMError", err)
+	}
+	want := []FieldError{{Resource: "Tree", Field: "tree", Code: "invalid"}}
+	if e.Message != "Validation Failed" || !cmp.Equal(want, e.FieldErrors) {
+		t.Fatalf("incorrect error: %#v", e)
+	}
+}
+
+func TestCommitFilesInGitHub(t *testing.T) {
+	message := "just a test message"
+	signature := scm.Signature{
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+	}
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	mockGitHubTrees("100755")
+	blobSHA := mockGitHubBlob([]byte("testing"))
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"base_tree": "691272480426f78a0138979dd3ce63b77f706feb",
+			"tree": []map[string]interface{}{
+				{"path": "config/my/file.yaml", "mode": "100755", "type": "blob", "sha": blobSHA},
+				{"path": "config/my/old.yaml", "mode": "100644", "type": "blob", "sha": nil},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"message":   message,
+			"tree":      "cd8274d15fa3ae2ab983129fb037999f264ba9a7",
+			"parents":   []string{"7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"},
+			"author":    map[string]string{"name": signature.Name, "email": signature.Email},
+			"committer": map[string]string{"name": signature.Name, "email": signature.Email},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_commit.json")
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/git/refs/heads/master").
+		MatchType("json").
+		JSON(map[string]interface{}{"sha": "7638417db6d59f3c431d3e1f261cc637155684cd", "force": false}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/single_ref.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	return &scm.Content{
-		Path:   data["path"].(string),
-		BlobID: data["sha"].(string),
-		Data:   content,
+	client := New(scmClient)
+
+	sha, err := client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", message, signature, []FileChange{
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing")},
+		{Action: FileDelete, Path: "config/my/old.yaml"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+	if sha != "7638417db6d59f3c431d3e1f261cc637155684cd" {
+		t.Fatalf("got a different sha back: %s", sha)
+	}
+}
+
+func TestCommitFilesInGitLab(t *testing.T) {
+	message := "just a test message"
+	signature := scm.Signature{
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+	}
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/Codertocat/Hello-World/repository/commits").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"branch":         "my-test-branch",
+			"commit_message": message,
+			"author_name":    signature.Name,
+			"author_email":   signature.Email,
+			"actions": []map[string]string{
+				{"action": "create", "file_path": "config/my/new.yaml", "content": "dGVzdGluZw==", "encoding": "base64"},
+				{"action": "update", "file_path": "config/my/file.yaml", "content": "dGVzdGluZw==", "encoding": "base64", "last_commit_id": "ae1d9fb46aa2b07ee9836d49862ec4e2c46fbbba"},
+				{"action": "delete", "file_path": "config/my/old.yaml"},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/gitlab_create_commit.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	sha, err := client.CommitFiles(context.Background(), "Codertocat/Hello-World", "my-test-branch", message, signature, []FileChange{
+		{Action: FileCreate, Path: "config/my/new.yaml", Content: []byte("testing")},
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing"), PreviousSHA: "ae1d9fb46aa2b07ee9836d49862ec4e2c46fbbba"},
+		{Action: FileDelete, Path: "config/my/old.yaml"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+	if sha != "ed899a2f4b50b4370feeea94676502b42383c746" {
+		t.Fatalf("got a different sha back: %s", sha)
+	}
+}
+
+func TestMoveFileInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	mockGitHubTrees("100755")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"base_tree": "691272480426f78a0138979dd3ce63b77f706feb",
+			"tree": []map[string]interface{}{
+				{"path": "config/new/file.yaml", "mode": "100755", "type": "blob", "sha": "980a0d5f19a64b4b30a87d4206aade58726b60e3"},
+				{"path": "config/my/file.yaml", "mode": "100755", "type": "blob", "sha": nil},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_commit.json")
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/git/refs/heads/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/single_ref.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MoveFile(context.Background(), "Codertocat/Hello-World", "master", "config/my/file.yaml", "config/new/file.yaml", "moving", "", scm.Signature{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+}
+
+func TestMoveFileInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/Codertocat/Hello-World/repository/commits").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"branch":         "my-test-branch",
+			"commit_message": "moving",
+			"actions": []map[string]string{
+				{"action": "move", "file_path": "config/new.yaml", "previous_path": "config/old.yaml"},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/gitlab_create_commit.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MoveFile(context.Background(), "Codertocat/Hello-World", "my-test-branch", "config/old.yaml", "config/new.yaml", "moving", "", scm.Signature{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+}
+
+func TestCommitFilesWithUnsupportedDriver(t *testing.T) {
+	scmClient, err := factory.NewClient("bitbucket", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "test", scm.Signature{}, []FileChange{
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing")},
+	})
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+	}
+}
+
+func TestCommitFilesWithModeInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	scriptSHA := mockGitHubBlob([]byte("#!/bin/sh"))
+	fileSHA := mockGitHubBlob([]byte("testing"))
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"base_tree": "691272480426f78a0138979dd3ce63b77f706feb",
+			"tree": []map[string]interface{}{
+				{"path": "scripts/run.sh", "mode": "100755", "type": "blob", "sha": scriptSHA},
+				{"path": "config/my/file.yaml", "mode": "100644", "type": "blob", "sha": fileSHA},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_commit.json")
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/git/refs/heads/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/single_ref.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "test", scm.Signature{}, []FileChange{
+		{Action: FileCreate, Path: "scripts/run.sh", Content: []byte("#!/bin/sh"), Mode: ModeExecutable},
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing"), Mode: ModeRegular},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+}
+
+func TestCommitFilesWithBinaryContentInGitHub(t *testing.T) {
+	content := []byte{0xff, 0xfe, 0x00, 0x80, 'g', 'i', 't'}
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	blobSHA := mockGitHubBlob(content)
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"base_tree": "691272480426f78a0138979dd3ce63b77f706feb",
+			"tree": []map[string]interface{}{
+				{"path": "images/logo.png", "mode": "100644", "type": "blob", "sha": blobSHA},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_commit.json")
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/git/refs/heads/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/single_ref.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/blobs/" + blobSHA).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(fmt.Sprintf(`{"sha": %q, "size": %d, "encoding": "base64", "content": %q}`, blobSHA, len(content), base64.StdEncoding.EncodeToString(content)))
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "test", scm.Signature{}, []FileChange{
+		{Action: FileCreate, Path: "images/logo.png", Content: content},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &scm.Content{Path: "images/logo.png", BlobID: blobSHA}
+	if err := client.completeContent(context.Background(), "Codertocat/Hello-World", "master", file.Path, file); err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+	if !bytes.Equal(file.Data, content) {
+		t.Fatalf("got content %q, want %q", file.Data, content)
+	}
+}
+
+func TestCommitFilesWithModeInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/Codertocat/Hello-World/repository/commits").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"branch":         "my-test-branch",
+			"commit_message": "test",
+			"actions": []map[string]interface{}{
+				{"action": "create", "file_path": "scripts/run.sh", "content": "IyEvYmluL3No", "encoding": "base64"},
+				{"action": "chmod", "file_path": "scripts/run.sh", "execute_filemode": true},
+			},
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/gitlab_create_commit.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "my-test-branch", "test", scm.Signature{}, []FileChange{
+		{Action: FileCreate, Path: "scripts/run.sh", Content: []byte("#!/bin/sh"), Mode: ModeExecutable},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+}
+
+func TestCommitFilesWithSymlinkInGitLab(t *testing.T) {
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "my-test-branch", "test", scm.Signature{}, []FileChange{
+		{Action: FileCreate, Path: "config/current", Content: []byte("v1"), Mode: ModeSymlink},
+	})
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+	}
+}
+
+func TestCommitFilesWithInvalidMode(t *testing.T) {
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "test", scm.Signature{}, []FileChange{
+		{Action: FileCreate, Path: "config/my/file.yaml", Content: []byte("testing"), Mode: "755"},
+	})
+	if !test.MatchError(t, `failed to commit file config/my/file.yaml: invalid file mode "755"`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestListPullRequests(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/pulls").
+		MatchParam("page", "2").
+		MatchParam("per_page", "10").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString("[" + readFixture(t, "testdata/pr_create.json") + "]")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	prs, err := client.ListPullRequests(context.Background(), "Codertocat/Hello-World", scm.PullRequestListOptions{Page: 2, Size: 10, Open: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(prs); l != 1 {
+		t.Fatalf("got %d pull requests, want 1", l)
+	}
+	if prs[0].Number != 1347 || prs[0].Source != "new-topic" || prs[0].Target != "master" {
+		t.Fatalf("got an incorrect pull request: %#v", prs[0])
+	}
+}
+
+func TestUpdatePullRequestInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/pulls/1347").
+		MatchType("json").
+		JSON(map[string]string{"title": "Amazing new feature", "body": "Please pull these awesome changes in!"}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	pr, err := client.UpdatePullRequest(context.Background(), "Codertocat/Hello-World", 1347, &scm.PullRequestInput{
+		Title: "Amazing new feature",
+		Body:  "Please pull these awesome changes in!",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("pull request was not updated")
+	}
+	if pr.Number != 1347 {
+		t.Fatalf("got pull request %d, want 1347", pr.Number)
+	}
+}
+
+func TestUpdatePullRequestInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Put("/api/v4/projects/Codertocat/Hello-World/merge_requests/1").
+		MatchType("json").
+		JSON(map[string]string{"title": "Amazing new feature", "description": "Please pull these awesome changes in!", "target_branch": "main"}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"iid": 1}`)
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/merge_requests/1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"iid": 1, "title": "Amazing new feature", "state": "opened"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.UpdatePullRequest(context.Background(), "Codertocat/Hello-World", 1, &scm.PullRequestInput{
+		Title:  "Amazing new feature",
+		Body:   "Please pull these awesome changes in!",
+		Target: "main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("merge request was not updated")
+	}
+}
+
+func TestCreatePullRequestWithOptionsInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/pulls").
+		MatchType("json").
+		JSON(map[string]interface{}{"title": "Amazing new feature", "body": "Please pull these awesome changes in!", "head": "octocat:new-topic", "base": "master", "draft": true}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/issues/1347/labels").
+		MatchType("json").
+		JSON(map[string][]string{"labels": {"bug"}}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"name": "bug"}]`)
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/pulls/1347/requested_reviewers").
+		MatchType("json").
+		JSON(map[string][]string{"reviewers": {"octocat"}, "team_reviewers": {"justice-league"}}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/issues/1347").
+		MatchType("json").
+		JSON(map[string]interface{}{"assignees": []string{"hubot"}, "milestone": 1}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"number": 1347}`)
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	pr, err := client.CreatePullRequestWithOptions(context.Background(), "Codertocat/Hello-World", &scm.PullRequestInput{
+		Title:  "Amazing new feature",
+		Body:   "Please pull these awesome changes in!",
+		Source: "octocat:new-topic",
+		Target: "master",
+	}, PullRequestOptions{
+		Labels:        []string{"bug"},
+		Reviewers:     []string{"octocat"},
+		TeamReviewers: []string{"justice-league"},
+		Assignees:     []string{"hubot"},
+		Milestone:     1,
+		Draft:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("pull request options were not applied")
+	}
+	if pr.Number != 1347 {
+		t.Fatalf("got pull request %d, want 1347", pr.Number)
+	}
+}
+
+func TestCreatePullRequestWithOptionsInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/users").
+		MatchParam("username", "octocat").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"id": 1, "username": "octocat"}]`)
+	gock.New("https://gitlab.com").
+		Get("/api/v4/users").
+		MatchParam("username", "hubot").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"id": 2, "username": "hubot"}]`)
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/Codertocat/Hello-World/merge_requests").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"title":         "Draft: Amazing new feature",
+			"description":   "Please pull these awesome changes in!",
+			"source_branch": "new-topic",
+			"target_branch": "master",
+			"labels":        "bug,urgent",
+			"assignee_ids":  []int{1},
+			"reviewer_ids":  []int{2},
+			"milestone_id":  5,
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		BodyString(`{"iid": 1}`)
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/merge_requests/1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"iid": 1, "title": "Draft: Amazing new feature", "state": "opened"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CreatePullRequestWithOptions(context.Background(), "Codertocat/Hello-World", &scm.PullRequestInput{
+		Title:  "Amazing new feature",
+		Body:   "Please pull these awesome changes in!",
+		Source: "new-topic",
+		Target: "master",
+	}, PullRequestOptions{
+		Labels:    []string{"bug", "urgent"},
+		Assignees: []string{"octocat"},
+		Reviewers: []string{"hubot"},
+		Milestone: 5,
+		Draft:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("merge request was not created")
+	}
+}
+
+func TestCreatePullRequestWithUnsupportedOptions(t *testing.T) {
+	optionsTests := []struct {
+		driver string
+		opts   PullRequestOptions
+	}{
+		{"gitlab", PullRequestOptions{TeamReviewers: []string{"justice-league"}}},
+		{"bitbucket", PullRequestOptions{Labels: []string{"bug"}}},
+	}
+
+	for _, tt := range optionsTests {
+		t.Run(tt.driver, func(t *testing.T) {
+			scmClient, err := factory.NewClient(tt.driver, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := New(scmClient)
+
+			_, err = client.CreatePullRequestWithOptions(context.Background(), "Codertocat/Hello-World", &scm.PullRequestInput{
+				Title:  "Amazing new feature",
+				Source: "new-topic",
+				Target: "master",
+			}, tt.opts)
+			if !errors.Is(err, scm.ErrNotSupported) {
+				t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+			}
+		})
+	}
+}
+
+func TestMergePullRequestInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/octocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Put("/repos/octocat/Hello-World/pulls/1347/merge").
+		MatchType("json").
+		JSON(map[string]string{"merge_method": "squash", "sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e", "merged": true}`)
+	gock.New("https://api.github.com").
+		Delete("/repos/octocat/Hello-World/git/refs/heads/new-topic").
+		Reply(http.StatusNoContent)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MergePullRequest(context.Background(), "octocat/Hello-World", 1347, MergeOptions{
+		Method:       MergeMethodSquash,
+		SHA:          "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		DeleteBranch: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("pull request was not merged")
+	}
+}
+
+func TestMergePullRequestFromForkInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Put("/repos/Codertocat/Hello-World/pulls/1347/merge").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e", "merged": true}`)
+	gock.New("https://api.github.com").
+		Delete("/repos/octocat/Hello-World/git/refs/heads/new-topic").
+		Reply(http.StatusNoContent)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MergePullRequest(context.Background(), "Codertocat/Hello-World", 1347, MergeOptions{DeleteBranch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("branch was not deleted from the fork")
+	}
+}
+
+func TestMergePullRequestWithBranchDeletionFailureInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/octocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Put("/repos/octocat/Hello-World/pulls/1347/merge").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e", "merged": true}`)
+	gock.New("https://api.github.com").
+		Delete("/repos/octocat/Hello-World/git/refs/heads/new-topic").
+		Reply(http.StatusUnprocessableEntity).
+		Type("application/json").
+		BodyString(`{"message": "Reference does not exist"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MergePullRequest(context.Background(), "octocat/Hello-World", 1347, MergeOptions{DeleteBranch: true})
+	if !errors.Is(err, ErrBranchNotDeleted) {
+		t.Fatalf("got %v, want %v", err, ErrBranchNotDeleted)
+	}
+	if !test.MatchError(t, `pull request 1347 in repo octocat/Hello-World was merged: .*Reference does not exist`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestMergePullRequestWithConflict(t *testing.T) {
+	gock.New("https://api.github.com").
+		Put("/repos/Codertocat/Hello-World/pulls/1347/merge").
+		Reply(http.StatusConflict).
+		Type("application/json").
+		BodyString(`{"message": "Head branch was modified. Review and try the merge again."}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MergePullRequest(context.Background(), "Codertocat/Hello-World", 1347, MergeOptions{})
+	if !IsConflict(err) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+}
+
+func TestMergePullRequestInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Put("/api/v4/projects/Codertocat/Hello-World/merge_requests/1/merge").
+		MatchType("json").
+		JSON(map[string]interface{}{"squash": true, "squash_commit_message": "Bump service-a", "should_remove_source_branch": true}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"iid": 1, "state": "merged"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.MergePullRequest(context.Background(), "Codertocat/Hello-World", 1, MergeOptions{
+		Method:        MergeMethodSquash,
+		CommitMessage: "Bump service-a",
+		DeleteBranch:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("merge request was not merged")
+	}
+}
+
+func TestMergePullRequestWithUnsupportedOptions(t *testing.T) {
+	mergeTests := []struct {
+		driver string
+		opts   MergeOptions
+	}{
+		{"gitlab", MergeOptions{Method: MergeMethodRebase}},
+		{"bitbucket", MergeOptions{Method: MergeMethodSquash}},
+	}
+
+	for _, tt := range mergeTests {
+		t.Run(tt.driver, func(t *testing.T) {
+			scmClient, err := factory.NewClient(tt.driver, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := New(scmClient)
+
+			err = client.MergePullRequest(context.Background(), "Codertocat/Hello-World", 1, tt.opts)
+			if !errors.Is(err, scm.ErrNotSupported) {
+				t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+			}
+		})
+	}
+}
+
+func TestListStatuses(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+		MatchParam("page", "1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		SetHeader("Link", `<https://api.github.com/repos/Codertocat/Hello-World/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e?page=2>; rel="next"`).
+		File("testdata/github_statuses.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+		MatchParam("page", "2").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_statuses.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	statuses, err := client.ListStatuses(context.Background(), "Codertocat/Hello-World", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("statuses were not listed")
+	}
+	want := &scm.Status{
+		State:  scm.StateSuccess,
+		Label:  "continuous-integration/drone",
+		Desc:   "Build has completed successfully",
+		Target: "https://ci.example.com/1000/output",
+	}
+	if diff := cmp.Diff([]*scm.Status{want, want}, statuses); diff != "" {
+		t.Fatalf("failed to list statuses:\n%s", diff)
+	}
+}
+
+func TestGetCombinedStatusInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/commits/new-topic/status").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_combined_status.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/commits/new-topic/check-runs").
+		MatchParam("page", "1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_check_runs.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	status, err := client.GetCombinedStatus(context.Background(), "Codertocat/Hello-World", "new-topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &CombinedStatus{
+		SHA:   "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		State: scm.StateFailure,
+		Statuses: []*scm.Status{
+			{
+				State:  scm.StateSuccess,
+				Label:  "continuous-integration/jenkins",
+				Desc:   "Build has completed successfully",
+				Target: "https://ci.example.com/1000/output",
+			},
+			{
+				State:  scm.StateFailure,
+				Label:  "mighty_readme",
+				Desc:   "Mighty Readme report",
+				Target: "https://github.com/github/hello-world/runs/4",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Fatalf("incorrect combined status:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"mighty_readme"}, status.Failed()); diff != "" {
+		t.Fatalf("incorrect failed statuses:\n%s", diff)
+	}
+}
+
+func TestListBranches(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches").
+		MatchParam("page", "1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"name": "master", "commit": {"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}}]`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	branches, err := client.ListBranches(context.Background(), "Codertocat/Hello-World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*scm.Reference{{Name: "master", Path: "refs/heads/master", Sha: "6dcb09b5b57875f334f61aebed695e2e4193db5e"}}
+	if diff := cmp.Diff(want, branches); diff != "" {
+		t.Fatalf("failed to list branches:\n%s", diff)
+	}
+}
+
+func TestDeleteBranchInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Delete("/api/v4/projects/Codertocat/Hello-World/repository/branches/gitops-abcde").
+		Reply(http.StatusNoContent)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	if err := client.DeleteBranch(context.Background(), "Codertocat/Hello-World", "gitops-abcde"); err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("branch was not deleted")
+	}
+}
+
+func mustParseJSONAsContent(t *testing.T, filename string) *scm.Content {
+	t.Helper()
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := base64.StdEncoding.DecodeString(data["content"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &scm.Content{
+		Path:   data["path"].(string),
+		BlobID: data["sha"].(string),
+		Data:   content,
+	}
+}
+
+// mockGitHubTrees mocks the trees of the directories with config/my/file.yaml
+// in the tree of testdata/github_get_commit.json, with the mode of the file.
+func mockGitHubTrees(mode string) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/691272480426f78a0138979dd3ce63b77f706feb").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"tree": [{"path": "config", "mode": "040000", "type": "tree", "sha": "1e6b0f6c5d7e7c2a1f4c5b1b6f1b3d0a4f1b2c3d"}]}`)
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/1e6b0f6c5d7e7c2a1f4c5b1b6f1b3d0a4f1b2c3d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"tree": [{"path": "my", "mode": "040000", "type": "tree", "sha": "2f7c1a7d6e8f8d3b2a5d6c2c7a2c4e1b5a2c3d4e"}]}`)
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/2f7c1a7d6e8f8d3b2a5d6c2c7a2c4e1b5a2c3d4e").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"tree": [{"path": "file.yaml", "mode": "` + mode + `", "type": "blob", "sha": "980a0d5f19a64b4b30a87d4206aade58726b60e3"}]}`)
+}
+
+// mockGitHubBlob mocks creating a blob with the content, and returns the SHA
+// of the blob.
+func mockGitHubBlob(content []byte) string {
+	sha := BlobSHA(content)
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/blobs").
+		MatchType("json").
+		JSON(map[string]string{"content": base64.StdEncoding.EncodeToString(content), "encoding": "base64"}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		BodyString(`{"sha": "` + sha + `"}`)
+	return sha
+}
+
+func readFixture(t *testing.T, filename string) string {
+	t.Helper()
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return string(body)
 }
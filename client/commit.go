@@ -0,0 +1,468 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+// FileAction identifies the change that a FileChange makes to a path.
+type FileAction int
+
+const (
+	// FileUpdate replaces the content of an existing file.
+	FileUpdate FileAction = iota
+	// FileCreate adds a new file.
+	FileCreate
+	// FileDelete removes an existing file.
+	FileDelete
+	// FileMove moves an existing file from the PreviousPath to the Path.
+	FileMove
+)
+
+// FileMode is the Git mode of a file.
+type FileMode string
+
+const (
+	// ModeRegular is the mode of a regular file.
+	ModeRegular FileMode = "100644"
+	// ModeExecutable is the mode of an executable file.
+	ModeExecutable FileMode = "100755"
+	// ModeSymlink is the mode of a symbolic link, the content of the file is
+	// the target of the link.
+	ModeSymlink FileMode = "120000"
+)
+
+// Validate returns an error if the mode is not the mode of a file, the empty
+// mode is valid.
+func (m FileMode) Validate() error {
+	switch m {
+	case "", ModeRegular, ModeExecutable, ModeSymlink:
+		return nil
+	}
+	return fmt.Errorf("invalid file mode %q", string(m))
+}
+
+// FileChange is a change to a single path, applied as part of a commit with
+// CommitFiles.
+type FileChange struct {
+	Action  FileAction
+	Path    string // relative path to the file in the repository
+	Content []byte // ignored when deleting, the content is not changed when moving if it's nil
+	// Mode is optional, and is ignored when deleting. The mode of an existing
+	// file is not changed if it's empty, and new files are regular files.
+	Mode FileMode
+	// PreviousPath is the path of the file before it's moved, it's only used
+	// when moving.
+	PreviousPath string
+	// PreviousSHA is optional, and is used to detect concurrent modifications
+	// of the file, it's the blob SHA of the file for GitHub, or of the file
+	// at the PreviousPath when moving, and the last_commit_id for GitLab.
+	PreviousSHA string
+}
+
+// CommitFiles creates a single commit on the branch that applies all the
+// changes, and returns the SHA of the new commit.
+//
+// This is supported for GitHub (using the Git data API) and GitLab (using the
+// commits API), other drivers return an error wrapping scm.ErrNotSupported.
+// Symbolic links can't be committed with GitLab.
+//
+// If commits are signed, see SignCommits, the commit is only created if it
+// matches the signed commit, and this is only supported for GitHub. The
+// signature must have a name and email, and the date defaults to the current
+// time.
+//
+// If the PreviousSHA of a change does not match the file in the branch, an
+// error with a 409 status is returned, new files are not checked for GitHub.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []FileChange) (string, error) {
+	keys := []string{headCacheKey(repo, branch)}
+	for _, change := range changes {
+		keys = append(keys, fileCacheKey(repo, branch, change.Path))
+		if change.Action == FileMove {
+			keys = append(keys, fileCacheKey(repo, branch, change.PreviousPath))
+		}
+	}
+	defer c.cache.invalidate(keys...)
+
+	for _, change := range changes {
+		if err := change.Mode.Validate(); err != nil {
+			return "", fmt.Errorf("failed to commit file %s: %w", change.Path, err)
+		}
+	}
+	if c.signer != nil && c.scmClient.Driver != scm.DriverGithub {
+		return "", fmt.Errorf("signing commits with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		return c.commitFilesGitHub(ctx, repo, branch, message, signature, changes)
+	case scm.DriverGitlab:
+		return c.commitFilesGitLab(ctx, repo, branch, message, signature, changes)
+	}
+	return "", fmt.Errorf("committing multiple files with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+}
+
+type ghObject struct {
+	Sha string `json:"sha"`
+}
+
+type ghGitCommit struct {
+	Sha  string   `json:"sha"`
+	Tree ghObject `json:"tree"`
+}
+
+type ghGitCommitInput struct {
+	Message   string          `json:"message"`
+	Tree      string          `json:"tree"`
+	Parents   []string        `json:"parents"`
+	Author    *ghGitSignature `json:"author,omitempty"`
+	Committer *ghGitSignature `json:"committer,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+type ghGitSignature struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date,omitempty"`
+}
+
+type ghTreeInput struct {
+	BaseTree string                   `json:"base_tree"`
+	Tree     []map[string]interface{} `json:"tree"`
+}
+
+type ghBlobInput struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+type ghRefUpdate struct {
+	Sha   string `json:"sha"`
+	Force bool   `json:"force"`
+}
+
+func (c *SCMClient) commitFilesGitHub(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []FileChange) (string, error) {
+	head, err := c.GetBranchHead(ctx, repo, branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch head for %s: %w", branch, err)
+	}
+	parent := &ghGitCommit{}
+	_, err = c.do(ctx, fmt.Sprintf("failed to get commit %s from repo %s", head, repo),
+		"GET", fmt.Sprintf("repos/%s/git/commits/%s", repo, head), nil, parent)
+	if err != nil {
+		return "", err
+	}
+
+	tree := &ghTreeInput{BaseTree: parent.Tree.Sha}
+	trees := map[string][]ghTreeEntry{}
+	for _, change := range changes {
+		entry := map[string]interface{}{
+			"path": change.Path,
+			"mode": string(ModeRegular),
+			"type": "blob",
+		}
+		var current *ghTreeEntry
+		switch change.Action {
+		case FileUpdate, FileDelete:
+			if change.PreviousSHA != "" || (change.Action == FileUpdate && change.Mode == "") {
+				if current, err = c.treeEntryGitHub(ctx, repo, parent.Tree.Sha, change.Path, trees); err != nil {
+					return "", err
+				}
+			}
+		case FileMove:
+			// The trees API can't move an entry, so the existing blob is
+			// added at the new path, and removed from the previous path.
+			if current, err = c.treeEntryGitHub(ctx, repo, parent.Tree.Sha, change.PreviousPath, trees); err != nil {
+				return "", err
+			}
+			if current == nil {
+				return "", SCMError{Msg: fmt.Sprintf("failed to move file %s", change.PreviousPath), Status: http.StatusNotFound, ResponseMsg: "Not Found"}
+			}
+		}
+		if current != nil && change.PreviousSHA != "" && current.Sha != change.PreviousSHA {
+			previousPath := change.Path
+			if change.Action == FileMove {
+				previousPath = change.PreviousPath
+			}
+			return "", SCMError{
+				Msg:         fmt.Sprintf("failed to commit file %s in repo %s branch %s", change.Path, repo, branch),
+				Status:      http.StatusConflict,
+				ResponseMsg: fmt.Sprintf("%s does not match %s", previousPath, change.PreviousSHA),
+			}
+		}
+		if current != nil {
+			entry["mode"] = current.Mode
+		}
+		if change.Mode != "" {
+			entry["mode"] = string(change.Mode)
+		}
+		switch {
+		case change.Action == FileDelete:
+			entry["sha"] = nil
+		case change.Action == FileMove && change.Content == nil:
+			entry["sha"] = current.Sha
+		default:
+			if entry["sha"], err = c.createBlobGitHub(ctx, repo, change.Content); err != nil {
+				return "", err
+			}
+		}
+		tree.Tree = append(tree.Tree, entry)
+		if change.Action == FileMove {
+			tree.Tree = append(tree.Tree, map[string]interface{}{
+				"path": change.PreviousPath,
+				"mode": current.Mode,
+				"type": "blob",
+				"sha":  nil,
+			})
+		}
+	}
+	newTree := &ghObject{}
+	_, err = c.do(ctx, fmt.Sprintf("failed to create tree in repo %s", repo),
+		"POST", fmt.Sprintf("repos/%s/git/trees", repo), tree, newTree)
+	if err != nil {
+		return "", err
+	}
+
+	commitInput := &ghGitCommitInput{
+		Message: message,
+		Tree:    newTree.Sha,
+		Parents: []string{head},
+	}
+	if signature.Name != "" || signature.Email != "" {
+		commitInput.Author = githubSignature(signature)
+		commitInput.Committer = commitInput.Author
+	}
+	var signed string
+	if c.signer != nil {
+		if signed, err = c.signCommit(ctx, repo, commitInput, signature); err != nil {
+			return "", err
+		}
+	}
+	commit := &ghGitCommit{}
+	_, err = c.do(ctx, fmt.Sprintf("failed to create commit in repo %s", repo),
+		"POST", fmt.Sprintf("repos/%s/git/commits", repo), commitInput, commit)
+	if err != nil {
+		return "", err
+	}
+	if signed != "" && commit.Sha != signed {
+		return "", fmt.Errorf("failed to create commit in repo %s: the created commit %s does not match the signed commit %s", repo, commit.Sha, signed)
+	}
+
+	_, err = c.do(ctx, fmt.Sprintf("failed to update branch %s in repo %s", branch, repo),
+		"PATCH", fmt.Sprintf("repos/%s/git/refs/heads/%s", repo, branch), &ghRefUpdate{Sha: commit.Sha}, nil)
+	if err != nil {
+		return "", err
+	}
+	return commit.Sha, nil
+}
+
+// createBlobGitHub creates a blob with the content, and returns its SHA.
+//
+// The content is base64 encoded, as content in the trees API is sent as a
+// string, which would replace bytes that are not valid UTF-8.
+func (c *SCMClient) createBlobGitHub(ctx context.Context, repo string, content []byte) (string, error) {
+	blob := &ghObject{}
+	_, err := c.do(ctx, fmt.Sprintf("failed to create blob in repo %s", repo),
+		"POST", fmt.Sprintf("repos/%s/git/blobs", repo), &ghBlobInput{Content: base64.StdEncoding.EncodeToString(content), Encoding: "base64"}, blob)
+	if err != nil {
+		return "", err
+	}
+	return blob.Sha, nil
+}
+
+// treeEntryGitHub returns the entry for the file at the path in the tree, or
+// nil if there's no file at the path.
+//
+// Each directory in the path is fetched with the trees API, rather than the
+// recursive tree, which is truncated for large repositories, and the entries
+// are kept in trees, which is keyed by directory.
+func (c *SCMClient) treeEntryGitHub(ctx context.Context, repo, root, filename string, trees map[string][]ghTreeEntry) (*ghTreeEntry, error) {
+	dir, name := path.Split(strings.Trim(filename, "/"))
+	entries, err := c.treeEntriesGitHub(ctx, repo, root, strings.TrimSuffix(dir, "/"), trees)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Path == name && entries[i].Type == "blob" {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *SCMClient) treeEntriesGitHub(ctx context.Context, repo, root, dir string, trees map[string][]ghTreeEntry) ([]ghTreeEntry, error) {
+	if entries, ok := trees[dir]; ok {
+		return entries, nil
+	}
+	sha := root
+	if dir != "" {
+		parent, name := path.Split(dir)
+		entries, err := c.treeEntriesGitHub(ctx, repo, root, strings.TrimSuffix(parent, "/"), trees)
+		if err != nil {
+			return nil, err
+		}
+		sha = ""
+		for _, entry := range entries {
+			if entry.Path == name && entry.Type == "tree" {
+				sha = entry.Sha
+			}
+		}
+		if sha == "" {
+			trees[dir] = nil
+			return nil, nil
+		}
+	}
+	tree := &ghTree{}
+	msg := fmt.Sprintf("failed to get tree %s from repo %s", sha, repo)
+	if _, err := c.do(ctx, msg, "GET", fmt.Sprintf("repos/%s/git/trees/%s", repo, sha), nil, tree); err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		return nil, fmt.Errorf("%s: the tree has too many entries to be listed", msg)
+	}
+	trees[dir] = tree.Tree
+	return tree.Tree, nil
+}
+
+// signCommit signs the commit that GitHub creates from the input, adds the
+// signature to the input, and returns the SHA of the signed commit.
+//
+// GitHub only creates the same commit if the author and date are provided, so
+// they're set on the input, the date is in UTC, as the time zone is not kept.
+func (c *SCMClient) signCommit(ctx context.Context, repo string, in *ghGitCommitInput, signature scm.Signature) (string, error) {
+	msg := fmt.Sprintf("failed to sign commit in repo %s", repo)
+	if signature.Name == "" || signature.Email == "" {
+		return "", fmt.Errorf("%s: a signature with a name and email is required", msg)
+	}
+	if signature.Date.IsZero() {
+		signature.Date = time.Now()
+	}
+	signature.Date = signature.Date.UTC().Truncate(time.Second)
+	in.Author = githubSignature(signature)
+	in.Committer = in.Author
+
+	sig := object.Signature{Name: signature.Name, Email: signature.Email, When: signature.Date}
+	commit := &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   in.Message,
+		TreeHash:  plumbing.NewHash(in.Tree),
+	}
+	for _, parent := range in.Parents {
+		commit.ParentHashes = append(commit.ParentHashes, plumbing.NewHash(parent))
+	}
+	unsigned := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+	r, err := unsigned.Reader()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+	defer r.Close()
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+	if in.Signature, err = c.signer.Sign(ctx, payload); err != nil {
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+
+	commit.PGPSignature = in.Signature
+	signed := &plumbing.MemoryObject{}
+	if err := commit.Encode(signed); err != nil {
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+	return signed.Hash().String(), nil
+}
+
+func githubSignature(s scm.Signature) *ghGitSignature {
+	sig := &ghGitSignature{Name: s.Name, Email: s.Email}
+	if !s.Date.IsZero() {
+		sig.Date = s.Date.Format(time.RFC3339)
+	}
+	return sig
+}
+
+type glCommitInput struct {
+	Branch        string           `json:"branch"`
+	CommitMessage string           `json:"commit_message"`
+	AuthorName    string           `json:"author_name,omitempty"`
+	AuthorEmail   string           `json:"author_email,omitempty"`
+	Actions       []glCommitAction `json:"actions"`
+}
+
+type glCommitAction struct {
+	Action       string `json:"action"`
+	FilePath     string `json:"file_path"`
+	PreviousPath string `json:"previous_path,omitempty"`
+	Content      string `json:"content,omitempty"`
+	Encoding     string `json:"encoding,omitempty"`
+	LastCommitID string `json:"last_commit_id,omitempty"`
+	// ExecuteFilemode is only used by the chmod action.
+	ExecuteFilemode *bool `json:"execute_filemode,omitempty"`
+}
+
+type glCommit struct {
+	ID string `json:"id"`
+}
+
+func (c *SCMClient) commitFilesGitLab(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []FileChange) (string, error) {
+	in := &glCommitInput{
+		Branch:        branch,
+		CommitMessage: message,
+		AuthorName:    signature.Name,
+		AuthorEmail:   signature.Email,
+	}
+	for _, change := range changes {
+		action := glCommitAction{
+			FilePath:     change.Path,
+			LastCommitID: change.PreviousSHA,
+		}
+		switch change.Action {
+		case FileCreate:
+			action.Action = "create"
+		case FileDelete:
+			action.Action = "delete"
+		case FileMove:
+			action.Action = "move"
+			action.PreviousPath = change.PreviousPath
+		default:
+			action.Action = "update"
+		}
+		if change.Action != FileDelete && !(change.Action == FileMove && change.Content == nil) {
+			action.Content = base64.StdEncoding.EncodeToString(change.Content)
+			action.Encoding = "base64"
+		}
+		in.Actions = append(in.Actions, action)
+		if change.Action == FileDelete || change.Mode == "" {
+			continue
+		}
+		// The commits API keeps the mode of existing files, and only sets the
+		// execute flag with the chmod action.
+		if change.Mode == ModeSymlink {
+			return "", fmt.Errorf("committing symbolic links with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+		}
+		executable := change.Mode == ModeExecutable
+		in.Actions = append(in.Actions, glCommitAction{Action: "chmod", FilePath: change.Path, ExecuteFilemode: &executable})
+	}
+	commit := &glCommit{}
+	_, err := c.do(ctx, fmt.Sprintf("failed to commit files in repo %s branch %s", repo, branch),
+		"POST", fmt.Sprintf("api/v4/projects/%s/repository/commits", encodeGitLabRepo(repo)), in, commit)
+	if err != nil {
+		return "", err
+	}
+	return commit.ID, nil
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/go-scm/scm/factory"
+	"github.com/ocraviotto/go-scm/scm/transport"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ocraviotto/pkg/secrets"
+)
+
+const defaultTokenKey = "token"
+
+// wellKnownHosts are the hosted services whose driver can be identified from
+// the host, additional hosts can be added with the GIT_DRIVERS environment
+// variable, e.g. GIT_DRIVERS=git.example.com=gitlab.
+var wellKnownHosts = map[string]string{
+	"github.com":    "github",
+	"gitlab.com":    "gitlab",
+	"bitbucket.org": "bitbucket",
+	"gitea.com":     "gitea",
+}
+
+// Config identifies an upstream service, and the credentials and TLS settings
+// for accessing it.
+type Config struct {
+	// URL is the URL of a repository on the upstream service, or its host,
+	// e.g. "https://github.com/org/repo" or "gitlab.example.com". Any path in
+	// the URL is ignored, see BasePath.
+	URL string
+	// Driver is the go-scm driver, one of github, gitlab, gitea, gogs,
+	// bitbucket or stash. It is identified from the host if it's empty, which
+	// is only possible for well-known hosts.
+	Driver string
+	// BasePath is the path on the host that the service is served from, e.g.
+	// "/gitlab". For GitHub Enterprise, "/api/v3" is added if there's no API
+	// path.
+	BasePath string
+
+	// Secret is the secret with the token for accessing the service, no
+	// token is used if the name is empty.
+	Secret   types.NamespacedName
+	TokenKey string // the key in the Secret with the token, defaults to "token"
+	// Username is used with the token for basic auth with Bitbucket Cloud app
+	// passwords, the token is used as a bearer token otherwise.
+	Username string
+
+	// CAKey is the key in the Secret with PEM encoded certificates that are
+	// trusted in addition to the system certificates.
+	CAKey string
+	// CAFile is the path of a file with PEM encoded certificates that are
+	// trusted in addition to the system certificates.
+	CAFile             string
+	InsecureSkipVerify bool
+
+	// SigningKey is the key that commits are signed with, commits are not
+	// signed if the key is empty, see SignCommits.
+	SigningKey SigningKey
+}
+
+// NewFromConfig creates and returns a new SCMClient for the upstream service
+// identified by the config, the token and certificates are looked up with the
+// SecretGetter.
+func NewFromConfig(ctx context.Context, cfg Config, s secrets.SecretGetter, opts ...ClientFunc) (*SCMClient, error) {
+	host, err := parseHost(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	driver := cfg.Driver
+	if driver == "" {
+		driver, err = identifyDriver(host.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	serverURL := ""
+	if _, ok := wellKnownHosts[host.Host]; !ok || cfg.BasePath != "" {
+		serverURL = strings.TrimSuffix(host.String()+"/"+strings.Trim(cfg.BasePath, "/"), "/")
+	}
+	scmClient, err := factory.NewClient(driver, serverURL, "", factory.SetUsername(cfg.Username))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for driver %s: %w", driver, err)
+	}
+
+	base, err := cfg.transport(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	token, err := cfg.token(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	scmClient.Client = &http.Client{Transport: authTransport(scmClient.Driver, token, cfg.Username, base)}
+	if cfg.SigningKey.Key != "" {
+		opts = append([]ClientFunc{SignCommits(NewCommitSigner(s, cfg.SigningKey))}, opts...)
+	}
+	return New(scmClient, opts...), nil
+}
+
+// parseHost returns the scheme and host from a URL, which defaults to HTTPS if
+// no scheme is provided, or the host is from an SCP style Git URL, e.g.
+// "git@github.com:org/repo.git".
+func parseHost(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, errors.New("no URL provided")
+	}
+	if !strings.Contains(s, "://") {
+		if at := strings.Index(s, "@"); at >= 0 {
+			s = strings.Replace(s[at+1:], ":", "/", 1)
+		}
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL %q: %w", s, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("failed to parse URL %q: no host", s)
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}, nil
+}
+
+func identifyDriver(host string) (string, error) {
+	var mappings []factory.MappingFunc
+	for h, d := range wellKnownHosts {
+		mappings = append(mappings, factory.Mapping(h, d))
+	}
+	driver, err := factory.NewDriverIdentifier(mappings...).Identify(host)
+	if err != nil {
+		return "", fmt.Errorf("no driver provided: %w", err)
+	}
+	return driver, nil
+}
+
+func (cfg Config) token(ctx context.Context, s secrets.SecretGetter) (string, error) {
+	if cfg.Secret.Name == "" {
+		return "", nil
+	}
+	if s == nil {
+		return "", errors.New("a secret is configured but no SecretGetter was provided")
+	}
+	key := cfg.TokenKey
+	if key == "" {
+		key = defaultTokenKey
+	}
+	token, err := s.SecretToken(ctx, cfg.Secret, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token: %w", err)
+	}
+	return token, nil
+}
+
+// transport returns the base transport with the TLS settings, or nil if the
+// default transport can be used.
+func (cfg Config) transport(ctx context.Context, s secrets.SecretGetter) (http.RoundTripper, error) {
+	if cfg.CAKey == "" && cfg.CAFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAKey != "" || cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if cfg.CAKey != "" {
+			if s == nil {
+				return nil, errors.New("a CA key is configured but no SecretGetter was provided")
+			}
+			ca, err := s.SecretToken(ctx, cfg.Secret, cfg.CAKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get CA certificates: %w", err)
+			}
+			if !pool.AppendCertsFromPEM([]byte(ca)) {
+				return nil, fmt.Errorf("failed to parse CA certificates from key %s", cfg.CAKey)
+			}
+		}
+		if cfg.CAFile != "" {
+			ca, err := ioutil.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("failed to parse CA certificates from %s", cfg.CAFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}, nil
+	}
+	t = t.Clone()
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// authTransport returns a transport that authenticates requests in the way
+// expected by the driver, this matches factory.NewClient.
+func authTransport(driver scm.Driver, token, username string, base http.RoundTripper) http.RoundTripper {
+	if token == "" {
+		return base
+	}
+	switch driver {
+	case scm.DriverGitea:
+		return &transport.Authorization{Base: base, Scheme: "token", Credentials: token}
+	case scm.DriverGitlab:
+		return &transport.PrivateToken{Base: base, Token: token}
+	case scm.DriverBitbucket:
+		if username != "" {
+			return &transport.BasicAuth{Base: base, Username: username, Password: token}
+		}
+	}
+	return &transport.BearerToken{Base: base, Token: token}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"gopkg.in/h2non/gock.v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ocraviotto/pkg/secrets"
+	"github.com/ocraviotto/pkg/test"
+)
+
+var testSecretID = types.NamespacedName{Name: "git-credentials", Namespace: "test-ns"}
+
+func TestNewFromConfig(t *testing.T) {
+	configTests := []struct {
+		name        string
+		cfg         Config
+		wantDriver  scm.Driver
+		wantBaseURL string
+	}{
+		{"github repo URL", Config{URL: "https://github.com/Codertocat/Hello-World.git"}, scm.DriverGithub, "https://api.github.com/"},
+		{"github SCP URL", Config{URL: "git@github.com:Codertocat/Hello-World.git"}, scm.DriverGithub, "https://api.github.com/"},
+		{"gitlab host", Config{URL: "gitlab.com"}, scm.DriverGitlab, "https://gitlab.com/"},
+		{"bitbucket cloud", Config{URL: "https://bitbucket.org/testing/repo"}, scm.DriverBitbucket, "https://api.bitbucket.org/"},
+		{"github enterprise", Config{URL: "https://github.example.com/org/repo", Driver: "github"}, scm.DriverGithub, "https://github.example.com/api/v3/"},
+		{"gitlab with base path", Config{URL: "https://example.com/org/repo", Driver: "gitlab", BasePath: "/gitlab/"}, scm.DriverGitlab, "https://example.com/gitlab/"},
+		{"stash with port", Config{URL: "bitbucket.example.com:7990", Driver: "stash"}, scm.DriverStash, "https://bitbucket.example.com:7990/"},
+	}
+
+	for _, tt := range configTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			c, err := NewFromConfig(context.Background(), tt.cfg, nil)
+			if err != nil {
+				rt.Fatal(err)
+			}
+
+			if c.scmClient.Driver != tt.wantDriver {
+				rt.Errorf("got driver %s, want %s", c.scmClient.Driver, tt.wantDriver)
+			}
+			if got := c.scmClient.BaseURL.String(); got != tt.wantBaseURL {
+				rt.Errorf("got base URL %s, want %s", got, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+func TestNewFromConfigWithUnknownHost(t *testing.T) {
+	_, err := NewFromConfig(context.Background(), Config{URL: "https://git.example.com/org/repo"}, nil)
+
+	if !test.MatchError(t, `no driver provided: unable to identify driver from hostname: git.example.com`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestNewFromConfigWithMissingSecret(t *testing.T) {
+	_, err := NewFromConfig(context.Background(), Config{URL: "github.com", Secret: testSecretID}, secrets.NewSecretsStub())
+
+	if !test.MatchError(t, `failed to get token: not found`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestNewFromConfigWithSigningKey(t *testing.T) {
+	key := SigningKey{Secret: testSecretID, Key: "signing.key", Format: SigningFormatSSH}
+
+	c, err := NewFromConfig(context.Background(), Config{URL: "github.com", SigningKey: key}, secrets.NewSecretsStub())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, ok := c.signer.(*secretSigner)
+	if !ok {
+		t.Fatalf("got signer %#v, want a secretSigner", c.signer)
+	}
+	if signer.key != key {
+		t.Fatalf("got signing key %#v, want %#v", signer.key, key)
+	}
+}
+
+func TestNewFromConfigAuthentication(t *testing.T) {
+	authTests := []struct {
+		cfg        Config
+		path       string
+		wantHeader string
+		wantValue  string
+	}{
+		{Config{URL: "github.com"}, "/repos/Codertocat/Hello-World/branches/master", "Authorization", "Bearer test-token"},
+		{Config{URL: "gitlab.com"}, "/api/v4/projects/Codertocat/Hello-World/repository/branches/master", "Private-Token", "test-token"},
+	}
+
+	for _, tt := range authTests {
+		t.Run(tt.cfg.URL, func(rt *testing.T) {
+			defer gock.Off()
+			host := "https://api.github.com"
+			if tt.cfg.URL == "gitlab.com" {
+				host = "https://gitlab.com"
+			}
+			gock.New(host).
+				Get(tt.path).
+				MatchHeader(tt.wantHeader, tt.wantValue).
+				Reply(http.StatusOK).
+				Type("application/json").
+				File("testdata/github_get_branch.json")
+			s := secrets.NewSecretsStub()
+			s.StubSecret(testSecretID, "token", "test-token")
+			tt.cfg.Secret = testSecretID
+
+			c, err := NewFromConfig(context.Background(), tt.cfg, s)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			_, err = c.GetBranchHead(context.Background(), "Codertocat/Hello-World", "master")
+			if err != nil {
+				rt.Fatal(err)
+			}
+
+			if !gock.IsDone() {
+				rt.Fatal("request was not authenticated")
+			}
+		})
+	}
+}
+
+func TestNewFromConfigWithCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"main","commit":{"sha":"7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"}}`))
+	}))
+	t.Cleanup(ts.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	s := secrets.NewSecretsStub()
+	s.StubSecret(testSecretID, "ca.crt", string(ca))
+	s.StubSecret(testSecretID, "token", "test-token")
+
+	c, err := NewFromConfig(context.Background(), Config{URL: ts.URL, Driver: "github", Secret: testSecretID, CAKey: "ca.crt"}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha, err := c.GetBranchHead(context.Background(), "Codertocat/Hello-World", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sha != "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d" {
+		t.Fatalf("got sha %s", sha)
+	}
+}
+
+func TestNewFromConfigWithUntrustedCertificate(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(ts.Close)
+
+	c, err := NewFromConfig(context.Background(), Config{URL: ts.URL, Driver: "github"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetBranchHead(context.Background(), "Codertocat/Hello-World", "main")
+
+	if !test.MatchError(t, `certificate`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
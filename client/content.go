@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const (
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+	// Git LFS does not treat larger files as pointers.
+	maxLFSPointerSize = 1024
+)
+
+// emptyBlobSHA is the Git object ID of an empty blob.
+var emptyBlobSHA = BlobSHA(nil)
+
+// BlobSHA returns the Git object ID of a blob with the content, which is the
+// BlobID of a file with the content.
+func BlobSHA(b []byte) string {
+	return plumbing.ComputeHash(plumbing.BlobObject, b).String()
+}
+
+// ErrFileTooLarge is wrapped by errors returned when reading files that are
+// larger than the upstream service or the client can return, see MaxFileSize.
+var ErrFileTooLarge = errors.New("file too large")
+
+// ErrLFSPointer is wrapped by errors returned when changing files that are
+// stored in Git LFS, as only the pointer to the file is in the repository.
+var ErrLFSPointer = errors.New("file is stored in Git LFS")
+
+// LFSPointer is the content of a file that is stored in Git LFS.
+type LFSPointer struct {
+	OID  string // e.g. sha256:4d7a2146...
+	Size int64  // the size of the file that is stored in Git LFS
+}
+
+// ParseLFSPointer returns the LFSPointer if the content is a Git LFS pointer
+// file, and false otherwise.
+func ParseLFSPointer(b []byte) (*LFSPointer, bool) {
+	if len(b) > maxLFSPointerSize || !bytes.HasPrefix(b, []byte(lfsPointerVersion+"\n")) {
+		return nil, false
+	}
+	p := &LFSPointer{Size: -1}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		key, value, ok := cutSpace(s.Text())
+		if !ok {
+			return nil, false
+		}
+		switch key {
+		case "oid":
+			p.OID = value
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			p.Size = size
+		}
+	}
+	if p.OID == "" || p.Size < 0 {
+		return nil, false
+	}
+	return p, true
+}
+
+// MaxFileSize is an option func for the SCMClient creation function.
+//
+// It configures the client to return an error wrapping ErrFileTooLarge when
+// reading files that are larger than the size in bytes.
+func MaxFileSize(size int) ClientFunc {
+	return func(c *SCMClient) {
+		c.maxFileSize = size
+	}
+}
+
+// completeContent checks that the content of a file is complete, as contents
+// APIs return no content for large files, e.g. the GitHub API for files over
+// 1MB, and fetches the blob of the file with the Git data API for GitHub.
+//
+// Files with no content and the ID of a non-empty blob are incomplete.
+func (c *SCMClient) completeContent(ctx context.Context, repo, ref, path string, content *scm.Content) error {
+	msg := fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref)
+	if len(content.Data) == 0 && content.BlobID != "" && content.BlobID != emptyBlobSHA {
+		if c.scmClient.Driver != scm.DriverGithub {
+			return fmt.Errorf("%s: the content was not returned: %w", msg, ErrFileTooLarge)
+		}
+		blob := &struct {
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"`
+			Size     int    `json:"size"`
+		}{}
+		_, err := c.do(ctx, msg, "GET", fmt.Sprintf("repos/%s/git/blobs/%s", repo, content.BlobID), nil, blob)
+		if err != nil {
+			return err
+		}
+		if c.maxFileSize > 0 && blob.Size > c.maxFileSize {
+			return fmt.Errorf("%s: the file is %d bytes: %w", msg, blob.Size, ErrFileTooLarge)
+		}
+		if blob.Encoding != "base64" {
+			return fmt.Errorf("%s: unknown blob encoding %q", msg, blob.Encoding)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.Content, "\n", ""))
+		if err != nil {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+		if len(data) != blob.Size {
+			return fmt.Errorf("%s: got %d bytes of %d: %w", msg, len(data), blob.Size, ErrFileTooLarge)
+		}
+		content.Data = data
+	}
+	if c.maxFileSize > 0 && len(content.Data) > c.maxFileSize {
+		return fmt.Errorf("%s: the file is %d bytes: %w", msg, len(content.Data), ErrFileTooLarge)
+	}
+	return nil
+}
+
+// cutSpace splits the line at the first space.
+func cutSpace(line string) (string, string, bool) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return "", "", false
+	}
+	return line[:i], line[i+1:], true
+}
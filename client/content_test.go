@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm/factory"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestParseLFSPointer(t *testing.T) {
+	pointerTests := []struct {
+		name    string
+		content string
+		want    *LFSPointer
+	}{
+		{
+			"pointer",
+			"version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n",
+			&LFSPointer{OID: "sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Size: 12345},
+		},
+		{"regular file", "test:\n  image: old-image\n", nil},
+		{"empty file", "", nil},
+		{"missing oid", "version https://git-lfs.github.com/spec/v1\nsize 12345\n", nil},
+		{"invalid size", "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a2146\nsize large\n", nil},
+	}
+
+	for _, tt := range pointerTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			p, ok := ParseLFSPointer([]byte(tt.content))
+			if ok != (tt.want != nil) {
+				rt.Fatalf("got %v, want %v", ok, tt.want != nil)
+			}
+			if diff := cmp.Diff(tt.want, p); diff != "" {
+				rt.Fatalf("got a different pointer back: %s\n", diff)
+			}
+		})
+	}
+}
+
+func TestGetFileWithLargeFileInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/contents/config/my/file.yaml").
+		MatchParam("ref", "master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"type": "file", "encoding": "none", "size": 2097152, "path": "config/my/file.yaml", "content": "", "sha": "980a0d5f19a64b4b30a87d4206aade58726b60e3"}`)
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/blobs/980a0d5f19a64b4b30a87d4206aade58726b60e3").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"sha": "980a0d5f19a64b4b30a87d4206aade58726b60e3", "size": 25, "encoding": "base64", "content": "dGVzdDoKICBpbWFnZTog\nb2xkLWltYWdlCg==\n"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	body, err := client.GetFile(context.TODO(), "Codertocat/Hello-World", "master", "config/my/file.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(body.Data); s != "test:\n  image: old-image\n" {
+		t.Fatalf("got content %q", s)
+	}
+	if !gock.IsDone() {
+		t.Fatal("blob was not fetched")
+	}
+}
+
+func TestGetFileWithLargeFileInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/repository/files/config/my/file.yaml").
+		MatchParam("ref", "master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"file_path": "config/my/file.yaml", "size": 2097152, "encoding": "base64", "content": "", "blob_id": "980a0d5f19a64b4b30a87d4206aade58726b60e3"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.GetFile(context.TODO(), "Codertocat/Hello-World", "master", "config/my/file.yaml")
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("got %v, want %v", err, ErrFileTooLarge)
+	}
+}
+
+func TestGetFileWithMaxFileSize(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/contents/config/my/file.yaml").
+		MatchParam("ref", "master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/content.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, MaxFileSize(10))
+
+	_, err = client.GetFile(context.TODO(), "Codertocat/Hello-World", "master", "config/my/file.yaml")
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("got %v, want %v", err, ErrFileTooLarge)
+	}
+}
@@ -0,0 +1,640 @@
+package dryrun
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/diff"
+)
+
+var _ client.GitClient = (*Client)(nil)
+
+// Client is a client.GitClient that reads from the wrapped GitClient, and
+// records the branches, commits and PullRequests that would have been created
+// in a Plan, instead of writing them.
+//
+// Planned changes are visible to later reads through the Client, so a branch
+// that would have been created can be updated, and its files read back, as if
+// it existed. Commit statuses are always read from the wrapped GitClient.
+//
+// PullRequests that would have been created are given negative placeholder
+// numbers, starting at -1 in each repository, so that they can't be confused
+// with existing PullRequests, and they are only valid with this Client.
+//
+// Forks that would have been created are read from the forked repository.
+type Client struct {
+	gitClient client.GitClient
+
+	mu              sync.Mutex
+	plan            Plan
+	branches        map[string]*plannedBranch   // by key(repo, branch)
+	deletedBranches map[string]bool             // by key(repo, branch)
+	files           map[string]*plannedFile     // by key(repo, branch, path)
+	commits         map[string]*plannedCommit   // by key(repo, sha)
+	pullRequests    map[string]*scm.PullRequest // by key(repo, number)
+	created         map[string][]int            // numbers of the created PullRequests by repo
+	forks           map[string]string           // the forked repo by the name of the planned fork
+}
+
+// plannedBranch is a branch that would have been created, or an existing
+// branch that would have been committed to.
+type plannedBranch struct {
+	base string // the ref that unchanged files are read from
+	head string
+}
+
+type plannedFile struct {
+	data    []byte
+	deleted bool
+}
+
+type plannedCommit struct {
+	commit *scm.Commit
+	branch string // the branch the commit would have been made on
+}
+
+// New creates and returns a new Client that plans changes against the
+// GitClient.
+func New(c client.GitClient) *Client {
+	return &Client{
+		gitClient:       c,
+		branches:        map[string]*plannedBranch{},
+		deletedBranches: map[string]bool{},
+		files:           map[string]*plannedFile{},
+		commits:         map[string]*plannedCommit{},
+		pullRequests:    map[string]*scm.PullRequest{},
+		created:         map[string][]int{},
+		forks:           map[string]string{},
+	}
+}
+
+// Plan returns the changes that would have been made so far.
+func (c *Client) Plan() *Plan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &Plan{Changes: append([]Change(nil), c.plan.Changes...)}
+}
+
+// GetFile returns the planned content of the file if it would have been
+// changed on the branch, or the content from the wrapped GitClient.
+func (c *Client) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	c.mu.Lock()
+	f, ok := c.files[key(repo, ref, path)]
+	upstreamRef := ref
+	if b, planned := c.branches[key(repo, ref)]; planned {
+		upstreamRef = b.base
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return c.gitClient.GetFile(ctx, c.upstreamRepo(repo), upstreamRef, path)
+	}
+	if f.deleted {
+		return nil, notFound(fmt.Sprintf("file %s not found in repo %s ref %s", path, repo, ref))
+	}
+	sha := client.BlobSHA(f.data)
+	return &scm.Content{Path: path, Data: f.data, Sha: sha, BlobID: sha}, nil
+}
+
+// UpdateFile plans a commit that creates or replaces the file.
+func (c *Client) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.commit(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileUpdate, Path: path, Content: content},
+	})
+	return err
+}
+
+// DeleteFile plans a commit that deletes the file.
+func (c *Client) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.commit(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileDelete, Path: path},
+	})
+	return err
+}
+
+// MoveFile plans a commit that moves the file.
+func (c *Client) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.commit(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileMove, Path: newPath, PreviousPath: path, Content: content},
+	})
+	return err
+}
+
+// ListFiles returns the entries from the wrapped GitClient, with the files
+// that would have been created or deleted on the branch.
+func (c *Client) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	c.mu.Lock()
+	upstreamRef := ref
+	if b, planned := c.branches[key(repo, ref)]; planned {
+		upstreamRef = b.base
+	}
+	planned := map[string]*plannedFile{}
+	prefix := key(repo, ref, "")
+	for k, f := range c.files {
+		if strings.HasPrefix(k, prefix) {
+			planned[strings.TrimPrefix(k, prefix)] = f
+		}
+	}
+	c.mu.Unlock()
+
+	upstream, err := c.gitClient.ListFiles(ctx, c.upstreamRepo(repo), upstreamRef, dir, recursive)
+	if err != nil && !client.IsNotFound(err) {
+		return nil, err
+	}
+	files := []*client.FileInfo{}
+	listed := map[string]bool{}
+	add := func(f *client.FileInfo) {
+		if !listed[f.Path] {
+			listed[f.Path] = true
+			files = append(files, f)
+		}
+	}
+	for _, f := range upstream {
+		p, ok := planned[f.Path]
+		switch {
+		case ok && p.deleted:
+			continue
+		case ok:
+			add(&client.FileInfo{Path: f.Path, Sha: client.BlobSHA(p.data), Kind: f.Kind})
+		default:
+			add(f)
+		}
+	}
+
+	dir = strings.Trim(dir, "/")
+	paths := make([]string, 0, len(planned))
+	for path := range planned {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f := planned[path]
+		rel := path
+		if dir != "" {
+			if !strings.HasPrefix(path, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(path, dir+"/")
+		}
+		if f.deleted || listed[path] {
+			continue
+		}
+		parts := strings.Split(rel, "/")
+		for i := range parts[:len(parts)-1] {
+			if i > 0 && !recursive {
+				break
+			}
+			add(&client.FileInfo{Path: strings.TrimPrefix(dir+"/"+strings.Join(parts[:i+1], "/"), "/"), Kind: client.KindDirectory})
+		}
+		if recursive || len(parts) == 1 {
+			add(&client.FileInfo{Path: path, Sha: client.BlobSHA(f.data), Kind: client.KindFile})
+		}
+	}
+	if err != nil && len(files) == 0 {
+		return nil, err
+	}
+	return files, nil
+}
+
+// CommitFiles plans a commit with all the changes, and returns the SHA of the
+// planned commit.
+func (c *Client) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	return c.commit(ctx, repo, branch, message, signature, changes)
+}
+
+// CreatePullRequest plans a new PullRequest.
+func (c *Client) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	return c.CreatePullRequestWithOptions(ctx, repo, inp, client.PullRequestOptions{})
+}
+
+// CreatePullRequestWithOptions plans a new PullRequest, the labels are added
+// to the planned PullRequest, and the other options are ignored.
+func (c *Client) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	sourceRepo := repo
+	if opts.SourceRepo != "" {
+		sourceRepo = opts.SourceRepo
+	}
+	head, err := c.GetBranchHead(ctx, sourceRepo, inp.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request in repo %s: %w", repo, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	pr := &scm.PullRequest{
+		Number:  -(len(c.created[repo]) + 1),
+		Title:   inp.Title,
+		Body:    inp.Body,
+		Sha:     head,
+		Source:  inp.Source,
+		Target:  inp.Target,
+		Fork:    opts.SourceRepo,
+		Created: now,
+		Updated: now,
+	}
+	for _, label := range opts.Labels {
+		pr.Labels = append(pr.Labels, scm.Label{Name: label})
+	}
+	c.created[repo] = append(c.created[repo], pr.Number)
+	c.pullRequests[key(repo, fmt.Sprint(pr.Number))] = pr
+	c.record(Change{Action: ActionCreatePullRequest, Repo: repo, Branch: pr.Source, SHA: head, PullRequest: copyPR(pr)})
+	return copyPR(pr), nil
+}
+
+// FindPullRequest returns the planned PullRequest with the number, or the
+// PullRequest from the wrapped GitClient.
+func (c *Client) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	c.mu.Lock()
+	pr, ok := c.pullRequests[key(repo, fmt.Sprint(number))]
+	c.mu.Unlock()
+	if ok {
+		return copyPR(pr), nil
+	}
+	return c.gitClient.FindPullRequest(ctx, repo, number)
+}
+
+// ListPullRequests returns the PullRequests from the wrapped GitClient, with
+// any planned changes, the PullRequests that would have been created are
+// included in the first page.
+func (c *Client) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	prs, err := c.gitClient.ListPullRequests(ctx, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	matching := []*scm.PullRequest{}
+	matches := func(pr *scm.PullRequest) bool {
+		return (opts.Open && !pr.Closed) || (opts.Closed && pr.Closed) || (!opts.Open && !opts.Closed)
+	}
+	for _, pr := range prs {
+		if planned, ok := c.pullRequests[key(repo, fmt.Sprint(pr.Number))]; ok && !c.isCreated(repo, pr.Number) {
+			pr = copyPR(planned)
+		}
+		if matches(pr) {
+			matching = append(matching, pr)
+		}
+	}
+	if opts.Page <= 1 {
+		for _, n := range c.created[repo] {
+			if pr := c.pullRequests[key(repo, fmt.Sprint(n))]; matches(pr) {
+				matching = append(matching, copyPR(pr))
+			}
+		}
+	}
+	return matching, nil
+}
+
+// UpdatePullRequest plans a change to the title, body and target branch of a
+// PullRequest.
+func (c *Client) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	pr, err := c.FindPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	if inp.Target != "" {
+		pr.Target = inp.Target
+	}
+	pr.Title = inp.Title
+	pr.Body = inp.Body
+	pr.Updated = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pullRequests[key(repo, fmt.Sprint(number))] = pr
+	c.record(Change{Action: ActionUpdatePullRequest, Repo: repo, Branch: pr.Source, PullRequest: copyPR(pr)})
+	return copyPR(pr), nil
+}
+
+// MergePullRequest plans merging an open PullRequest, the Target branch is not
+// changed.
+func (c *Client) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	pr, err := c.FindPullRequest(ctx, repo, number)
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("failed to merge pull request %d in repo %s", number, repo)
+	if pr.Closed {
+		return client.SCMError{Msg: msg, Status: http.StatusMethodNotAllowed, ResponseMsg: "Pull Request is not mergeable"}
+	}
+	if opts.SHA != "" && opts.SHA != pr.Sha {
+		return client.SCMError{Msg: msg, Status: http.StatusConflict, ResponseMsg: "Head branch was modified"}
+	}
+	pr.Closed = true
+	pr.Merged = true
+	pr.Updated = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pullRequests[key(repo, fmt.Sprint(number))] = pr
+	c.record(Change{Action: ActionMergePullRequest, Repo: repo, Branch: pr.Target, SHA: pr.Sha, PullRequest: copyPR(pr)})
+	return nil
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (c *Client) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	return c.gitClient.ListStatuses(ctx, repo, ref)
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (c *Client) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	return c.gitClient.GetCombinedStatus(ctx, repo, ref)
+}
+
+// CreateBranch plans a new branch pointing at the commit sha, which can be a
+// planned commit.
+func (c *Client) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	if _, err := c.GetBranchHead(ctx, repo, branch); err == nil {
+		return client.SCMError{
+			Msg:         fmt.Sprintf("failed to create branch %s in repo %s", branch, repo),
+			Status:      http.StatusUnprocessableEntity,
+			ResponseMsg: "Reference already exists",
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &plannedBranch{base: sha, head: sha}
+	if commit, ok := c.commits[key(repo, sha)]; ok {
+		// the files of a planned commit are only known to the branch it would
+		// have been made on.
+		b.base = c.branches[key(repo, commit.branch)].base
+		prefix := key(repo, commit.branch, "")
+		for k, f := range c.files {
+			if strings.HasPrefix(k, prefix) {
+				c.files[key(repo, branch, strings.TrimPrefix(k, prefix))] = f
+			}
+		}
+	}
+	c.branches[key(repo, branch)] = b
+	delete(c.deletedBranches, key(repo, branch))
+	c.record(Change{Action: ActionCreateBranch, Repo: repo, Branch: branch, SHA: sha})
+	return nil
+}
+
+// GetBranchHead returns the planned head of the branch if it would have been
+// created or committed to, or the head from the wrapped GitClient.
+func (c *Client) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	c.mu.Lock()
+	b, ok := c.branches[key(repo, branch)]
+	deleted := c.deletedBranches[key(repo, branch)]
+	c.mu.Unlock()
+	switch {
+	case ok:
+		return b.head, nil
+	case deleted:
+		return "", notFound(fmt.Sprintf("branch %s not found in repo %s", branch, repo))
+	}
+	return c.gitClient.GetBranchHead(ctx, c.upstreamRepo(repo), branch)
+}
+
+// ListBranches returns the branches from the wrapped GitClient, with the
+// planned branches and heads.
+func (c *Client) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	refs, err := c.gitClient.ListBranches(ctx, c.upstreamRepo(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	branches := []*scm.Reference{}
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		k := key(repo, ref.Name)
+		if c.deletedBranches[k] {
+			continue
+		}
+		if b, ok := c.branches[k]; ok {
+			ref = &scm.Reference{Name: ref.Name, Path: ref.Path, Sha: b.head}
+		}
+		seen[k] = true
+		branches = append(branches, ref)
+	}
+	prefix := key(repo, "")
+	for k, b := range c.branches {
+		if strings.HasPrefix(k, prefix) && !seen[k] {
+			name := strings.TrimPrefix(k, prefix)
+			branches = append(branches, &scm.Reference{Name: name, Path: "refs/heads/" + name, Sha: b.head})
+		}
+	}
+	return branches, nil
+}
+
+// DeleteBranch plans deleting the branch.
+func (c *Client) DeleteBranch(ctx context.Context, repo, branch string) error {
+	if _, err := c.GetBranchHead(ctx, repo, branch); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.branches, key(repo, branch))
+	c.deletedBranches[key(repo, branch)] = true
+	c.record(Change{Action: ActionDeleteBranch, Repo: repo, Branch: branch})
+	return nil
+}
+
+// CreateFork plans a fork of the repository, which is named after the
+// repository in the owner, or in "dry-run" if the owner is empty, as the name
+// of the authenticated user is not known.
+//
+// Forks are always planned, even if the owner already has a fork.
+func (c *Client) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	if owner == "" {
+		owner = "dry-run"
+	}
+	fork := owner + "/" + repo[strings.LastIndex(repo, "/")+1:]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.forks[fork]; !ok {
+		c.forks[fork] = repo
+		c.record(Change{Action: ActionCreateFork, Repo: repo, Fork: fork})
+	}
+	return fork, nil
+}
+
+// SyncFork does nothing, as planned branches can be created from any commit.
+func (c *Client) SyncFork(ctx context.Context, fork, branch string) error {
+	return nil
+}
+
+// GetCommit returns the planned commit if the ref is a planned commit, or the
+// head of a branch that would have been committed to, or the commit from the
+// wrapped GitClient.
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	c.mu.Lock()
+	sha := ref
+	if b, ok := c.branches[key(repo, ref)]; ok {
+		sha = b.head
+	}
+	commit, ok := c.commits[key(repo, sha)]
+	c.mu.Unlock()
+	if ok {
+		cc := *commit.commit
+		return &cc, nil
+	}
+	return c.gitClient.GetCommit(ctx, c.upstreamRepo(repo), sha)
+}
+
+// commit plans a commit on the branch with the changes, and records the
+// changes to each file in the Plan.
+func (c *Client) commit(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	head, err := c.GetBranchHead(ctx, repo, branch)
+	if err != nil {
+		return "", err
+	}
+
+	planned := make([]Change, len(changes))
+	files := make([]*plannedFile, len(changes))
+	for i, change := range changes {
+		var before []byte
+		exists := true
+		current, err := c.GetFile(ctx, repo, branch, change.Path)
+		switch {
+		case client.IsNotFound(err):
+			exists = false
+		case err != nil:
+			return "", err
+		default:
+			before = current.Data
+		}
+
+		msg := fmt.Sprintf("failed to commit %s to branch %s in repo %s", change.Path, branch, repo)
+		p := Change{Repo: repo, Branch: branch, Path: change.Path, Message: message}
+		if change.Action != client.FileDelete {
+			p.Mode = change.Mode
+		}
+		if change.Action == client.FileMove {
+			if exists {
+				return "", client.SCMError{Msg: msg, Status: http.StatusUnprocessableEntity, ResponseMsg: "A file with this name already exists"}
+			}
+			previous, err := c.GetFile(ctx, repo, branch, change.PreviousPath)
+			if err != nil {
+				return "", err
+			}
+			content := change.Content
+			if content == nil {
+				content = previous.Data
+			}
+			p.Action = ActionMoveFile
+			p.PreviousPath = change.PreviousPath
+			p.Diff = diff.Unified("a/"+change.PreviousPath, "b/"+change.Path, previous.Data, content)
+			files[i] = &plannedFile{data: content}
+			planned[i] = p
+			continue
+		}
+		switch {
+		case change.Action == client.FileDelete && !exists:
+			return "", notFound(msg)
+		case change.Action == client.FileDelete:
+			p.Action = ActionDeleteFile
+			p.Diff = diff.Unified("a/"+change.Path, "/dev/null", before, nil)
+			files[i] = &plannedFile{deleted: true}
+		case change.Action == client.FileCreate && exists:
+			return "", client.SCMError{Msg: msg, Status: http.StatusUnprocessableEntity, ResponseMsg: "A file with this name already exists"}
+		case exists:
+			p.Action = ActionUpdateFile
+			p.Diff = diff.Unified("a/"+change.Path, "b/"+change.Path, before, change.Content)
+			files[i] = &plannedFile{data: change.Content}
+		default:
+			p.Action = ActionCreateFile
+			p.Diff = diff.Unified("/dev/null", "b/"+change.Path, nil, change.Content)
+			files[i] = &plannedFile{data: change.Content}
+		}
+		planned[i] = p
+	}
+
+	sha := commitSHA(head, message, changes)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.branches[key(repo, branch)]
+	if !ok {
+		b = &plannedBranch{base: branch}
+		c.branches[key(repo, branch)] = b
+	}
+	b.head = sha
+	for i, change := range changes {
+		if change.Action == client.FileMove {
+			c.files[key(repo, branch, change.PreviousPath)] = &plannedFile{deleted: true}
+		}
+		c.files[key(repo, branch, change.Path)] = files[i]
+		planned[i].SHA = sha
+		c.record(planned[i])
+	}
+	c.commits[key(repo, sha)] = &plannedCommit{
+		branch: branch,
+		commit: &scm.Commit{
+			Sha:       sha,
+			Message:   message,
+			Author:    signature,
+			Committer: signature,
+		},
+	}
+	return sha, nil
+}
+
+// isCreated returns true if the number is the placeholder number of a
+// PullRequest that would have been created, it must be called with the lock
+// held.
+func (c *Client) isCreated(repo string, number int) bool {
+	for _, n := range c.created[repo] {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamRepo returns the repository to read from the wrapped GitClient,
+// which is the forked repository for planned forks.
+func (c *Client) upstreamRepo(repo string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if upstream, ok := c.forks[repo]; ok {
+		return upstream
+	}
+	return repo
+}
+
+// record adds the change to the plan, it must be called with the lock held.
+func (c *Client) record(change Change) {
+	c.plan.Changes = append(c.plan.Changes, change)
+}
+
+func copyPR(pr *scm.PullRequest) *scm.PullRequest {
+	cp := *pr
+	cp.Labels = append([]scm.Label(nil), pr.Labels...)
+	return &cp
+}
+
+// commitSHA returns a SHA that identifies a planned commit, it is not the SHA
+// the commit would have if it was made.
+func commitSHA(parent, message string, changes []client.FileChange) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "parent %s\n%s\n", parent, message)
+	for _, change := range changes {
+		fmt.Fprintf(h, "%d %s %x\n", change.Action, change.Path, change.Content)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func notFound(msg string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusNotFound, ResponseMsg: "Not Found"}
+}
+
+func key(s ...string) string {
+	return strings.Join(s, ":")
+}
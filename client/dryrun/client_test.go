@@ -0,0 +1,303 @@
+package dryrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/ocraviotto/go-scm/scm"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/updater"
+)
+
+const (
+	testRepo = "testorg/testrepo"
+	testSHA  = "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+)
+
+func TestUpdateFileToNewBranch(t *testing.T) {
+	m := newMock(t)
+	c := New(m)
+	ctx := context.Background()
+
+	if err := c.CreateBranch(ctx, testRepo, "test-branch", testSHA); err != nil {
+		t.Fatal(err)
+	}
+	err := c.UpdateFile(ctx, testRepo, "test-branch", "README.md", "updating", "", scm.Signature{}, []byte("new content\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := c.GetFile(ctx, testRepo, "test-branch", "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(content.Data); s != "new content\n" {
+		t.Fatalf("got %q, want the planned content", s)
+	}
+	head, err := c.GetBranchHead(ctx, testRepo, "test-branch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == testSHA {
+		t.Fatal("head of the branch was not updated")
+	}
+	m.RefuteBranchCreated(testRepo, "test-branch", testSHA)
+	if b := m.GetUpdatedContents(testRepo, "README.md", "test-branch"); b != nil {
+		t.Fatalf("file was updated: %q", b)
+	}
+
+	want := []Change{
+		{Action: ActionCreateBranch, Repo: testRepo, Branch: "test-branch", SHA: testSHA},
+		{
+			Action: ActionUpdateFile, Repo: testRepo, Branch: "test-branch", SHA: head, Path: "README.md", Message: "updating",
+			Diff: "--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-old content\n+new content\n",
+		},
+	}
+	if diff := cmp.Diff(want, c.Plan().Changes); diff != "" {
+		t.Fatalf("incorrect plan:\n%s", diff)
+	}
+}
+
+func TestCommitFilesReadsPlannedChanges(t *testing.T) {
+	c := New(newMock(t))
+	ctx := context.Background()
+
+	_, err := c.CommitFiles(ctx, testRepo, "main", "creating", scm.Signature{}, []client.FileChange{
+		{Action: client.FileCreate, Path: "new.md", Content: []byte("testing\n")},
+		{Action: client.FileDelete, Path: "README.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetFile(ctx, testRepo, "main", "README.md"); !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error for the deleted file", err)
+	}
+	_, err = c.CommitFiles(ctx, testRepo, "main", "creating", scm.Signature{}, []client.FileChange{
+		{Action: client.FileCreate, Path: "new.md", Content: []byte("testing\n")},
+	})
+	if !client.IsValidation(err) {
+		t.Fatalf("got %v, want a validation error for the existing file", err)
+	}
+	want := []Action{ActionCreateFile, ActionDeleteFile}
+	if diff := cmp.Diff(want, actions(c.Plan())); diff != "" {
+		t.Fatalf("incorrect plan:\n%s", diff)
+	}
+}
+
+func TestCommitFilesWithMode(t *testing.T) {
+	c := New(newMock(t))
+
+	_, err := c.CommitFiles(context.Background(), testRepo, "main", "creating", scm.Signature{}, []client.FileChange{
+		{Action: client.FileCreate, Path: "run.sh", Content: []byte("#!/bin/sh\n"), Mode: client.ModeExecutable},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "create-file testorg/testrepo run.sh in main with mode 100755\n"
+	if s := c.Plan().String(); !strings.HasPrefix(s, want) {
+		t.Fatalf("got plan %q, want it to start with %q", s, want)
+	}
+}
+
+func TestListFilesIncludesPlannedChanges(t *testing.T) {
+	c := New(newMock(t))
+	ctx := context.Background()
+	_, err := c.CommitFiles(ctx, testRepo, "main", "creating", scm.Signature{}, []client.FileChange{
+		{Action: client.FileCreate, Path: "docs/new.md", Content: []byte("testing\n")},
+		{Action: client.FileDelete, Path: "README.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := c.ListFiles(ctx, testRepo, "main", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*client.FileInfo{
+		{Path: "docs", Kind: client.KindDirectory},
+		{Path: "docs/new.md", Sha: client.BlobSHA([]byte("testing\n")), Kind: client.KindFile},
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("incorrect files:\n%s", diff)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	c := New(newMock(t))
+	ctx := context.Background()
+
+	if err := c.MoveFile(ctx, testRepo, "main", "README.md", "docs/README.md", "moving", "", scm.Signature{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetFile(ctx, testRepo, "main", "README.md"); !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error for the moved file", err)
+	}
+	content, err := c.GetFile(ctx, testRepo, "main", "docs/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(content.Data); s != "old content\n" {
+		t.Fatalf("got %q, want the moved content", s)
+	}
+	want := "move-file testorg/testrepo README.md -> docs/README.md in main\n"
+	if s := c.Plan().String(); s != want {
+		t.Fatalf("got plan %q, want %q", s, want)
+	}
+}
+
+func TestUpdateFileInPlannedFork(t *testing.T) {
+	m := newMock(t)
+	c := New(m)
+	ctx := context.Background()
+
+	fork, err := c.CreateFork(ctx, testRepo, "octo-bots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != "octo-bots/testrepo" {
+		t.Fatalf("got fork %s, want octo-bots/testrepo", fork)
+	}
+	if err := c.CreateBranch(ctx, fork, "test-branch", testSHA); err != nil {
+		t.Fatal(err)
+	}
+	err = c.UpdateFile(ctx, fork, "test-branch", "README.md", "updating", "", scm.Signature{}, []byte("new content\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CreatePullRequestWithOptions(ctx, testRepo, &scm.PullRequestInput{Title: "Test", Source: "test-branch", Target: "main"},
+		client.PullRequestOptions{SourceRepo: fork})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]Action{ActionCreateFork, ActionCreateBranch, ActionUpdateFile, ActionCreatePullRequest}, actions(c.Plan())); diff != "" {
+		t.Fatalf("incorrect plan:\n%s", diff)
+	}
+	if pr := c.Plan().Changes[3].PullRequest; pr.Fork != fork {
+		t.Fatalf("got pull request from %s, want %s", pr.Fork, fork)
+	}
+	m.AssertNoInteractions()
+}
+
+func TestCreateBranchThatExists(t *testing.T) {
+	c := New(newMock(t))
+
+	err := c.CreateBranch(context.Background(), testRepo, "main", testSHA)
+
+	if !client.IsValidation(err) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	if !c.Plan().IsEmpty() {
+		t.Fatalf("got changes %v", c.Plan().Changes)
+	}
+}
+
+func TestPullRequests(t *testing.T) {
+	m := newMock(t)
+	m.AddPullRequest(testRepo, &scm.PullRequest{Number: 1, Title: "existing", Source: "other", Target: "main"})
+	c := New(m)
+	ctx := context.Background()
+	inp := &scm.PullRequestInput{Title: "testing", Source: "main", Target: "release"}
+
+	pr, err := c.CreatePullRequest(ctx, testRepo, inp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RefutePullRequestCreated(testRepo, inp)
+	if pr.Number != -1 {
+		t.Fatalf("got number %d, want a placeholder number", pr.Number)
+	}
+	if _, err := c.UpdatePullRequest(ctx, testRepo, 1, &scm.PullRequestInput{Title: "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MergePullRequest(ctx, testRepo, pr.Number, client.MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	prs, err := c.ListPullRequests(ctx, testRepo, scm.PullRequestListOptions{Page: 1, Size: 100, Open: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*scm.PullRequest{{Number: 1, Title: "updated", Source: "other", Target: "main"}}
+	if diff := cmp.Diff(want, prs, cmpopts.IgnoreFields(scm.PullRequest{}, "Updated")); diff != "" {
+		t.Fatalf("incorrect pull requests:\n%s", diff)
+	}
+	wantActions := []Action{ActionCreatePullRequest, ActionUpdatePullRequest, ActionMergePullRequest}
+	if diff := cmp.Diff(wantActions, actions(c.Plan())); diff != "" {
+		t.Fatalf("incorrect plan:\n%s", diff)
+	}
+}
+
+func TestPlanWithUpdater(t *testing.T) {
+	m := newMock(t)
+	c := New(m)
+	u := updater.New(zap.New(), c, updater.NameGenerator(stubNameGenerator{"a"}))
+	ctx := context.Background()
+
+	branch, err := u.ApplyUpdateToFile(ctx, updater.CommitInput{
+		Repo:               testRepo,
+		Filename:           "README.md",
+		Branch:             "main",
+		BranchGenerateName: "test-branch-",
+		CommitMessage:      "updating",
+	}, updater.ReplaceContents([]byte("new content\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := u.CreatePR(ctx, updater.PullRequestInput{Repo: testRepo, Title: "testing", NewBranch: branch, SourceBranch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertCommitCount(0)
+	want := `create-branch testorg/testrepo test-branch-a from 980a0d5f19a64b4b30a87d4206aade58726b60e3
+update-file testorg/testrepo README.md in test-branch-a
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old content
++new content
+create-pull-request testorg/testrepo #-1 test-branch-a -> main: testing
+`
+	if diff := cmp.Diff(want, c.Plan().String()); diff != "" {
+		t.Fatalf("incorrect plan:\n%s", diff)
+	}
+	if pr.Sha != c.Plan().Changes[1].SHA {
+		t.Fatalf("got PullRequest head %s, want the planned commit", pr.Sha)
+	}
+}
+
+func newMock(t *testing.T) *mock.MockClient {
+	m := mock.New(t)
+	m.AddBranchHead(testRepo, "main", testSHA)
+	m.AddFileContents(testRepo, "README.md", "main", []byte("old content\n"))
+	m.AddFileContents(testRepo, "README.md", testSHA, []byte("old content\n"))
+	return m
+}
+
+func actions(p *Plan) []Action {
+	var a []Action
+	for _, c := range p.Changes {
+		a = append(a, c.Action)
+	}
+	return a
+}
+
+type stubNameGenerator struct {
+	name string
+}
+
+func (s stubNameGenerator) PrefixedName(p string) string {
+	return p + s.name
+}
@@ -0,0 +1,101 @@
+package dryrun
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+// Action identifies the kind of change in a Plan.
+type Action string
+
+const (
+	// ActionCreateBranch is used when a branch would be created.
+	ActionCreateBranch Action = "create-branch"
+	// ActionCreateFork is used when a repository would be forked.
+	ActionCreateFork Action = "create-fork"
+	// ActionDeleteBranch is used when a branch would be deleted.
+	ActionDeleteBranch Action = "delete-branch"
+	// ActionCreateFile is used when a missing file would be created.
+	ActionCreateFile Action = "create-file"
+	// ActionUpdateFile is used when an existing file would be changed.
+	ActionUpdateFile Action = "update-file"
+	// ActionDeleteFile is used when a file would be deleted.
+	ActionDeleteFile Action = "delete-file"
+	// ActionMoveFile is used when a file would be moved.
+	ActionMoveFile Action = "move-file"
+	// ActionCreatePullRequest is used when a PullRequest would be opened.
+	ActionCreatePullRequest Action = "create-pull-request"
+	// ActionUpdatePullRequest is used when a PullRequest would be changed.
+	ActionUpdatePullRequest Action = "update-pull-request"
+	// ActionMergePullRequest is used when a PullRequest would be merged.
+	ActionMergePullRequest Action = "merge-pull-request"
+)
+
+// Change is a single change that would have been made to a repository.
+type Change struct {
+	Action Action
+	Repo   string // e.g. my-org/my-repo
+	Branch string // the branch that is created or deleted, or that files are committed to
+	SHA    string // the commit a branch is created from, or the planned commit for file changes
+	Fork   string // the name of the fork, for forks
+
+	Path         string          // the file that is changed, for file changes
+	PreviousPath string          // the path the file is moved from, for moves
+	Mode         client.FileMode // the mode that is set, for file changes that set one
+	Message      string          // the commit message, for file changes
+	Diff         string          // a unified diff of the change, for file changes
+
+	// PullRequest is the PullRequest that would be created, updated or
+	// merged, PullRequests that would be created have negative placeholder
+	// numbers, see Client.
+	PullRequest *scm.PullRequest
+}
+
+// Plan is the list of changes that would have been made, in the order that
+// they were requested.
+type Plan struct {
+	Changes []Change
+}
+
+// IsEmpty returns true if no changes would have been made.
+func (p *Plan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns a description of each change, followed by the diffs of the
+// file changes, e.g. for a comment on a PullRequest.
+func (p *Plan) String() string {
+	var out strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintf(&out, "%s %s", c.Action, c.Repo)
+		switch c.Action {
+		case ActionCreateBranch:
+			fmt.Fprintf(&out, " %s from %s\n", c.Branch, c.SHA)
+		case ActionDeleteBranch:
+			fmt.Fprintf(&out, " %s\n", c.Branch)
+		case ActionCreateFork:
+			fmt.Fprintf(&out, " as %s\n", c.Fork)
+		case ActionCreatePullRequest, ActionUpdatePullRequest, ActionMergePullRequest:
+			pr := c.PullRequest
+			fmt.Fprintf(&out, " #%d %s -> %s: %s\n", pr.Number, pr.Source, pr.Target, pr.Title)
+		case ActionMoveFile:
+			fmt.Fprintf(&out, " %s -> %s in %s%s\n", c.PreviousPath, c.Path, c.Branch, modeSuffix(c.Mode))
+			out.WriteString(c.Diff)
+		default:
+			fmt.Fprintf(&out, " %s in %s%s\n", c.Path, c.Branch, modeSuffix(c.Mode))
+			out.WriteString(c.Diff)
+		}
+	}
+	return out.String()
+}
+
+func modeSuffix(m client.FileMode) string {
+	if m == "" {
+		return ""
+	}
+	return " with mode " + string(m)
+}
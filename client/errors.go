@@ -1,23 +1,138 @@
 package client
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
 )
 
+// staleMessages are fragments of the error messages returned by upstream
+// services that do not use a 409 status when a change is made against an
+// out-of-date version of a file or branch.
+var staleMessages = []string{
+	"does not match",     // GitHub and Gitea contents API
+	"not a fast forward", // GitHub refs API
+	"has changed since",  // GitLab files and commits API
+}
+
 // IsNotFound returns true if the error represents a NotFound response from an
 // upstream service.
 func IsNotFound(err error) bool {
-	e, ok := err.(SCMError)
-	return ok && e.Status == http.StatusNotFound
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized returns true if the error represents a request that was
+// rejected because the credentials are missing or invalid.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || errors.Is(err, scm.ErrNotAuthorized)
+}
+
+// IsForbidden returns true if the error represents a request that was rejected
+// because the credentials don't grant access to the resource.
+//
+// Requests that are rejected because of rate limiting are not forbidden, see
+// IsRateLimited.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden) && !IsRateLimited(err)
+}
+
+// IsRateLimited returns true if the error represents a request that was
+// rejected because the rate limit of the upstream service was exceeded.
+//
+// GitHub uses a 403 status for exceeded rate limits, which are identified by
+// the message.
+func IsRateLimited(err error) bool {
+	var e SCMError
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		msg := strings.ToLower(e.ResponseMsg)
+		if strings.Contains(msg, "rate limit") {
+			return true
+		}
+		for _, s := range rateLimitMessages {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsValidation returns true if the error represents a request that was
+// rejected because the input is invalid, e.g. because a branch already exists.
+//
+// The FieldErrors of the SCMError identify the invalid fields, if the upstream
+// service provides them.
+//
+// Conflicts are not validation errors, see IsConflict.
+func IsValidation(err error) bool {
+	return (hasStatus(err, http.StatusUnprocessableEntity) || hasStatus(err, http.StatusBadRequest)) && !IsConflict(err)
+}
+
+// IsNotSupported returns true if the error represents an operation that is not
+// supported by the driver.
+func IsNotSupported(err error) bool {
+	return errors.Is(err, scm.ErrNotSupported) || hasStatus(err, http.StatusNotImplemented)
+}
+
+func hasStatus(err error, status int) bool {
+	var e SCMError
+	return errors.As(err, &e) && e.Status == status
+}
+
+// IsConflict returns true if the error represents a change that was rejected
+// because it was based on an out-of-date version of a file or branch, e.g.
+// because the previousSHA passed to UpdateFile is stale.
+func IsConflict(err error) bool {
+	var e SCMError
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Status {
+	case http.StatusConflict:
+		return true
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		msg := strings.ToLower(e.ResponseMsg)
+		for _, s := range staleMessages {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
+// SCMError is an HTTP error response from an upstream service.
 type SCMError struct {
 	Msg         string
 	Status      int
-	ResponseMsg string
+	ResponseMsg string // the body of the response, or the error from go-scm if the body was already read
+
+	// Message is the description of the error parsed from the response, and
+	// FieldErrors are the errors for individual fields of the request, if the
+	// upstream service provides them.
+	Message     string
+	FieldErrors []FieldError
+}
+
+// FieldError is an error for a single field of a request.
+type FieldError struct {
+	Resource string
+	Field    string
+	Code     string
+	Message  string
 }
 
 func (s SCMError) Error() string {
@@ -38,5 +153,108 @@ func newSCMError(msg string, status int, body io.ReadCloser) SCMError {
 	}
 
 	e.ResponseMsg = string(bytes)
+	e.Message, e.FieldErrors = parseErrorBody(bytes)
 	return e
 }
+
+// responseError returns an SCMError if the response from go-scm has an error
+// status, and otherwise the err.
+//
+// go-scm reads the body of error responses, and returns the message it parsed
+// from it as err, which is used as the ResponseMsg in that case.
+func responseError(msg string, r *scm.Response, err error) error {
+	if r == nil || !isErrorStatus(r.Status) {
+		return err
+	}
+	e := newSCMError(msg, r.Status, r.Body)
+	if e.ResponseMsg == "" && err != nil {
+		e.ResponseMsg = err.Error()
+		e.Message = err.Error()
+	}
+	return e
+}
+
+// errorBody is the union of the error response formats of the supported
+// upstream services.
+type errorBody struct {
+	// GitHub, GitLab and Gitea use a message, which GitLab uses for field
+	// errors as an object with a list of errors per field.
+	Message json.RawMessage `json:"message"`
+	// GitLab uses error for some errors, Bitbucket Cloud uses an object with a
+	// message and the errors per field.
+	Error json.RawMessage `json:"error"`
+	// GitHub and Bitbucket Server use a list of errors.
+	Errors []struct {
+		Resource string `json:"resource"`
+		Field    string `json:"field"`
+		Code     string `json:"code"`
+		Message  string `json:"message"`
+		Context  string `json:"context"`
+	} `json:"errors"`
+}
+
+// parseErrorBody parses an error response body from an upstream service into
+// a message, and the errors for individual fields.
+func parseErrorBody(b []byte) (string, []FieldError) {
+	var body errorBody
+	if err := json.Unmarshal(b, &body); err != nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	var message string
+	var fieldErrors []FieldError
+	for _, e := range body.Errors {
+		field := e.Field
+		if field == "" {
+			field = e.Context
+		}
+		fieldErrors = append(fieldErrors, FieldError{Resource: e.Resource, Field: field, Code: e.Code, Message: e.Message})
+	}
+	for _, raw := range []json.RawMessage{body.Message, body.Error} {
+		if len(raw) == 0 || message != "" {
+			continue
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			message = s
+			continue
+		}
+		var nested struct {
+			Message string              `json:"message"`
+			Fields  map[string][]string `json:"fields"`
+		}
+		if json.Unmarshal(raw, &nested) == nil && (nested.Message != "" || len(nested.Fields) > 0) {
+			message = nested.Message
+			fieldErrors = append(fieldErrors, fieldErrorsFromMap(nested.Fields)...)
+			continue
+		}
+		var fields map[string][]string
+		if json.Unmarshal(raw, &fields) == nil {
+			fieldErrors = append(fieldErrors, fieldErrorsFromMap(fields)...)
+		}
+	}
+	if message == "" && len(fieldErrors) > 0 {
+		message = fieldErrors[0].Message
+		if message == "" && fieldErrors[0].Code != "" {
+			message = fmt.Sprintf("%s is %s", fieldErrors[0].Field, fieldErrors[0].Code)
+		}
+	}
+	return message, fieldErrors
+}
+
+// fieldErrorsFromMap converts GitLab style errors, where the messages are
+// listed by field name.
+func fieldErrorsFromMap(fields map[string][]string) []FieldError {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var fieldErrors []FieldError
+	for _, name := range names {
+		for _, msg := range fields[name] {
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: msg})
+		}
+	}
+	return fieldErrors
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+func TestIsConflict(t *testing.T) {
+	conflictTests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"conflict status", SCMError{Status: http.StatusConflict}, true},
+		{"wrapped conflict status", fmt.Errorf("failed to update file: %w", SCMError{Status: http.StatusConflict}), true},
+		{"github stale sha", SCMError{Status: http.StatusUnprocessableEntity, ResponseMsg: `{"message":"README.md does not match 6113728f27ae82c7b1a177c8d03f9e96e0adf246"}`}, true},
+		{"github non fast-forward", SCMError{Status: http.StatusUnprocessableEntity, ResponseMsg: `{"message":"Update is not a fast forward"}`}, true},
+		{"gitlab stale commit", SCMError{Status: http.StatusBadRequest, ResponseMsg: `{"message":"You are attempting to update a file that has changed since you started editing it."}`}, true},
+		{"validation failure", SCMError{Status: http.StatusUnprocessableEntity, ResponseMsg: `{"message":"Invalid request."}`}, false},
+		{"not found", SCMError{Status: http.StatusNotFound}, false},
+		{"other error", errors.New("conflict"), false},
+		{"nil error", nil, false},
+	}
+
+	for _, tt := range conflictTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			if got := IsConflict(tt.err); got != tt.want {
+				rt.Errorf("IsConflict(%v) got %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorClassifiers(t *testing.T) {
+	rateLimited := SCMError{Status: http.StatusForbidden, ResponseMsg: `{"message":"API rate limit exceeded for 127.0.0.1."}`}
+	classifierTests := []struct {
+		name       string
+		classifier func(error) bool
+		err        error
+		want       bool
+	}{
+		{"not found", IsNotFound, SCMError{Status: http.StatusNotFound}, true},
+		{"wrapped not found", IsNotFound, fmt.Errorf("failed to update file: %w", SCMError{Status: http.StatusNotFound}), true},
+		{"not found other status", IsNotFound, SCMError{Status: http.StatusInternalServerError}, false},
+		{"unauthorized", IsUnauthorized, SCMError{Status: http.StatusUnauthorized}, true},
+		{"unauthorized go-scm error", IsUnauthorized, fmt.Errorf("failed: %w", scm.ErrNotAuthorized), true},
+		{"forbidden", IsForbidden, SCMError{Status: http.StatusForbidden}, true},
+		{"forbidden rate limited", IsForbidden, rateLimited, false},
+		{"rate limited with 403", IsRateLimited, rateLimited, true},
+		{"rate limited with 429", IsRateLimited, SCMError{Status: http.StatusTooManyRequests}, true},
+		{"rate limited forbidden", IsRateLimited, SCMError{Status: http.StatusForbidden}, false},
+		{"validation", IsValidation, SCMError{Status: http.StatusUnprocessableEntity, ResponseMsg: `{"message":"Reference already exists"}`}, true},
+		{"validation with 400", IsValidation, SCMError{Status: http.StatusBadRequest}, true},
+		{"validation conflict", IsValidation, SCMError{Status: http.StatusUnprocessableEntity, ResponseMsg: `{"message":"Update is not a fast forward"}`}, false},
+		{"not supported", IsNotSupported, fmt.Errorf("committing multiple files with driver stash: %w", scm.ErrNotSupported), true},
+		{"not supported other error", IsNotSupported, errors.New("not supported"), false},
+		{"nil error", IsNotFound, nil, false},
+	}
+
+	for _, tt := range classifierTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			if got := tt.classifier(tt.err); got != tt.want {
+				rt.Errorf("got %v for %v, want %v", got, tt.err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorBody(t *testing.T) {
+	parseTests := []struct {
+		name        string
+		body        string
+		wantMessage string
+		wantErrors  []FieldError
+	}{
+		{"github", `{"message":"Validation Failed","errors":[{"resource":"PullRequest","field":"head","code":"invalid"}]}`,
+			"Validation Failed", []FieldError{{Resource: "PullRequest", Field: "head", Code: "invalid"}}},
+		{"gitlab message", `{"message":"404 Project Not Found"}`, "404 Project Not Found", nil},
+		{"gitlab field errors", `{"message":{"title":["can't be blank"],"description":["is too long"]}}`,
+			"is too long", []FieldError{{Field: "description", Message: "is too long"}, {Field: "title", Message: "can't be blank"}}},
+		{"gitlab error", `{"error":"branch is missing"}`, "branch is missing", nil},
+		{"bitbucket cloud", `{"type":"error","error":{"message":"Bad request","fields":{"source":["source is required"]}}}`,
+			"Bad request", []FieldError{{Field: "source", Message: "source is required"}}},
+		{"bitbucket server", `{"errors":[{"context":"name","message":"Branch name is invalid"}]}`,
+			"Branch name is invalid", []FieldError{{Field: "name", Message: "Branch name is invalid"}}},
+		{"plain text", "Internal Server Error\n", "Internal Server Error", nil},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			message, fieldErrors := parseErrorBody([]byte(tt.body))
+			if message != tt.wantMessage {
+				rt.Errorf("got message %q, want %q", message, tt.wantMessage)
+			}
+			if diff := cmp.Diff(tt.wantErrors, fieldErrors); diff != "" {
+				rt.Errorf("incorrect field errors:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const treePageSize = 100
+
+// FileKind identifies the kind of an entry in a directory.
+type FileKind string
+
+const (
+	// KindFile is a regular or executable file.
+	KindFile FileKind = "file"
+	// KindDirectory is a directory.
+	KindDirectory FileKind = "dir"
+	// KindSymlink is a symbolic link.
+	KindSymlink FileKind = "symlink"
+	// KindSubmodule is a submodule, which refers to a commit in another
+	// repository.
+	KindSubmodule FileKind = "submodule"
+)
+
+// FileInfo describes an entry in a directory, as returned by ListFiles.
+type FileInfo struct {
+	Path string // relative path to the entry in the repository
+	Sha  string // the SHA of the blob, tree or submodule commit
+	Kind FileKind
+}
+
+// ListFiles returns the entries in the directory at the ref, which include its
+// subdirectories, and if recursive is true, the entries in all of those
+// subdirectories too. The directory is relative to the root of the repository,
+// which is listed if it is empty.
+//
+// This is supported for GitHub and GitLab (using the tree APIs), other drivers
+// return an error wrapping scm.ErrNotSupported.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned, if the directory does not exist, this is
+// a 404.
+func (c *SCMClient) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*FileInfo, error) {
+	dir = strings.Trim(dir, "/")
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		return c.listFilesGitHub(ctx, repo, ref, dir, recursive)
+	case scm.DriverGitlab:
+		return c.listFilesGitLab(ctx, repo, ref, dir, recursive)
+	}
+	return nil, fmt.Errorf("listing files with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+}
+
+type ghTree struct {
+	Tree      []ghTreeEntry `json:"tree"`
+	Truncated bool          `json:"truncated"`
+}
+
+type ghTreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Sha  string `json:"sha"`
+}
+
+// listFilesGitHub lists the tree of the directory, which is found by walking
+// the trees of its parent directories, and the trees of its subdirectories if
+// recursive is true, as the recursive tree of the ref is truncated for large
+// repositories, see treeEntriesGitHub.
+func (c *SCMClient) listFilesGitHub(ctx context.Context, repo, ref, dir string, recursive bool) ([]*FileInfo, error) {
+	trees := map[string][]ghTreeEntry{}
+	root := url.PathEscape(ref)
+	if dir != "" {
+		parent, name := path.Split(dir)
+		entries, err := c.treeEntriesGitHub(ctx, repo, root, strings.TrimSuffix(parent, "/"), trees)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Path == name && entry.Type == "tree" {
+				found = true
+			}
+		}
+		if !found {
+			msg := fmt.Sprintf("failed to list files in %s in repo %s ref %s", dir, repo, ref)
+			return nil, SCMError{Msg: msg, Status: http.StatusNotFound, ResponseMsg: "Not Found"}
+		}
+	}
+	return c.listTreeGitHub(ctx, repo, root, dir, recursive, trees, []*FileInfo{})
+}
+
+func (c *SCMClient) listTreeGitHub(ctx context.Context, repo, root, dir string, recursive bool, trees map[string][]ghTreeEntry, files []*FileInfo) ([]*FileInfo, error) {
+	entries, err := c.treeEntriesGitHub(ctx, repo, root, dir, trees)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Path)
+		files = append(files, &FileInfo{Path: entryPath, Sha: entry.Sha, Kind: treeEntryKind(entry.Type, entry.Mode)})
+		if recursive && entry.Type == "tree" {
+			if files, err = c.listTreeGitHub(ctx, repo, root, entryPath, recursive, trees, files); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+type glTreeEntry struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+}
+
+func (c *SCMClient) listFilesGitLab(ctx context.Context, repo, ref, dir string, recursive bool) ([]*FileInfo, error) {
+	msg := fmt.Sprintf("failed to list files in %s in repo %s ref %s", dir, repo, ref)
+	files := []*FileInfo{}
+	for page := 1; ; page++ {
+		entries := []glTreeEntry{}
+		_, err := c.do(ctx, msg, "GET", fmt.Sprintf("api/v4/projects/%s/repository/tree?path=%s&ref=%s&recursive=%t&per_page=%d&page=%d",
+			encodeGitLabRepo(repo), url.QueryEscape(dir), url.QueryEscape(ref), recursive, treePageSize, page), nil, &entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			files = append(files, &FileInfo{Path: entry.Path, Sha: entry.ID, Kind: treeEntryKind(entry.Type, entry.Mode)})
+		}
+		if len(entries) < treePageSize {
+			return files, nil
+		}
+	}
+}
+
+// treeEntryKind returns the kind of a Git tree entry from its object type and
+// mode.
+func treeEntryKind(objectType, mode string) FileKind {
+	switch {
+	case objectType == "tree":
+		return KindDirectory
+	case objectType == "commit":
+		return KindSubmodule
+	case mode == "120000":
+		return KindSymlink
+	}
+	return KindFile
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/go-scm/scm/factory"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestListFilesInGitHub(t *testing.T) {
+	listTests := []struct {
+		dir       string
+		recursive bool
+		want      []*FileInfo
+	}{
+		{"envs", false, []*FileInfo{
+			{Path: "envs/dev", Sha: "4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4", Kind: KindDirectory},
+			{Path: "envs/latest", Sha: "2e65efe2a145dda7ee51d1741299f848e5bf752e", Kind: KindSymlink},
+			{Path: "envs/vendor", Sha: "6dcb09b5b57875f334f61aebed695e2e4193db5e", Kind: KindSubmodule},
+		}},
+		{"/envs/dev/", true, []*FileInfo{
+			{Path: "envs/dev/values.yaml", Sha: "5f3b8a1d9c1a6bb6b8e0b5f0a4c0c5d6e7f8a9b0", Kind: KindFile},
+		}},
+		{"envs", true, []*FileInfo{
+			{Path: "envs/dev", Sha: "4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4", Kind: KindDirectory},
+			{Path: "envs/dev/values.yaml", Sha: "5f3b8a1d9c1a6bb6b8e0b5f0a4c0c5d6e7f8a9b0", Kind: KindFile},
+			{Path: "envs/latest", Sha: "2e65efe2a145dda7ee51d1741299f848e5bf752e", Kind: KindSymlink},
+			{Path: "envs/vendor", Sha: "6dcb09b5b57875f334f61aebed695e2e4193db5e", Kind: KindSubmodule},
+		}},
+		{"", false, []*FileInfo{
+			{Path: "README.md", Sha: "980a0d5f19a64b4b30a87d4206aade58726b60e3", Kind: KindFile},
+			{Path: "envs", Sha: "f484d249c660418515fb01c2b9662073663c242e", Kind: KindDirectory},
+		}},
+	}
+
+	for _, tt := range listTests {
+		t.Run(tt.dir, func(rt *testing.T) {
+			mockGitHubListTrees()
+			defer gock.Off()
+			scmClient, err := factory.NewClient("github", "", "")
+			if err != nil {
+				rt.Fatal(err)
+			}
+			client := New(scmClient)
+
+			files, err := client.ListFiles(context.Background(), "Codertocat/Hello-World", "master", tt.dir, tt.recursive)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, files); diff != "" {
+				rt.Fatalf("incorrect files:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListFilesInGitHubWithMissingDirectory(t *testing.T) {
+	mockGitHubListTrees()
+	defer gock.Off()
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.ListFiles(context.Background(), "Codertocat/Hello-World", "master", "envs/unknown", false)
+
+	if !IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestListFilesInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/repository/tree").
+		MatchParams(map[string]string{"path": "envs", "ref": "master", "recursive": "true", "page": "1"}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[
+			{"id": "4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4", "name": "dev", "type": "tree", "path": "envs/dev", "mode": "040000"},
+			{"id": "5f3b8a1d9c1a6bb6b8e0b5f0a4c0c5d6e7f8a9b0", "name": "values.yaml", "type": "blob", "path": "envs/dev/values.yaml", "mode": "100644"}
+		]`)
+	defer gock.Off()
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	files, err := client.ListFiles(context.Background(), "Codertocat/Hello-World", "master", "envs", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*FileInfo{
+		{Path: "envs/dev", Sha: "4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4", Kind: KindDirectory},
+		{Path: "envs/dev/values.yaml", Sha: "5f3b8a1d9c1a6bb6b8e0b5f0a4c0c5d6e7f8a9b0", Kind: KindFile},
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("incorrect files:\n%s", diff)
+	}
+}
+
+func TestListFilesWithUnsupportedDriver(t *testing.T) {
+	scmClient, err := factory.NewClient("bitbucket", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.ListFiles(context.Background(), "Codertocat/Hello-World", "master", "", false)
+
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+	}
+}
+
+// mockGitHubListTrees mocks the tree of each directory in the repository,
+// without the recursive trees, which are truncated for large repositories.
+func mockGitHubListTrees() {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/master").
+		Persist().
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_tree.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/f484d249c660418515fb01c2b9662073663c242e").
+		Persist().
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"tree": [
+			{"path": "dev", "mode": "040000", "type": "tree", "sha": "4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4"},
+			{"path": "latest", "mode": "120000", "type": "blob", "sha": "2e65efe2a145dda7ee51d1741299f848e5bf752e"},
+			{"path": "vendor", "mode": "160000", "type": "commit", "sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}
+		]}`)
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/trees/4c1b5d4e8a4d8a3c5fb1f3c1c4e0c4e1b1c2d3e4").
+		Persist().
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"tree": [{"path": "values.yaml", "mode": "100644", "type": "blob", "sha": "5f3b8a1d9c1a6bb6b8e0b5f0a4c0c5d6e7f8a9b0"}]}`)
+}
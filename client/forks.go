@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const forkPageSize = 100
+
+// CreateFork creates a fork of the repository owned by the owner, which is an
+// organization in GitHub or a namespace in GitLab, or by the authenticated
+// user if the owner is empty, and returns the name of the fork, e.g.
+// my-user/my-repo.
+//
+// If the owner already has a fork of the repository, it is not changed, and
+// its name is returned.
+//
+// Forks are created asynchronously, so the fork may have no branches for a
+// short time after it's created.
+//
+// This is supported for GitHub and GitLab, other drivers return an error
+// wrapping scm.ErrNotSupported.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		return c.createForkGitHub(ctx, repo, owner)
+	case scm.DriverGitlab:
+		return c.createForkGitLab(ctx, repo, owner)
+	}
+	return "", fmt.Errorf("creating forks with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+}
+
+// createForkGitHub creates the fork, GitHub returns the existing fork if
+// there is one.
+func (c *SCMClient) createForkGitHub(ctx context.Context, repo, owner string) (string, error) {
+	in := map[string]string{}
+	if owner != "" {
+		in["organization"] = owner
+	}
+	fork := &struct {
+		FullName string `json:"full_name"`
+	}{}
+	_, err := c.do(ctx, fmt.Sprintf("failed to fork repo %s", repo), "POST", fmt.Sprintf("repos/%s/forks", repo), in, fork)
+	if err != nil {
+		return "", err
+	}
+	return fork.FullName, nil
+}
+
+// SyncFork updates the branch in the fork with the commits in the same branch
+// of the repository that it was forked from, so that branches can be created
+// in the fork from those commits.
+//
+// This is supported for GitHub, other drivers return an error wrapping
+// scm.ErrNotSupported, GitLab can only mirror forks with pull mirroring.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned, if the branch in the fork has commits that
+// are not in the repository, this is a 409.
+func (c *SCMClient) SyncFork(ctx context.Context, fork, branch string) error {
+	if c.scmClient.Driver != scm.DriverGithub {
+		return fmt.Errorf("syncing forks with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	defer c.cache.invalidate(headCacheKey(fork, branch))
+	_, err := c.do(ctx, fmt.Sprintf("failed to sync branch %s in fork %s", branch, fork), "POST",
+		fmt.Sprintf("repos/%s/merge-upstream", fork), map[string]string{"branch": branch}, nil)
+	return err
+}
+
+type glProject struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+// createForkGitLab looks for an existing fork owned by the owner, or by the
+// authenticated user, as GitLab rejects creating a second fork in the same
+// namespace.
+func (c *SCMClient) createForkGitLab(ctx context.Context, repo, owner string) (string, error) {
+	msg := fmt.Sprintf("failed to fork repo %s", repo)
+	for page := 1; ; page++ {
+		forks := []glProject{}
+		path := fmt.Sprintf("api/v4/projects/%s/forks?per_page=%d&page=%d", encodeGitLabRepo(repo), forkPageSize, page)
+		if owner == "" {
+			path += "&owned=true"
+		}
+		if _, err := c.do(ctx, msg, "GET", path, nil, &forks); err != nil {
+			return "", err
+		}
+		for _, fork := range forks {
+			if owner == "" || strings.EqualFold(fork.Namespace.FullPath, owner) {
+				return fork.PathWithNamespace, nil
+			}
+		}
+		if len(forks) < forkPageSize {
+			break
+		}
+	}
+
+	path := fmt.Sprintf("api/v4/projects/%s/fork", encodeGitLabRepo(repo))
+	if owner != "" {
+		path += "?namespace_path=" + url.QueryEscape(owner)
+	}
+	fork := &glProject{}
+	if _, err := c.do(ctx, msg, "POST", path, nil, fork); err != nil {
+		return "", err
+	}
+	return fork.PathWithNamespace, nil
+}
+
+// gitLabProjectID looks up the numeric ID of a GitLab project, which is needed
+// to open merge requests between projects.
+func (c *SCMClient) gitLabProjectID(ctx context.Context, repo string) (int, error) {
+	project := &glProject{}
+	_, err := c.do(ctx, fmt.Sprintf("failed to get repo %s", repo), "GET",
+		fmt.Sprintf("api/v4/projects/%s", encodeGitLabRepo(repo)), nil, project)
+	if err != nil {
+		return 0, err
+	}
+	return project.ID, nil
+}
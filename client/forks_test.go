@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/go-scm/scm/factory"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestCreateForkInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/forks").
+		MatchType("json").
+		JSON(map[string]string{"organization": "octo-bots"}).
+		Reply(http.StatusAccepted).
+		Type("application/json").
+		BodyString(`{"id": 1296270, "full_name": "octo-bots/Hello-World", "fork": true}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	fork, err := client.CreateFork(context.Background(), "Codertocat/Hello-World", "octo-bots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != "octo-bots/Hello-World" {
+		t.Fatalf("got fork %s, want octo-bots/Hello-World", fork)
+	}
+	if !gock.IsDone() {
+		t.Fatal("fork was not created")
+	}
+}
+
+func TestCreateForkInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/forks").
+		MatchParam("page", "1").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"id": 2, "path_with_namespace": "someone/Hello-World", "namespace": {"full_path": "someone"}}]`)
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/Codertocat/Hello-World/fork").
+		MatchParam("namespace_path", "octo-bots").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		BodyString(`{"id": 3, "path_with_namespace": "octo-bots/Hello-World", "namespace": {"full_path": "octo-bots"}}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	fork, err := client.CreateFork(context.Background(), "Codertocat/Hello-World", "octo-bots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != "octo-bots/Hello-World" {
+		t.Fatalf("got fork %s, want octo-bots/Hello-World", fork)
+	}
+	if !gock.IsDone() {
+		t.Fatal("fork was not created")
+	}
+}
+
+func TestCreateForkInGitLabWithExistingFork(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/forks").
+		MatchParam("owned", "true").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`[{"id": 2, "path_with_namespace": "octobot/hello-world-fork", "namespace": {"full_path": "octobot"}}]`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	fork, err := client.CreateFork(context.Background(), "Codertocat/Hello-World", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != "octobot/hello-world-fork" {
+		t.Fatalf("got fork %s, want octobot/hello-world-fork", fork)
+	}
+}
+
+func TestCreateForkWithUnsupportedDriver(t *testing.T) {
+	scmClient, err := factory.NewClient("bitbucket", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CreateFork(context.Background(), "Codertocat/Hello-World", "")
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want an error wrapping scm.ErrNotSupported", err)
+	}
+}
+
+func TestSyncForkInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/octo-bots/Hello-World/merge-upstream").
+		MatchType("json").
+		JSON(map[string]string{"branch": "main"}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"message": "Successfully fetched and fast-forwarded from upstream Codertocat:main.", "merge_type": "fast-forward", "base_branch": "Codertocat:main"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	if err := client.SyncFork(context.Background(), "octo-bots/Hello-World", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("fork was not synced")
+	}
+}
+
+func TestSyncForkWithDivergedBranch(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/octo-bots/Hello-World/merge-upstream").
+		Reply(http.StatusConflict).
+		Type("application/json").
+		BodyString(`{"message": "There are merge conflicts"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.SyncFork(context.Background(), "octo-bots/Hello-World", "main")
+	if !IsConflict(err) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+}
+
+func TestSyncForkWithUnsupportedDriver(t *testing.T) {
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	err = client.SyncFork(context.Background(), "octo-bots/Hello-World", "main")
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want an error wrapping scm.ErrNotSupported", err)
+	}
+}
+
+func TestCreatePullRequestFromForkInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/pulls").
+		MatchType("json").
+		JSON(map[string]interface{}{"title": "Amazing new feature", "body": "Please pull these awesome changes in!", "head": "octo-bots:new-topic", "base": "master", "draft": false}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/pulls/1347").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/pr_create.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	_, err = client.CreatePullRequestWithOptions(context.Background(), "Codertocat/Hello-World", &scm.PullRequestInput{
+		Title:  "Amazing new feature",
+		Body:   "Please pull these awesome changes in!",
+		Source: "new-topic",
+		Target: "master",
+	}, PullRequestOptions{SourceRepo: "octo-bots/Hello-World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("pull request was not created")
+	}
+}
+
+func TestCreatePullRequestFromForkInGitLab(t *testing.T) {
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"id": 1, "path_with_namespace": "Codertocat/Hello-World"}`)
+	gock.New("https://gitlab.com").
+		Post("/api/v4/projects/octo-bots/Hello-World/merge_requests").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"title":             "Amazing new feature",
+			"description":       "Please pull these awesome changes in!",
+			"source_branch":     "new-topic",
+			"target_branch":     "master",
+			"target_project_id": 1,
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		BodyString(`{"iid": 4}`)
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/Codertocat/Hello-World/merge_requests/4").
+		Reply(http.StatusOK).
+		Type("application/json").
+		BodyString(`{"iid": 4, "title": "Amazing new feature", "state": "opened"}`)
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient)
+
+	pr, err := client.CreatePullRequestWithOptions(context.Background(), "Codertocat/Hello-World", &scm.PullRequestInput{
+		Title:  "Amazing new feature",
+		Body:   "Please pull these awesome changes in!",
+		Source: "new-topic",
+		Target: "master",
+	}, PullRequestOptions{SourceRepo: "octo-bots/Hello-World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("merge request was not created")
+	}
+	if pr.Number != 4 {
+		t.Fatalf("got merge request %d, want 4", pr.Number)
+	}
+}
@@ -11,7 +11,22 @@ type GitClient interface {
 	GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error)
 	UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error
 	DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error
+	MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error
+	ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*FileInfo, error)
+	CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []FileChange) (string, error)
 	CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error)
+	CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts PullRequestOptions) (*scm.PullRequest, error)
+	FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error)
+	ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error)
+	UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error)
+	MergePullRequest(ctx context.Context, repo string, number int, opts MergeOptions) error
+	ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error)
+	GetCombinedStatus(ctx context.Context, repo, ref string) (*CombinedStatus, error)
 	CreateBranch(ctx context.Context, repo, branch, sha string) error
 	GetBranchHead(ctx context.Context, repo, branch string) (string, error)
+	ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error)
+	DeleteBranch(ctx context.Context, repo, branch string) error
+	CreateFork(ctx context.Context, repo, owner string) (string, error)
+	SyncFork(ctx context.Context, fork, branch string) error
+	GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error)
 }
@@ -0,0 +1,362 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+var _ client.GitClient = (*Client)(nil)
+
+// Client implements the client.GitClient interface for a local Git
+// repository, which can be bare or have a working tree.
+//
+// Changes are written directly to the object database and refs, so the working
+// tree is not updated when a change is committed to the checked out branch.
+//
+// PullRequests and commit statuses are recorded as metadata alongside the
+// repository, see MetadataFile.
+//
+// The repo parameter of the client.GitClient methods is ignored, as each Client
+// accesses a single repository.
+type Client struct {
+	repo     *git.Repository
+	metadata *metadataStore
+	mu       sync.Mutex
+}
+
+// New creates and returns a new Client for an opened repository.
+func New(r *git.Repository) (*Client, error) {
+	m, err := newMetadataStore(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+	return &Client{repo: r, metadata: m}, nil
+}
+
+// Open creates and returns a new Client for the repository at path.
+func Open(path string) (*Client, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository %s: %w", path, err)
+	}
+	return New(r)
+}
+
+// Clone creates a bare copy of the repository at url in path, with all the
+// branches and tags, and returns a new Client for it.
+func Clone(ctx context.Context, url, path string) (*Client, error) {
+	r, err := git.PlainInit(path, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository %s: %w", path, err)
+	}
+	remote, err := r.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote: %w", err)
+	}
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/*:refs/heads/*"},
+		Tags:     git.AllTags,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	return New(r)
+}
+
+// GetFile reads the file at path from the ref, which can be a branch, tag or
+// commit SHA.
+//
+// The Sha and BlobID of the returned content are the SHA of the file's blob.
+func (c *Client) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	f, err := commit.File(path)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, notFound(fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref))
+		}
+		return nil, err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &scm.Content{
+		Path:   path,
+		Data:   data,
+		Sha:    f.Hash.String(),
+		BlobID: f.Hash.String(),
+	}, nil
+}
+
+// UpdateFile commits the content to the file at path in the branch, the file
+// is created if it doesn't exist.
+//
+// If the file exists, and the previousSHA is not empty and doesn't match the
+// file's blob SHA, an error with a 409 status is returned.
+func (c *Client) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileUpdate, Path: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// DeleteFile commits the removal of the file at path from the branch.
+//
+// If the previousSHA is not empty and doesn't match the file's blob SHA, an
+// error with a 409 status is returned.
+func (c *Client) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileDelete, Path: path, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// ListFiles returns the entries in the directory at the ref, which can be a
+// branch, tag or commit SHA.
+func (c *Client) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	dir = strings.Trim(dir, "/")
+	if dir != "" {
+		tree, err = tree.Tree(dir)
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, notFound(fmt.Sprintf("failed to list files in %s in repo %s ref %s", dir, repo, ref))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	files := []*client.FileInfo{}
+	return files, c.listTree(tree, dir, recursive, &files)
+}
+
+// MoveFile commits the move of the file at path to the newPath in the
+// branch, replacing its content unless the content is nil.
+//
+// If the previousSHA is not empty and doesn't match the file's blob SHA, an
+// error with a 409 status is returned.
+func (c *Client) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileMove, Path: newPath, PreviousPath: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// CommitFiles creates a single commit on the branch that applies all the
+// changes, and returns the SHA of the new commit.
+//
+// The PreviousSHA of each change is compared to the blob SHA of the file, and
+// if the branch is changed by someone else while committing, an error with a
+// 409 status is returned.
+func (c *Client) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	head, err := c.branchRef(branch)
+	if err != nil {
+		return "", err
+	}
+	parent, err := c.repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+	tree := parent.TreeHash
+	for _, change := range changes {
+		if err := change.Mode.Validate(); err != nil {
+			return "", fmt.Errorf("failed to commit file %s: %w", change.Path, err)
+		}
+		if tree, err = c.applyChange(tree, change); err != nil {
+			return "", err
+		}
+	}
+	hash, err := c.writeCommit(message, signature, tree, parent.Hash)
+	if err != nil {
+		return "", err
+	}
+	if err := c.updateRef(head, hash); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// CreateBranch creates a new branch pointing at the commit sha.
+func (c *Client) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := c.repo.Reference(name, false); err == nil {
+		return invalid(fmt.Sprintf("failed to create branch %s in repo %s", branch, repo), "Reference already exists")
+	}
+	commit, err := c.resolveCommit(sha)
+	if err != nil {
+		return err
+	}
+	return c.repo.Storer.SetReference(plumbing.NewHashReference(name, commit.Hash))
+}
+
+// GetBranchHead returns the SHA of the commit at the head of the branch.
+func (c *Client) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	ref, err := c.branchRef(branch)
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+// ListBranches returns all the branches in the repository, sorted by name.
+func (c *Client) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	iter, err := c.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	branches := []*scm.Reference{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches = append(branches, &scm.Reference{
+			Name: ref.Name().Short(),
+			Path: ref.Name().String(),
+			Sha:  ref.Hash().String(),
+		})
+		return nil
+	})
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, err
+}
+
+// DeleteBranch deletes a branch from the repository.
+func (c *Client) DeleteBranch(ctx context.Context, repo, branch string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ref, err := c.branchRef(branch)
+	if err != nil {
+		return err
+	}
+	return c.repo.Storer.RemoveReference(ref.Name())
+}
+
+// CreateFork returns an error wrapping scm.ErrNotSupported, as each Client
+// accesses a single repository.
+func (c *Client) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	return "", fmt.Errorf("creating forks of a local repository: %w", scm.ErrNotSupported)
+}
+
+// SyncFork returns an error wrapping scm.ErrNotSupported, as each Client
+// accesses a single repository.
+func (c *Client) SyncFork(ctx context.Context, fork, branch string) error {
+	return fmt.Errorf("syncing forks of a local repository: %w", scm.ErrNotSupported)
+}
+
+// GetCommit returns the commit identified by the ref.
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &scm.Commit{
+		Sha:       commit.Hash.String(),
+		Message:   commit.Message,
+		Author:    convertSignature(commit.Author),
+		Committer: convertSignature(commit.Committer),
+	}, nil
+}
+
+func (c *Client) resolveCommit(ref string) (*object.Commit, error) {
+	hash, err := c.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) || errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, notFound(fmt.Sprintf("failed to find ref %s", ref))
+		}
+		return nil, err
+	}
+	return c.repo.CommitObject(*hash)
+}
+
+func (c *Client) branchRef(branch string) (*plumbing.Reference, error) {
+	ref, err := c.repo.Reference(plumbing.NewBranchReferenceName(branch), false)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, notFound(fmt.Sprintf("failed to find branch %s", branch))
+		}
+		return nil, err
+	}
+	return ref, nil
+}
+
+// updateRef moves the branch to the commit, if the branch still points at the
+// same commit as old.
+func (c *Client) updateRef(old *plumbing.Reference, hash plumbing.Hash) error {
+	err := c.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(old.Name(), hash), old)
+	if errors.Is(err, storage.ErrReferenceHasChanged) {
+		return conflict(fmt.Sprintf("failed to update branch %s", old.Name().Short()), err.Error())
+	}
+	return err
+}
+
+func (c *Client) writeCommit(message string, signature scm.Signature, tree plumbing.Hash, parents ...plumbing.Hash) (plumbing.Hash, error) {
+	sig := object.Signature{Name: signature.Name, Email: signature.Email, When: signature.Date}
+	if sig.When.IsZero() {
+		sig.When = time.Now()
+	}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	obj := c.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.repo.Storer.SetEncodedObject(obj)
+}
+
+func convertSignature(s object.Signature) scm.Signature {
+	return scm.Signature{Name: s.Name, Email: s.Email, Date: s.When}
+}
+
+func notFound(msg string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusNotFound, ResponseMsg: "Not Found"}
+}
+
+func conflict(msg, reason string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusConflict, ResponseMsg: reason}
+}
+
+func cleanPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
@@ -0,0 +1,584 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+const (
+	testRepo   = "testorg/testrepo"
+	testBranch = "main"
+	testFile   = "environments/test/config.yaml"
+)
+
+var testSignature = scm.Signature{
+	Name:  "Test User",
+	Email: "test@example.com",
+	Date:  time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC),
+}
+
+func TestGetFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	content, err := c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha := hashBlob(t, []byte("test: old\n"))
+	want := &scm.Content{
+		Path:   testFile,
+		Data:   []byte("test: old\n"),
+		Sha:    sha,
+		BlobID: sha,
+	}
+	if diff := cmp.Diff(want, content); diff != "" {
+		t.Fatalf("incorrect content:\n%s", diff)
+	}
+}
+
+func TestGetFileWithMissingFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	_, err := c.GetFile(context.Background(), testRepo, testBranch, "unknown.yaml")
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	listTests := []struct {
+		dir       string
+		recursive bool
+		want      []*client.FileInfo
+	}{
+		{"", false, []*client.FileInfo{
+			{Path: "README.md", Kind: client.KindFile},
+			{Path: "environments", Kind: client.KindDirectory},
+		}},
+		{"environments", true, []*client.FileInfo{
+			{Path: "environments/test", Kind: client.KindDirectory},
+			{Path: testFile, Kind: client.KindFile},
+		}},
+	}
+
+	for _, tt := range listTests {
+		t.Run(tt.dir, func(rt *testing.T) {
+			files, err := c.ListFiles(context.Background(), testRepo, testBranch, tt.dir, tt.recursive)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, files, cmpopts.IgnoreFields(client.FileInfo{}, "Sha")); diff != "" {
+				rt.Fatalf("incorrect files:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListFilesWithMissingDirectory(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	_, err := c.ListFiles(context.Background(), testRepo, testBranch, "unknown", false)
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	current, err := c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", current.Sha, testSignature, []byte("test: new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, testBranch, testFile, "test: new\n")
+	assertFileContent(t, c, testBranch, "README.md", "testing\n")
+	commit, err := c.GetCommit(context.Background(), testRepo, testBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "update config" || commit.Author.Email != testSignature.Email || !commit.Author.Date.Equal(testSignature.Date) {
+		t.Fatalf("incorrect commit: %#v", commit)
+	}
+}
+
+func TestUpdateFileWithStaleSHA(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	err := c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", hashBlob(t, []byte("unknown")), testSignature, []byte("test: new\n"))
+
+	if !client.IsConflict(err) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	assertFileContent(t, c, testBranch, testFile, "test: old\n")
+}
+
+func TestUpdateFileCreatingFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	err := c.UpdateFile(context.Background(), testRepo, testBranch, "environments/prod/new/config.yaml", "create config", "", testSignature, []byte("test: prod\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, testBranch, "environments/prod/new/config.yaml", "test: prod\n")
+	assertFileContent(t, c, testBranch, testFile, "test: old\n")
+}
+
+func TestDeleteFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	err := c.DeleteFile(context.Background(), testRepo, testBranch, testFile, "remove config", "", testSignature, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+	head, err := c.repo.CommitObject(plumbing.NewHash(mustBranchHead(t, c, testBranch)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.FindEntry("environments"); err != object.ErrEntryNotFound {
+		t.Fatalf("empty directory was not removed: %v", err)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	moveTests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"keeping the content", nil, "test: old\n"},
+		{"replacing the content", []byte("test: new\n"), "test: new\n"},
+	}
+
+	for _, tt := range moveTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			c := newTestClient(rt, git.PlainInit)
+			newPath := "environments/prod/config.yaml"
+
+			err := c.MoveFile(context.Background(), testRepo, testBranch, testFile, newPath, "move config", "", testSignature, tt.content)
+			if err != nil {
+				rt.Fatal(err)
+			}
+
+			assertFileContent(rt, c, testBranch, newPath, tt.want)
+			if _, err := c.GetFile(context.Background(), testRepo, testBranch, testFile); !client.IsNotFound(err) {
+				rt.Fatalf("got %v, want a not found error", err)
+			}
+			files, err := c.ListFiles(context.Background(), testRepo, testBranch, "environments", false)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if len(files) != 1 || files[0].Path != "environments/prod" {
+				rt.Fatalf("got files %v, want only the new directory", files)
+			}
+		})
+	}
+}
+
+func TestMoveFileToExistingFile(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	err := c.MoveFile(context.Background(), testRepo, testBranch, testFile, "README.md", "move config", "", testSignature, nil)
+
+	if !client.IsValidation(err) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+}
+
+func TestCommitFiles(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	before := mustBranchHead(t, c, testBranch)
+
+	sha, err := c.CommitFiles(context.Background(), testRepo, testBranch, "update files", testSignature, []client.FileChange{
+		{Action: client.FileUpdate, Path: testFile, Content: []byte("test: new\n")},
+		{Action: client.FileCreate, Path: "environments/prod/config.yaml", Content: []byte("test: prod\n")},
+		{Action: client.FileDelete, Path: "README.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head := mustBranchHead(t, c, testBranch); head != sha {
+		t.Fatalf("branch head got %s, want %s", head, sha)
+	}
+	commit, err := c.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.NumParents() != 1 || commit.ParentHashes[0].String() != before {
+		t.Fatalf("incorrect parents %v, want %s", commit.ParentHashes, before)
+	}
+	assertFileContent(t, c, testBranch, testFile, "test: new\n")
+	assertFileContent(t, c, testBranch, "environments/prod/config.yaml", "test: prod\n")
+	if _, err := c.GetFile(context.Background(), testRepo, testBranch, "README.md"); !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestCommitFilesWithMode(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+
+	_, err := c.CommitFiles(context.Background(), testRepo, testBranch, "make executable", testSignature, []client.FileChange{
+		{Action: client.FileUpdate, Path: testFile, Content: []byte("test: new\n"), Mode: client.ModeExecutable},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CommitFiles(context.Background(), testRepo, testBranch, "update file", testSignature, []client.FileChange{
+		{Action: client.FileUpdate, Path: testFile, Content: []byte("test: newer\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := c.repo.CommitObject(plumbing.NewHash(mustBranchHead(t, c, testBranch)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := commit.File(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Mode != filemode.Executable {
+		t.Fatalf("got mode %s, want %s", f.Mode, filemode.Executable)
+	}
+}
+
+func TestBranches(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	head := mustBranchHead(t, c, testBranch)
+
+	if err := c.CreateBranch(context.Background(), testRepo, "test-branch", head); err != nil {
+		t.Fatal(err)
+	}
+	err := c.CreateBranch(context.Background(), testRepo, "test-branch", head)
+	var e client.SCMError
+	if !errors.As(err, &e) || e.Status != 422 {
+		t.Fatalf("got %v, want a 422 error", err)
+	}
+
+	branches, err := c.ListBranches(context.Background(), testRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*scm.Reference{
+		{Name: "main", Path: "refs/heads/main", Sha: head},
+		{Name: "test-branch", Path: "refs/heads/test-branch", Sha: head},
+	}
+	if diff := cmp.Diff(want, branches); diff != "" {
+		t.Fatalf("incorrect branches:\n%s", diff)
+	}
+
+	if err := c.DeleteBranch(context.Background(), testRepo, "test-branch"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBranchHead(context.Background(), testRepo, "test-branch"); !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestPullRequests(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	createTestBranch(t, c, "test-branch", "test: new\n")
+
+	pr, err := c.CreatePullRequestWithOptions(context.Background(), testRepo, &scm.PullRequestInput{
+		Title:  "Update config",
+		Body:   "Update the test config",
+		Source: "test-branch",
+		Target: testBranch,
+	}, client.PullRequestOptions{Labels: []string{"bump"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CreatePullRequest(context.Background(), testRepo, &scm.PullRequestInput{Source: "test-branch", Target: testBranch})
+	if err == nil {
+		t.Fatal("created duplicate pull request")
+	}
+	_, err = c.UpdatePullRequest(context.Background(), testRepo, pr.Number, &scm.PullRequestInput{Title: "Update test config", Body: "Update the test config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prs, err := c.ListPullRequests(context.Background(), testRepo, scm.PullRequestListOptions{Open: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 1 {
+		t.Fatalf("got %d pull requests, want 1", len(prs))
+	}
+	want := &scm.PullRequest{
+		Number: 1,
+		Title:  "Update test config",
+		Body:   "Update the test config",
+		Sha:    mustBranchHead(t, c, "test-branch"),
+		Ref:    "refs/pull/1/head",
+		Source: "test-branch",
+		Target: testBranch,
+		Labels: []scm.Label{{Name: "bump"}},
+	}
+	if diff := cmp.Diff(want, prs[0], cmpIgnoreTimes()); diff != "" {
+		t.Fatalf("incorrect pull request:\n%s", diff)
+	}
+}
+
+func TestMergePullRequest(t *testing.T) {
+	mergeTests := []struct {
+		method  client.MergeMethod
+		parents int
+		message string
+	}{
+		{client.MergeMethodMerge, 2, "Merge pull request #1 from test-branch"},
+		{client.MergeMethodSquash, 1, "Update config (#1)"},
+		{client.MergeMethodRebase, 1, "update config"},
+	}
+
+	for _, tt := range mergeTests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			c := newTestClient(t, git.PlainInit)
+			createTestBranch(t, c, "test-branch", "test: new\n")
+			pr, err := c.CreatePullRequest(context.Background(), testRepo, &scm.PullRequestInput{
+				Title:  "Update config",
+				Source: "test-branch",
+				Target: testBranch,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.MergePullRequest(context.Background(), testRepo, pr.Number, client.MergeOptions{
+				Method:       tt.method,
+				SHA:          pr.Sha,
+				DeleteBranch: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertFileContent(t, c, testBranch, testFile, "test: new\n")
+			commit, err := c.repo.CommitObject(plumbing.NewHash(mustBranchHead(t, c, testBranch)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit.NumParents() != tt.parents || commit.Message != tt.message {
+				t.Fatalf("got %d parents and message %q, want %d and %q", commit.NumParents(), commit.Message, tt.parents, tt.message)
+			}
+			merged, err := c.FindPullRequest(context.Background(), testRepo, pr.Number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !merged.Merged || !merged.Closed {
+				t.Fatalf("pull request was not merged: %#v", merged)
+			}
+			if _, err := c.GetBranchHead(context.Background(), testRepo, "test-branch"); !client.IsNotFound(err) {
+				t.Fatalf("branch was not deleted: %v", err)
+			}
+		})
+	}
+}
+
+func TestMergePullRequestWithDivergedBranches(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	createTestBranch(t, c, "test-branch", "test: new\n")
+	pr, err := c.CreatePullRequest(context.Background(), testRepo, &scm.PullRequestInput{
+		Title:  "Update config",
+		Source: "test-branch",
+		Target: testBranch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.UpdateFile(context.Background(), testRepo, testBranch, "README.md", "update readme", "", testSignature, []byte("updated\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.MergePullRequest(context.Background(), testRepo, pr.Number, client.MergeOptions{})
+
+	var e client.SCMError
+	if !errors.As(err, &e) || e.Status != 405 {
+		t.Fatalf("got %v, want a 405 error", err)
+	}
+}
+
+func TestCombinedStatus(t *testing.T) {
+	c := newTestClient(t, git.PlainInit)
+	head := mustBranchHead(t, c, testBranch)
+	for _, state := range []scm.State{scm.StateFailure, scm.StateSuccess} {
+		if _, err := c.CreateStatus(context.Background(), testRepo, testBranch, &scm.StatusInput{Label: "ci/build", State: state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, err := c.GetCombinedStatus(context.Background(), testRepo, testBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &client.CombinedStatus{
+		SHA:      head,
+		State:    scm.StateSuccess,
+		Statuses: []*scm.Status{{Label: "ci/build", State: scm.StateSuccess}},
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Fatalf("incorrect status:\n%s", diff)
+	}
+}
+
+func TestMetadataIsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestClient(t, func(string, bool) (*git.Repository, error) {
+		return git.PlainInit(dir, true)
+	})
+	createTestBranch(t, c, "test-branch", "test: new\n")
+	pr, err := c.CreatePullRequest(context.Background(), testRepo, &scm.PullRequestInput{
+		Title:  "Update config",
+		Source: "test-branch",
+		Target: testBranch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := reopened.FindPullRequest(context.Background(), testRepo, pr.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Title != pr.Title {
+		t.Fatalf("got %q, want %q", found.Title, pr.Title)
+	}
+}
+
+func TestClone(t *testing.T) {
+	dir := t.TempDir()
+	origin := newTestClient(t, func(string, bool) (*git.Repository, error) {
+		return git.PlainInit(dir, true)
+	})
+	createTestBranch(t, origin, "test-branch", "test: new\n")
+
+	c, err := Clone(context.Background(), dir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, testBranch, testFile, "test: old\n")
+	assertFileContent(t, c, "test-branch", testFile, "test: new\n")
+}
+
+func TestNewWithMemoryStorage(t *testing.T) {
+	c := newTestClient(t, func(string, bool) (*git.Repository, error) {
+		return git.Init(memory.NewStorage(), nil)
+	})
+
+	assertFileContent(t, c, testBranch, testFile, "test: old\n")
+}
+
+// newTestClient creates a Client for a new repository with an initial commit
+// on the main branch.
+func newTestClient(t *testing.T, initRepo func(string, bool) (*git.Repository, error)) *Client {
+	t.Helper()
+	r, err := initRepo(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := c.writeTree(&object.Tree{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err := c.writeCommit("initial commit", testSignature, empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(testBranch), initial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CommitFiles(context.Background(), testRepo, testBranch, "add files", testSignature, []client.FileChange{
+		{Action: client.FileCreate, Path: testFile, Content: []byte("test: old\n")},
+		{Action: client.FileCreate, Path: "README.md", Content: []byte("testing\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func createTestBranch(t *testing.T, c *Client, branch, content string) {
+	t.Helper()
+	if err := c.CreateBranch(context.Background(), testRepo, branch, mustBranchHead(t, c, testBranch)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateFile(context.Background(), testRepo, branch, testFile, "update config", "", testSignature, []byte(content)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustBranchHead(t *testing.T, c *Client, branch string) string {
+	t.Helper()
+	sha, err := c.GetBranchHead(context.Background(), testRepo, branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func assertFileContent(t *testing.T, c *Client, ref, path, want string) {
+	t.Helper()
+	content, err := c.GetFile(context.Background(), testRepo, ref, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != want {
+		t.Fatalf("got %q in %s, want %q", content.Data, path, want)
+	}
+}
+
+func hashBlob(t *testing.T, b []byte) string {
+	t.Helper()
+	return plumbing.ComputeHash(plumbing.BlobObject, b).String()
+}
+
+func cmpIgnoreTimes() cmp.Option {
+	return cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Created" || p.Last().String() == ".Updated"
+	}, cmp.Ignore())
+}
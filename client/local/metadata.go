@@ -0,0 +1,80 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+// MetadataFile is the name of the file in the Git directory of the repository
+// that PullRequests and commit statuses are recorded in.
+//
+// Repositories that are not stored on disk keep the metadata in memory.
+const MetadataFile = "metadata.json"
+
+type metadata struct {
+	PullRequests []*scm.PullRequest                `json:"pullRequests"`
+	Options      map[int]client.PullRequestOptions `json:"options"`
+	Statuses     map[string][]*scm.Status          `json:"statuses"`
+}
+
+type metadataStore struct {
+	fs   billy.Filesystem
+	data metadata
+}
+
+func newMetadataStore(r *git.Repository) (*metadataStore, error) {
+	m := &metadataStore{
+		data: metadata{
+			PullRequests: []*scm.PullRequest{},
+			Options:      map[int]client.PullRequestOptions{},
+			Statuses:     map[string][]*scm.Status{},
+		},
+	}
+	if s, ok := r.Storer.(*filesystem.Storage); ok {
+		m.fs = s.Filesystem()
+	}
+	if m.fs == nil {
+		return m, nil
+	}
+	b, err := util.ReadFile(m.fs, MetadataFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, json.Unmarshal(b, &m.data)
+}
+
+// save writes the metadata to a temporary file, and then renames it, so that
+// the metadata is never partially written.
+func (m *metadataStore) save() error {
+	if m.fs == nil {
+		return nil
+	}
+	b, err := json.MarshalIndent(m.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := util.TempFile(m.fs, "", MetadataFile)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return m.fs.Rename(f.Name(), MetadataFile)
+}
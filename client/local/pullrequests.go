@@ -0,0 +1,318 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+// CreatePullRequest records a new PullRequest from the Source to the Target
+// branch.
+//
+// An error with a 422 status is returned if either branch doesn't exist, or
+// there is already an open PullRequest between the branches.
+func (c *Client) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	return c.CreatePullRequestWithOptions(ctx, repo, inp, client.PullRequestOptions{})
+}
+
+// CreatePullRequestWithOptions records a new PullRequest along with the
+// options, the labels are added to the PullRequest, and the other options are
+// only recorded.
+//
+// PullRequests from a SourceRepo are not supported, as each Client accesses a
+// single repository.
+func (c *Client) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	if opts.SourceRepo != "" {
+		return nil, fmt.Errorf("pull requests from a fork of a local repository: %w", scm.ErrNotSupported)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := fmt.Sprintf("failed to create pull request in repo %s", repo)
+	source, err := c.branchRef(inp.Source)
+	if err != nil {
+		return nil, invalid(msg, fmt.Sprintf("head %s is invalid", inp.Source))
+	}
+	if _, err := c.branchRef(inp.Target); err != nil {
+		return nil, invalid(msg, fmt.Sprintf("base %s is invalid", inp.Target))
+	}
+	for _, pr := range c.metadata.data.PullRequests {
+		if !pr.Closed && pr.Source == inp.Source && pr.Target == inp.Target {
+			return nil, invalid(msg, fmt.Sprintf("A pull request already exists for %s", inp.Source))
+		}
+	}
+
+	now := time.Now()
+	pr := &scm.PullRequest{
+		Number:  len(c.metadata.data.PullRequests) + 1,
+		Title:   inp.Title,
+		Body:    inp.Body,
+		Sha:     source.Hash().String(),
+		Source:  inp.Source,
+		Target:  inp.Target,
+		Created: now,
+		Updated: now,
+	}
+	pr.Ref = fmt.Sprintf("refs/pull/%d/head", pr.Number)
+	for _, label := range opts.Labels {
+		pr.Labels = append(pr.Labels, scm.Label{Name: label})
+	}
+	c.metadata.data.PullRequests = append(c.metadata.data.PullRequests, pr)
+	if !opts.IsZero() {
+		c.metadata.data.Options[pr.Number] = opts
+	}
+	if err := c.metadata.save(); err != nil {
+		return nil, err
+	}
+	return c.withHead(pr), nil
+}
+
+// FindPullRequest returns the PullRequest with the number, the Sha of open
+// PullRequests is the current head of the Source branch.
+func (c *Client) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pr, err := c.findPullRequest(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return c.withHead(pr), nil
+}
+
+// ListPullRequests returns the PullRequests matching the options.
+func (c *Client) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matching := []*scm.PullRequest{}
+	for _, pr := range c.metadata.data.PullRequests {
+		if (opts.Open && !pr.Closed) || (opts.Closed && pr.Closed) || (!opts.Open && !opts.Closed) {
+			matching = append(matching, c.withHead(pr))
+		}
+	}
+	if opts.Size == 0 {
+		return matching, nil
+	}
+	start := (opts.Page - 1) * opts.Size
+	if opts.Page < 1 {
+		start = 0
+	}
+	if start >= len(matching) {
+		return []*scm.PullRequest{}, nil
+	}
+	end := start + opts.Size
+	if end > len(matching) {
+		end = len(matching)
+	}
+	return matching[start:end], nil
+}
+
+// UpdatePullRequest updates the title, body and target branch of an existing
+// PullRequest.
+func (c *Client) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pr, err := c.findPullRequest(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	if inp.Target != "" {
+		if _, err := c.branchRef(inp.Target); err != nil {
+			return nil, invalid(fmt.Sprintf("failed to update pull request %d in repo %s", number, repo),
+				fmt.Sprintf("base %s is invalid", inp.Target))
+		}
+		pr.Target = inp.Target
+	}
+	pr.Title = inp.Title
+	pr.Body = inp.Body
+	pr.Updated = time.Now()
+	if err := c.metadata.save(); err != nil {
+		return nil, err
+	}
+	return c.withHead(pr), nil
+}
+
+// MergePullRequest merges the Source branch into the Target branch of an open
+// PullRequest.
+//
+// The merge is only possible if the Target branch has not diverged from the
+// Source branch, otherwise an error with a 405 status is returned, as
+// conflicts can't be resolved. With MergeMethodRebase, the Target branch is
+// fast-forwarded to the Source branch.
+//
+// The merge commit is made with the committer of the head of the Source
+// branch.
+func (c *Client) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msg := fmt.Sprintf("failed to merge pull request %d in repo %s", number, repo)
+	pr, err := c.findPullRequest(repo, number)
+	if err != nil {
+		return err
+	}
+	if pr.Closed {
+		return notMergeable(msg, "Pull Request is not open")
+	}
+	source, err := c.branchRef(pr.Source)
+	if err != nil {
+		return err
+	}
+	target, err := c.branchRef(pr.Target)
+	if err != nil {
+		return err
+	}
+	if opts.SHA != "" && opts.SHA != source.Hash().String() {
+		return conflict(msg, "Head branch was modified. Review and try the merge again.")
+	}
+	head, err := c.repo.CommitObject(source.Hash())
+	if err != nil {
+		return err
+	}
+	base, err := c.repo.CommitObject(target.Hash())
+	if err != nil {
+		return err
+	}
+	if ok, err := base.IsAncestor(head); err != nil {
+		return err
+	} else if !ok && base.Hash != head.Hash {
+		return notMergeable(msg, "Pull Request is not mergeable")
+	}
+
+	merged := head.Hash
+	signature := convertSignature(head.Committer)
+	signature.Date = time.Time{}
+	switch opts.Method {
+	case client.MergeMethodRebase:
+	case client.MergeMethodSquash:
+		message := mergeMessage(opts, fmt.Sprintf("%s (#%d)", pr.Title, pr.Number))
+		merged, err = c.writeCommit(message, signature, head.TreeHash, base.Hash)
+	default:
+		message := mergeMessage(opts, fmt.Sprintf("Merge pull request #%d from %s", pr.Number, pr.Source))
+		merged, err = c.writeCommit(message, signature, head.TreeHash, base.Hash, head.Hash)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.updateRef(target, merged); err != nil {
+		return err
+	}
+
+	pr.Sha = head.Hash.String()
+	pr.Merged = true
+	pr.Closed = true
+	pr.Updated = time.Now()
+	if err := c.metadata.save(); err != nil {
+		return err
+	}
+	if opts.DeleteBranch {
+		return c.repo.Storer.RemoveReference(source.Name())
+	}
+	return nil
+}
+
+// CreateStatus records a commit status for the commit identified by the ref.
+func (c *Client) CreateStatus(ctx context.Context, repo, ref string, inp *scm.StatusInput) (*scm.Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	status := &scm.Status{
+		State:  inp.State,
+		Label:  inp.Label,
+		Desc:   inp.Desc,
+		Target: inp.Target,
+		Title:  inp.Title,
+	}
+	sha := commit.Hash.String()
+	c.metadata.data.Statuses[sha] = append([]*scm.Status{status}, c.metadata.data.Statuses[sha]...)
+	return status, c.metadata.save()
+}
+
+// ListStatuses returns the commit statuses recorded for the ref with
+// CreateStatus, the most recent first.
+func (c *Client) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	statuses := c.metadata.data.Statuses[commit.Hash.String()]
+	if statuses == nil {
+		return []*scm.Status{}, nil
+	}
+	return statuses, nil
+}
+
+// GetCombinedStatus returns the combined state of the commit statuses for a
+// ref, see client.CombineStatuses.
+func (c *Client) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	statuses, err := c.ListStatuses(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := c.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	latest := client.LatestStatuses(statuses)
+	return &client.CombinedStatus{
+		SHA:      commit.Hash.String(),
+		State:    client.CombineStatuses(latest),
+		Statuses: latest,
+	}, nil
+}
+
+func (c *Client) findPullRequest(repo string, number int) (*scm.PullRequest, error) {
+	for _, pr := range c.metadata.data.PullRequests {
+		if pr.Number == number {
+			return pr, nil
+		}
+	}
+	return nil, notFound(fmt.Sprintf("failed to find pull request %d in repo %s", number, repo))
+}
+
+// withHead returns a copy of the PullRequest, with the Sha of open
+// PullRequests updated to the head of the Source branch.
+func (c *Client) withHead(pr *scm.PullRequest) *scm.PullRequest {
+	cp := *pr
+	if pr.Closed {
+		return &cp
+	}
+	if ref, err := c.repo.Reference(plumbing.NewBranchReferenceName(pr.Source), false); err == nil {
+		cp.Sha = ref.Hash().String()
+	}
+	return &cp
+}
+
+func mergeMessage(opts client.MergeOptions, title string) string {
+	if opts.CommitTitle != "" {
+		title = opts.CommitTitle
+	}
+	if opts.CommitMessage == "" {
+		return title
+	}
+	return title + "\n\n" + opts.CommitMessage
+}
+
+func invalid(msg, reason string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusUnprocessableEntity, ResponseMsg: reason}
+}
+
+func notMergeable(msg, reason string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusMethodNotAllowed, ResponseMsg: reason}
+}
@@ -0,0 +1,241 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+// applyChange returns the hash of a new tree with the change applied to the
+// tree identified by root.
+func (c *Client) applyChange(root plumbing.Hash, change client.FileChange) (plumbing.Hash, error) {
+	if change.Action == client.FileMove {
+		return c.moveEntry(root, change)
+	}
+	tree, err := c.repo.TreeObject(root)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	current, err := tree.FindEntry(change.Path)
+	switch {
+	case err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound:
+		current = nil
+	case err != nil:
+		return plumbing.ZeroHash, err
+	}
+
+	if current != nil && current.Mode == filemode.Dir {
+		return plumbing.ZeroHash, fmt.Errorf("failed to update %s: is a directory", change.Path)
+	}
+	if current == nil && change.Action == client.FileDelete {
+		return plumbing.ZeroHash, notFound(fmt.Sprintf("failed to delete file %s", change.Path))
+	}
+	if current != nil && change.Action == client.FileCreate {
+		return plumbing.ZeroHash, invalid(fmt.Sprintf("failed to create file %s", change.Path), "A file with this name already exists")
+	}
+	if current != nil && change.PreviousSHA != "" && current.Hash.String() != change.PreviousSHA {
+		return plumbing.ZeroHash, conflict(fmt.Sprintf("failed to update file %s", change.Path),
+			fmt.Sprintf("%s does not match %s", change.Path, change.PreviousSHA))
+	}
+
+	var entry *object.TreeEntry
+	if change.Action != client.FileDelete {
+		blob, err := c.writeBlob(change.Content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry = &object.TreeEntry{Mode: filemode.Regular, Hash: blob}
+		if current != nil {
+			entry.Mode = current.Mode
+		}
+		if change.Mode != "" {
+			if entry.Mode, err = filemode.New(string(change.Mode)); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+	}
+	hash, empty, err := c.replaceEntry(tree, cleanPath(change.Path), entry)
+	if err != nil || !empty {
+		return hash, err
+	}
+	return c.writeTree(&object.Tree{})
+}
+
+// moveEntry returns the hash of a new tree with the file at the PreviousPath of
+// the change moved to its Path, keeping the mode of the file unless the change
+// has a mode, and replacing its content unless the change has no content.
+func (c *Client) moveEntry(root plumbing.Hash, change client.FileChange) (plumbing.Hash, error) {
+	tree, err := c.repo.TreeObject(root)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	msg := fmt.Sprintf("failed to move file %s to %s", change.PreviousPath, change.Path)
+	current, err := tree.FindEntry(change.PreviousPath)
+	switch {
+	case err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound:
+		return plumbing.ZeroHash, notFound(msg)
+	case err != nil:
+		return plumbing.ZeroHash, err
+	case current.Mode == filemode.Dir:
+		return plumbing.ZeroHash, fmt.Errorf("%s: is a directory", msg)
+	case change.PreviousSHA != "" && current.Hash.String() != change.PreviousSHA:
+		return plumbing.ZeroHash, conflict(msg, fmt.Sprintf("%s does not match %s", change.PreviousPath, change.PreviousSHA))
+	}
+	if _, err := tree.FindEntry(change.Path); err == nil {
+		return plumbing.ZeroHash, invalid(msg, "A file with this name already exists")
+	}
+
+	entry := &object.TreeEntry{Mode: current.Mode, Hash: current.Hash}
+	if change.Mode != "" {
+		if entry.Mode, err = filemode.New(string(change.Mode)); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	if change.Content != nil {
+		if entry.Hash, err = c.writeBlob(change.Content); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	root, empty, err := c.replaceEntry(tree, cleanPath(change.PreviousPath), nil)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if empty {
+		tree = nil
+	} else if tree, err = c.repo.TreeObject(root); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	root, _, err = c.replaceEntry(tree, cleanPath(change.Path), entry)
+	return root, err
+}
+
+// listTree appends the entries in the tree at dir to files, and the entries in
+// its subtrees if recursive is true.
+func (c *Client) listTree(tree *object.Tree, dir string, recursive bool, files *[]*client.FileInfo) error {
+	for _, entry := range tree.Entries {
+		path := entry.Name
+		if dir != "" {
+			path = dir + "/" + entry.Name
+		}
+		*files = append(*files, &client.FileInfo{Path: path, Sha: entry.Hash.String(), Kind: fileKind(entry.Mode)})
+		if !recursive || entry.Mode != filemode.Dir {
+			continue
+		}
+		subtree, err := c.repo.TreeObject(entry.Hash)
+		if err != nil {
+			return err
+		}
+		if err := c.listTree(subtree, path, recursive, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileKind(mode filemode.FileMode) client.FileKind {
+	switch mode {
+	case filemode.Dir:
+		return client.KindDirectory
+	case filemode.Symlink:
+		return client.KindSymlink
+	case filemode.Submodule:
+		return client.KindSubmodule
+	}
+	return client.KindFile
+}
+
+// replaceEntry returns the hash of a copy of the tree with the entry at the
+// path replaced, or removed if the entry is nil, creating any missing
+// directories.
+//
+// Directories that are left empty are removed, and the returned bool is true
+// if the tree itself is empty.
+func (c *Client) replaceEntry(tree *object.Tree, path []string, entry *object.TreeEntry) (plumbing.Hash, bool, error) {
+	entries := []object.TreeEntry{}
+	var existing *object.TreeEntry
+	if tree != nil {
+		for i := range tree.Entries {
+			if tree.Entries[i].Name == path[0] {
+				existing = &tree.Entries[i]
+				continue
+			}
+			entries = append(entries, tree.Entries[i])
+		}
+	}
+
+	if len(path) == 1 {
+		if entry != nil {
+			entries = append(entries, object.TreeEntry{Name: path[0], Mode: entry.Mode, Hash: entry.Hash})
+		}
+	} else {
+		var subtree *object.Tree
+		if existing != nil {
+			if existing.Mode != filemode.Dir {
+				return plumbing.ZeroHash, false, fmt.Errorf("failed to update %s: not a directory", existing.Name)
+			}
+			t, err := c.repo.TreeObject(existing.Hash)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			subtree = t
+		}
+		hash, empty, err := c.replaceEntry(subtree, path[1:], entry)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		if !empty {
+			entries = append(entries, object.TreeEntry{Name: path[0], Mode: filemode.Dir, Hash: hash})
+		}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, true, nil
+	}
+	sortEntries(entries)
+	hash, err := c.writeTree(&object.Tree{Entries: entries})
+	return hash, false, err
+}
+
+func (c *Client) writeTree(t *object.Tree) (plumbing.Hash, error) {
+	obj := c.repo.Storer.NewEncodedObject()
+	if err := t.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.repo.Storer.SetEncodedObject(obj)
+}
+
+func (c *Client) writeBlob(b []byte) (plumbing.Hash, error) {
+	obj := c.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.repo.Storer.SetEncodedObject(obj)
+}
+
+// sortEntries sorts the entries in the order Git requires, where directories
+// are sorted as if their names ended with a "/".
+func sortEntries(entries []object.TreeEntry) {
+	key := func(e object.TreeEntry) []byte {
+		if e.Mode == filemode.Dir {
+			return []byte(e.Name + "/")
+		}
+		return []byte(e.Name)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(key(entries[i]), key(entries[j])) < 0
+	})
+}
@@ -3,11 +3,14 @@ package mock
 import (
 	"context"
 	"crypto/sha1"
-	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ocraviotto/go-scm/scm"
 	"github.com/ocraviotto/pkg/client"
@@ -21,9 +24,22 @@ func New(t *testing.T) *MockClient {
 		t:                   t,
 		files:               make(map[string][]byte),
 		updatedFiles:        make(map[string][]byte),
+		updatedModes:        make(map[string]client.FileMode),
+		deletedFiles:        make(map[string]bool),
+		movedFiles:          make(map[string]string),
 		createdBranches:     make(map[string]bool),
 		branchHeads:         make(map[string]string),
+		deletedBranches:     make(map[string]bool),
+		commitDates:         make(map[string]time.Time),
 		createdPullRequests: make(map[string][]*scm.PullRequestInput),
+		pullRequests:        make(map[string][]*scm.PullRequest),
+		updatedPullRequests: make(map[string]*scm.PullRequestInput),
+		pullRequestOptions:  make(map[string]client.PullRequestOptions),
+		mergedPullRequests:  make(map[string]client.MergeOptions),
+		statuses:            make(map[string][]*scm.Status),
+		forks:               make(map[string]string),
+		createdForks:        make(map[string]bool),
+		syncedForks:         make(map[string]bool),
 	}
 }
 
@@ -33,13 +49,38 @@ type MockClient struct {
 	t                    *testing.T
 	files                map[string][]byte
 	GetFileErr           error
+	OnlyBlobIDs          bool
 	updatedFiles         map[string][]byte
+	updatedModes         map[string]client.FileMode
 	UpdateFileErr        error
+	deletedFiles         map[string]bool
+	movedFiles           map[string]string
+	ListFilesErr         error
+	commits              int
+	CommitFilesErr       error
 	createdBranches      map[string]bool
 	CreateBranchErr      error
 	branchHeads          map[string]string
+	GetBranchHeadErr     error
+	deletedBranches      map[string]bool
+	DeleteBranchErr      error
+	commitDates          map[string]time.Time
 	createdPullRequests  map[string][]*scm.PullRequestInput
 	CreatePullRequestErr error
+	pullRequestOptions   map[string]client.PullRequestOptions
+	pullRequests         map[string][]*scm.PullRequest
+	ListPullRequestsErr  error
+	updatedPullRequests  map[string]*scm.PullRequestInput
+	UpdatePullRequestErr error
+	mergedPullRequests   map[string]client.MergeOptions
+	MergePullRequestErr  error
+	statuses             map[string][]*scm.Status
+	ListStatusesErr      error
+	forks                map[string]string
+	createdForks         map[string]bool
+	CreateForkErr        error
+	syncedForks          map[string]bool
+	SyncForkErr          error
 }
 
 // GetFile implements the client.GitClient interface.
@@ -48,9 +89,13 @@ func (m *MockClient) GetFile(ctx context.Context, repo, ref, path string) (*scm.
 		return &scm.Content{}, m.GetFileErr
 	}
 	if b, ok := m.files[key(repo, path, ref)]; ok {
+		if m.OnlyBlobIDs {
+			// The GitHub client only returns the ID of the blob.
+			return &scm.Content{Data: b, BlobID: bytesSha1(b)}, nil
+		}
 		return &scm.Content{Data: b, Sha: bytesSha1(b)}, nil
 	}
-	return nil, errors.New("not found")
+	return nil, client.SCMError{Msg: fmt.Sprintf("file %s not found in repo %s ref %s", path, repo, ref), Status: http.StatusNotFound}
 }
 
 // UpdateFile implements the client.GitClient interface.
@@ -58,8 +103,14 @@ func (m *MockClient) UpdateFile(ctx context.Context, repo, branch, path, message
 	if m.UpdateFileErr != nil {
 		return m.UpdateFileErr
 	}
-	// TODO: Do we need something to validate the previousSHA?
+	if current, ok := m.files[key(repo, path, branch)]; ok && bytesSha1(current) != previousSHA {
+		return client.SCMError{
+			Msg:    fmt.Sprintf("failed to update file %s in repo %s branch %s", path, repo, branch),
+			Status: http.StatusConflict,
+		}
+	}
 	m.updatedFiles[key(repo, path, branch)] = content
+	m.commit(repo, branch)
 	return nil
 }
 
@@ -68,6 +119,98 @@ func (c *MockClient) DeleteFile(ctx context.Context, repo, branch, path, message
 	return scm.ErrNotSupported
 }
 
+// MoveFile implements the client.GitClient interface.
+func (m *MockClient) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := m.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileMove, Path: newPath, PreviousPath: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// ListFiles implements the client.GitClient interface.
+//
+// The files added with AddFileContents are listed, along with the directories
+// that contain them.
+func (m *MockClient) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	if m.ListFilesErr != nil {
+		return nil, m.ListFilesErr
+	}
+	dir = strings.Trim(dir, "/")
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	entries := map[string]*client.FileInfo{}
+	for k, b := range m.files {
+		path := strings.TrimSuffix(strings.TrimPrefix(k, key(repo, "")), ":"+ref)
+		if len(path)+len(repo)+len(ref)+2 != len(k) || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(path, prefix), "/")
+		for i := range parts[:len(parts)-1] {
+			if i > 0 && !recursive {
+				break
+			}
+			d := prefix + strings.Join(parts[:i+1], "/")
+			entries[d] = &client.FileInfo{Path: d, Kind: client.KindDirectory}
+		}
+		if recursive || len(parts) == 1 {
+			entries[path] = &client.FileInfo{Path: path, Sha: bytesSha1(b), Kind: client.KindFile}
+		}
+	}
+	if len(entries) == 0 && dir != "" {
+		return nil, client.SCMError{Msg: fmt.Sprintf("directory %s not found in repo %s ref %s", dir, repo, ref), Status: http.StatusNotFound}
+	}
+	files := []*client.FileInfo{}
+	for _, f := range entries {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// CommitFiles implements the client.GitClient interface.
+func (m *MockClient) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	if m.CommitFilesErr != nil {
+		return "", m.CommitFilesErr
+	}
+	for _, change := range changes {
+		path := change.Path
+		if change.PreviousPath != "" {
+			path = change.PreviousPath
+		}
+		current, ok := m.files[key(repo, path, branch)]
+		if ok && change.Action != client.FileCreate && change.PreviousSHA != "" && bytesSha1(current) != change.PreviousSHA {
+			return "", client.SCMError{
+				Msg:    fmt.Sprintf("failed to commit file %s in repo %s branch %s", path, repo, branch),
+				Status: http.StatusConflict,
+			}
+		}
+	}
+	for _, change := range changes {
+		switch change.Action {
+		case client.FileDelete:
+			m.deletedFiles[key(repo, change.Path, branch)] = true
+			continue
+		case client.FileMove:
+			previous := key(repo, change.PreviousPath, branch)
+			m.deletedFiles[previous] = true
+			m.movedFiles[previous] = change.Path
+			if change.Content == nil {
+				change.Content = m.files[previous]
+				if b, ok := m.updatedFiles[previous]; ok {
+					change.Content = b
+				}
+			}
+		}
+		m.updatedFiles[key(repo, change.Path, branch)] = change.Content
+		if change.Mode != "" {
+			m.updatedModes[key(repo, change.Path, branch)] = change.Mode
+		}
+	}
+	return m.commit(repo, branch), nil
+}
+
 // CreatePullRequest implements the client.GitClient interface.
 func (m *MockClient) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
 	if m.CreatePullRequestErr != nil {
@@ -79,8 +222,128 @@ func (m *MockClient) CreatePullRequest(ctx context.Context, repo string, inp *sc
 	}
 	existing = append(existing, inp)
 	m.createdPullRequests[repo] = existing
-	number := len(existing) // TODO: This is not concurrency safe!
-	return &scm.PullRequest{Number: number, Link: fmt.Sprintf("https://example.com/pull-request/%d", number)}, nil
+	number := len(m.pullRequests[repo]) + 1 // TODO: This is not concurrency safe!
+	pr := &scm.PullRequest{
+		Number: number,
+		Title:  inp.Title,
+		Body:   inp.Body,
+		Source: inp.Source,
+		Target: inp.Target,
+		Sha:    m.branchHeads[key(repo, inp.Source)],
+		Link:   fmt.Sprintf("https://example.com/pull-request/%d", number),
+	}
+	m.pullRequests[repo] = append(m.pullRequests[repo], pr)
+	return pr, nil
+}
+
+// CreatePullRequestWithOptions implements the client.GitClient interface.
+func (m *MockClient) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	pr, err := m.CreatePullRequest(ctx, repo, inp)
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range opts.Labels {
+		pr.Labels = append(pr.Labels, scm.Label{Name: label})
+	}
+	if opts.SourceRepo != "" {
+		pr.Fork = opts.SourceRepo
+		pr.Sha = m.branchHeads[key(opts.SourceRepo, inp.Source)]
+	}
+	m.pullRequestOptions[key(repo, strconv.Itoa(pr.Number))] = opts
+	return pr, nil
+}
+
+// FindPullRequest implements the client.GitClient interface.
+func (m *MockClient) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	for _, pr := range m.pullRequests[repo] {
+		if pr.Number == number {
+			return pr, nil
+		}
+	}
+	return nil, client.SCMError{Msg: fmt.Sprintf("pull request %d not found in repo %s", number, repo), Status: http.StatusNotFound}
+}
+
+// ListPullRequests implements the client.GitClient interface.
+//
+// All matching PullRequests are returned in the first page.
+func (m *MockClient) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	if m.ListPullRequestsErr != nil {
+		return nil, m.ListPullRequestsErr
+	}
+	prs := []*scm.PullRequest{}
+	if opts.Page > 1 {
+		return prs, nil
+	}
+	for _, pr := range m.pullRequests[repo] {
+		if (opts.Open && !pr.Closed) || (opts.Closed && pr.Closed) || (!opts.Open && !opts.Closed) {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, nil
+}
+
+// UpdatePullRequest implements the client.GitClient interface.
+func (m *MockClient) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	if m.UpdatePullRequestErr != nil {
+		return nil, m.UpdatePullRequestErr
+	}
+	pr, err := m.FindPullRequest(ctx, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	pr.Title = inp.Title
+	pr.Body = inp.Body
+	if inp.Target != "" {
+		pr.Target = inp.Target
+	}
+	m.updatedPullRequests[key(repo, strconv.Itoa(number))] = inp
+	return pr, nil
+}
+
+// MergePullRequest implements the client.GitClient interface.
+func (m *MockClient) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	if m.MergePullRequestErr != nil {
+		return m.MergePullRequestErr
+	}
+	pr, err := m.FindPullRequest(ctx, repo, number)
+	if err != nil {
+		return err
+	}
+	if pr.Closed {
+		return client.SCMError{Msg: fmt.Sprintf("pull request %d in repo %s is not open", number, repo), Status: http.StatusMethodNotAllowed}
+	}
+	pr.Merged = true
+	pr.Closed = true
+	m.mergedPullRequests[key(repo, strconv.Itoa(number))] = opts
+	if !opts.DeleteBranch {
+		return nil
+	}
+	headRepo := repo
+	if pr.Fork != "" {
+		headRepo = pr.Fork
+	}
+	if err := m.DeleteBranch(ctx, headRepo, pr.Source); err != nil {
+		return fmt.Errorf("pull request %d in repo %s was merged: %v: %w", number, repo, err, client.ErrBranchNotDeleted)
+	}
+	return nil
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (m *MockClient) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	if m.ListStatusesErr != nil {
+		return nil, m.ListStatusesErr
+	}
+	return m.statuses[key(repo, ref)], nil
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (m *MockClient) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	statuses, err := m.ListStatuses(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	latest := client.LatestStatuses(statuses)
+	return &client.CombinedStatus{SHA: ref, State: client.CombineStatuses(latest), Statuses: latest}, nil
 }
 
 // CreateBranch implements the client.GitClient interface.
@@ -89,18 +352,109 @@ func (m *MockClient) CreateBranch(ctx context.Context, repo, branch, sha string)
 		return m.CreateBranchErr
 	}
 	m.createdBranches[key(repo, branch, sha)] = true
+	m.branchHeads[key(repo, branch)] = sha
 	return nil
 }
 
 // GetBranchHead implements the client.GitClient interface.
 func (m *MockClient) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	if m.GetBranchHeadErr != nil {
+		return "", m.GetBranchHeadErr
+	}
 	ref, ok := m.branchHeads[key(repo, branch)]
 	if !ok {
-		return "", errors.New("not found")
+		return "", client.SCMError{Msg: fmt.Sprintf("branch %s not found in repo %s", branch, repo), Status: http.StatusNotFound}
 	}
 	return ref, nil
 }
 
+// ListBranches implements the client.GitClient interface.
+func (m *MockClient) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	branches := []*scm.Reference{}
+	for k, sha := range m.branchHeads {
+		if name := strings.TrimPrefix(k, key(repo, "")); name != k {
+			branches = append(branches, &scm.Reference{Name: name, Path: "refs/heads/" + name, Sha: sha})
+		}
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, nil
+}
+
+// DeleteBranch implements the client.GitClient interface.
+func (m *MockClient) DeleteBranch(ctx context.Context, repo, branch string) error {
+	if m.DeleteBranchErr != nil {
+		return m.DeleteBranchErr
+	}
+	if _, ok := m.branchHeads[key(repo, branch)]; !ok {
+		return client.SCMError{Msg: fmt.Sprintf("branch %s not found in repo %s", branch, repo), Status: http.StatusNotFound}
+	}
+	delete(m.branchHeads, key(repo, branch))
+	m.deletedBranches[key(repo, branch)] = true
+	return nil
+}
+
+// CreateFork implements the client.GitClient interface.
+//
+// The forks added with AddFork are returned, otherwise a fork is created with
+// the branches of the repository, in the owner, or in "mock-user" if the owner
+// is empty.
+func (m *MockClient) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	if m.CreateForkErr != nil {
+		return "", m.CreateForkErr
+	}
+	k := key(repo, owner)
+	if fork, ok := m.forks[k]; ok {
+		return fork, nil
+	}
+	if owner == "" {
+		owner = "mock-user"
+	}
+	fork := owner + "/" + repo[strings.LastIndex(repo, "/")+1:]
+	branches, _ := m.ListBranches(ctx, repo)
+	for _, branch := range branches {
+		m.branchHeads[key(fork, branch.Name)] = branch.Sha
+	}
+	m.forks[k] = fork
+	m.createdForks[key(repo, fork)] = true
+	return fork, nil
+}
+
+// SyncFork implements the client.GitClient interface.
+//
+// The head of the branch in the fork is moved to the head of the branch in the
+// repository that it was forked from with CreateFork.
+func (m *MockClient) SyncFork(ctx context.Context, fork, branch string) error {
+	if m.SyncForkErr != nil {
+		return m.SyncForkErr
+	}
+	for k, f := range m.forks {
+		if f != fork {
+			continue
+		}
+		repo := k[:strings.LastIndex(k, ":")]
+		if sha, ok := m.branchHeads[key(repo, branch)]; ok {
+			m.branchHeads[key(fork, branch)] = sha
+		}
+	}
+	m.syncedForks[key(fork, branch)] = true
+	return nil
+}
+
+// GetCommit implements the client.GitClient interface.
+//
+// Only the commits added with AddCommit are found.
+func (m *MockClient) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	date, ok := m.commitDates[key(repo, ref)]
+	if !ok {
+		return nil, client.SCMError{Msg: fmt.Sprintf("commit %s not found in repo %s", ref, repo), Status: http.StatusNotFound}
+	}
+	return &scm.Commit{
+		Sha:       ref,
+		Author:    scm.Signature{Date: date},
+		Committer: scm.Signature{Date: date},
+	}, nil
+}
+
 // AddFileContents is a mock method for setting up a fixture for
 // GetFileContents.
 func (m *MockClient) AddFileContents(repo, path, ref string, body []byte) {
@@ -114,11 +468,66 @@ func (m *MockClient) GetUpdatedContents(repo, path, ref string) []byte {
 	return c
 }
 
+// GetUpdatedMode returns the mode set for the file in a commit made with
+// CommitFiles, which is empty if no mode was set.
+func (m *MockClient) GetUpdatedMode(repo, path, ref string) client.FileMode {
+	return m.updatedModes[key(repo, path, ref)]
+}
+
+// AssertFileDeleted fails if the file was not deleted in a commit made with
+// CommitFiles.
+func (m *MockClient) AssertFileDeleted(repo, path, ref string) {
+	m.t.Helper()
+	if !m.deletedFiles[key(repo, path, ref)] {
+		m.t.Fatalf("file %s not deleted in repo %s ref %s", path, repo, ref)
+	}
+}
+
+// AssertFileMoved fails if the file was not moved to the newPath in a commit
+// made with CommitFiles or MoveFile.
+func (m *MockClient) AssertFileMoved(repo, path, newPath, ref string) {
+	m.t.Helper()
+	if moved := m.movedFiles[key(repo, path, ref)]; moved != newPath {
+		m.t.Fatalf("file %s not moved to %s in repo %s ref %s", path, newPath, repo, ref)
+	}
+}
+
+// AssertCommitCount fails if the number of commits made does not match.
+func (m *MockClient) AssertCommitCount(n int) {
+	m.t.Helper()
+	if m.commits != n {
+		m.t.Fatalf("expected %d commits: got %d", n, m.commits)
+	}
+}
+
 // AddBranchHead is a mock for setting up a response for GetBranchHead.
 func (m *MockClient) AddBranchHead(repo, branch, sha string) {
 	m.branchHeads[key(repo, branch)] = sha
 }
 
+// AddFork is a mock method for setting up an existing fork of the repository
+// owned by the owner for CreateFork.
+func (m *MockClient) AddFork(repo, owner, fork string) {
+	m.forks[key(repo, owner)] = fork
+}
+
+// AddCommit is a mock method for setting up a commit for GetCommit.
+func (m *MockClient) AddCommit(repo, sha string, date time.Time) {
+	m.commitDates[key(repo, sha)] = date
+}
+
+// AddPullRequest is a mock method for setting up an existing PullRequest for
+// FindPullRequest and ListPullRequests.
+func (m *MockClient) AddPullRequest(repo string, pr *scm.PullRequest) {
+	m.pullRequests[repo] = append(m.pullRequests[repo], pr)
+}
+
+// AddStatus is a mock method for setting up a commit status for
+// ListStatuses, the most recent status should be added first.
+func (m *MockClient) AddStatus(repo, ref string, status *scm.Status) {
+	m.statuses[key(repo, ref)] = append(m.statuses[key(repo, ref)], status)
+}
+
 // AssertBranchCreated fails if no matching branch was created using
 // CreateBranch.
 func (m *MockClient) AssertBranchCreated(repo, branch, sha string) {
@@ -137,6 +546,23 @@ func (m *MockClient) RefuteBranchCreated(repo, branch, sha string) {
 	}
 }
 
+// AssertForkCreated fails if no matching fork was created using CreateFork.
+func (m *MockClient) AssertForkCreated(repo, fork string) {
+	m.t.Helper()
+	if _, ok := m.createdForks[key(repo, fork)]; !ok {
+		m.t.Fatalf("fork %s of repo %s not created", fork, repo)
+	}
+}
+
+// AssertForkSynced fails if the branch in the fork was not synced using
+// SyncFork.
+func (m *MockClient) AssertForkSynced(fork, branch string) {
+	m.t.Helper()
+	if !m.syncedForks[key(fork, branch)] {
+		m.t.Fatalf("branch %s in fork %s not synced", branch, fork)
+	}
+}
+
 // AssertPullRequestCreated fails if no matching PullRequest was created.
 func (m *MockClient) AssertPullRequestCreated(repo string, inp *scm.PullRequestInput) {
 	m.t.Helper()
@@ -159,6 +585,58 @@ func (m *MockClient) RefutePullRequestCreated(repo string, inp *scm.PullRequestI
 	}
 }
 
+// AssertPullRequestOptions fails if the PullRequest was not created with the
+// matching options.
+func (m *MockClient) AssertPullRequestOptions(repo string, number int, opts client.PullRequestOptions) {
+	m.t.Helper()
+	if !reflect.DeepEqual(m.pullRequestOptions[key(repo, strconv.Itoa(number))], opts) {
+		m.t.Fatalf("pullrequest %d not created with options %#v in repo %s", number, opts, repo)
+	}
+}
+
+// AssertPullRequestUpdated fails if the PullRequest was not updated with the
+// matching input.
+func (m *MockClient) AssertPullRequestUpdated(repo string, number int, inp *scm.PullRequestInput) {
+	m.t.Helper()
+	if !reflect.DeepEqual(m.updatedPullRequests[key(repo, strconv.Itoa(number))], inp) {
+		m.t.Fatalf("pullrequest %d not updated in repo %s", number, repo)
+	}
+}
+
+// AssertPullRequestMerged fails if the PullRequest was not merged with the
+// matching options.
+func (m *MockClient) AssertPullRequestMerged(repo string, number int, opts client.MergeOptions) {
+	m.t.Helper()
+	merged, ok := m.mergedPullRequests[key(repo, strconv.Itoa(number))]
+	if !ok || merged != opts {
+		m.t.Fatalf("pullrequest %d not merged with options %#v in repo %s", number, opts, repo)
+	}
+}
+
+// RefutePullRequestMerged fails if the PullRequest was merged.
+func (m *MockClient) RefutePullRequestMerged(repo string, number int) {
+	m.t.Helper()
+	if _, ok := m.mergedPullRequests[key(repo, strconv.Itoa(number))]; ok {
+		m.t.Fatalf("pullrequest %d was merged in repo %s", number, repo)
+	}
+}
+
+// AssertBranchDeleted fails if the branch was not deleted with DeleteBranch.
+func (m *MockClient) AssertBranchDeleted(repo, branch string) {
+	m.t.Helper()
+	if !m.deletedBranches[key(repo, branch)] {
+		m.t.Fatalf("branch %s not deleted in repo %s", branch, repo)
+	}
+}
+
+// RefuteBranchDeleted fails if the branch was deleted with DeleteBranch.
+func (m *MockClient) RefuteBranchDeleted(repo, branch string) {
+	m.t.Helper()
+	if m.deletedBranches[key(repo, branch)] {
+		m.t.Fatalf("branch %s was deleted in repo %s", branch, repo)
+	}
+}
+
 // AssertNoBranchesCreated fails if a branch was created.
 func (m *MockClient) AssertNoBranchesCreated() {
 	if l := len(m.createdBranches); l > 0 {
@@ -180,6 +658,10 @@ func (m *MockClient) AssertNoInteractions() {
 		m.t.Fatalf("files were updated %#v", m.updatedFiles)
 	}
 
+	if len(m.deletedFiles) != 0 {
+		m.t.Fatalf("files were deleted %#v", m.deletedFiles)
+	}
+
 	if len(m.createdBranches) != 0 {
 		m.t.Fatalf("branches created %#v", m.createdBranches)
 	}
@@ -187,6 +669,18 @@ func (m *MockClient) AssertNoInteractions() {
 	if len(m.createdPullRequests) != 0 {
 		m.t.Fatalf("pull requests created %#v", m.createdPullRequests)
 	}
+
+	if len(m.createdForks) != 0 {
+		m.t.Fatalf("forks created %#v", m.createdForks)
+	}
+}
+
+// commit records a new commit to the branch, and moves the branch head to it.
+func (m *MockClient) commit(repo, branch string) string {
+	m.commits++
+	sha := bytesSha1([]byte(fmt.Sprintf("%s:%s:%d", repo, branch, m.commits)))
+	m.branchHeads[key(repo, branch)] = sha
+	return sha
 }
 
 func key(s ...string) string {
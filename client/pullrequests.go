@@ -0,0 +1,367 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+// FindPullRequest gets a PullRequest by number.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	pr, r, err := c.scmClient.PullRequests.Find(ctx, repo, number)
+	if err := responseError(fmt.Sprintf("failed to get pull request %d from repo %s", number, repo), r, err); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// ListPullRequests lists the PullRequests in a repository.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	prs, r, err := c.scmClient.PullRequests.List(ctx, repo, opts)
+	if err := responseError(fmt.Sprintf("failed to list pull requests in repo %s", repo), r, err); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// UpdatePullRequest updates the title, body and target branch of an existing
+// PullRequest, the source branch can't be changed.
+//
+// This is supported for GitHub and GitLab, other drivers return an error
+// wrapping scm.ErrNotSupported.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	msg := fmt.Sprintf("failed to update pull request %d in repo %s", number, repo)
+	var err error
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		in := map[string]string{"title": inp.Title, "body": inp.Body}
+		if inp.Target != "" {
+			in["base"] = inp.Target
+		}
+		_, err = c.do(ctx, msg, "PATCH", fmt.Sprintf("repos/%s/pulls/%d", repo, number), in, nil)
+	case scm.DriverGitlab:
+		in := map[string]string{"title": inp.Title, "description": inp.Body}
+		if inp.Target != "" {
+			in["target_branch"] = inp.Target
+		}
+		_, err = c.do(ctx, msg, "PUT", fmt.Sprintf("api/v4/projects/%s/merge_requests/%d", encodeGitLabRepo(repo), number), in, nil)
+	default:
+		return nil, fmt.Errorf("updating pull requests with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.FindPullRequest(ctx, repo, number)
+}
+
+// PullRequestOptions are the attributes of a PullRequest that can't be
+// provided in an scm.PullRequestInput.
+type PullRequestOptions struct {
+	Labels        []string
+	Reviewers     []string // usernames of the requested reviewers
+	TeamReviewers []string // slugs of the requested teams, only supported by GitHub
+	Assignees     []string // usernames of the assignees
+	Milestone     int      // the milestone number in GitHub, or ID in GitLab
+	Draft         bool
+	SourceRepo    string // the fork that the source branch is in, e.g. my-user/my-repo, if it's not in the repository
+}
+
+// IsZero returns true if no options are set.
+func (o PullRequestOptions) IsZero() bool {
+	return len(o.Labels) == 0 && len(o.Reviewers) == 0 && len(o.TeamReviewers) == 0 &&
+		len(o.Assignees) == 0 && o.Milestone == 0 && !o.Draft && o.SourceRepo == ""
+}
+
+// CreatePullRequestWithOptions creates a PullRequest with the provided input
+// and options.
+//
+// The options are supported for GitHub and GitLab, other drivers return an
+// error wrapping scm.ErrNotSupported if any options are provided, without
+// creating the PullRequest.
+//
+// If a SourceRepo is provided, the PullRequest is opened from the branch in
+// that fork, and in GitLab, the merge request is created with the credentials
+// for the fork.
+//
+// In GitHub, the options are applied after creating the PullRequest, if this
+// fails, the PullRequest is returned along with the error.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts PullRequestOptions) (*scm.PullRequest, error) {
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		return c.createPullRequestGitHub(ctx, repo, inp, opts)
+	case scm.DriverGitlab:
+		return c.createPullRequestGitLab(ctx, repo, inp, opts)
+	}
+	if !opts.IsZero() {
+		return nil, fmt.Errorf("pull request options with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	return c.CreatePullRequest(ctx, repo, inp)
+}
+
+type ghPullRequestInput struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft"`
+}
+
+type ghIssueUpdate struct {
+	Assignees []string `json:"assignees,omitempty"`
+	Milestone int      `json:"milestone,omitempty"`
+}
+
+type ghReviewRequest struct {
+	Reviewers     []string `json:"reviewers,omitempty"`
+	TeamReviewers []string `json:"team_reviewers,omitempty"`
+}
+
+func (c *SCMClient) createPullRequestGitHub(ctx context.Context, repo string, inp *scm.PullRequestInput, opts PullRequestOptions) (*scm.PullRequest, error) {
+	created := &struct {
+		Number int `json:"number"`
+	}{}
+	head := inp.Source
+	if opts.SourceRepo != "" {
+		head = strings.Split(opts.SourceRepo, "/")[0] + ":" + inp.Source
+	}
+	_, err := c.do(ctx, fmt.Sprintf("failed to create pull request in repo %s", repo), "POST", fmt.Sprintf("repos/%s/pulls", repo),
+		&ghPullRequestInput{Title: inp.Title, Body: inp.Body, Head: head, Base: inp.Target, Draft: opts.Draft}, created)
+	if err != nil {
+		return nil, err
+	}
+	pr := &scm.PullRequest{Number: created.Number, Title: inp.Title, Body: inp.Body, Source: inp.Source, Target: inp.Target, Fork: opts.SourceRepo}
+
+	msg := fmt.Sprintf("failed to apply options to pull request %d in repo %s", created.Number, repo)
+	if len(opts.Labels) > 0 {
+		_, err := c.do(ctx, msg, "POST", fmt.Sprintf("repos/%s/issues/%d/labels", repo, created.Number),
+			map[string][]string{"labels": opts.Labels}, nil)
+		if err != nil {
+			return pr, err
+		}
+	}
+	if len(opts.Reviewers) > 0 || len(opts.TeamReviewers) > 0 {
+		_, err := c.do(ctx, msg, "POST", fmt.Sprintf("repos/%s/pulls/%d/requested_reviewers", repo, created.Number),
+			&ghReviewRequest{Reviewers: opts.Reviewers, TeamReviewers: opts.TeamReviewers}, nil)
+		if err != nil {
+			return pr, err
+		}
+	}
+	if len(opts.Assignees) > 0 || opts.Milestone != 0 {
+		_, err := c.do(ctx, msg, "PATCH", fmt.Sprintf("repos/%s/issues/%d", repo, created.Number),
+			&ghIssueUpdate{Assignees: opts.Assignees, Milestone: opts.Milestone}, nil)
+		if err != nil {
+			return pr, err
+		}
+	}
+	return c.FindPullRequest(ctx, repo, created.Number)
+}
+
+type glMergeRequestInput struct {
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	Labels          string `json:"labels,omitempty"`
+	AssigneeIDs     []int  `json:"assignee_ids,omitempty"`
+	ReviewerIDs     []int  `json:"reviewer_ids,omitempty"`
+	MilestoneID     int    `json:"milestone_id,omitempty"`
+	TargetProjectID int    `json:"target_project_id,omitempty"`
+}
+
+func (c *SCMClient) createPullRequestGitLab(ctx context.Context, repo string, inp *scm.PullRequestInput, opts PullRequestOptions) (*scm.PullRequest, error) {
+	if len(opts.TeamReviewers) > 0 {
+		return nil, fmt.Errorf("team reviewers with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	in := &glMergeRequestInput{
+		Title:        inp.Title,
+		Description:  inp.Body,
+		SourceBranch: inp.Source,
+		TargetBranch: inp.Target,
+		Labels:       strings.Join(opts.Labels, ","),
+		MilestoneID:  opts.Milestone,
+	}
+	if opts.Draft {
+		in.Title = "Draft: " + in.Title
+	}
+	var err error
+	if in.AssigneeIDs, err = c.gitLabUserIDs(ctx, opts.Assignees); err != nil {
+		return nil, err
+	}
+	if in.ReviewerIDs, err = c.gitLabUserIDs(ctx, opts.Reviewers); err != nil {
+		return nil, err
+	}
+
+	sourceRepo := repo
+	if opts.SourceRepo != "" {
+		sourceRepo = opts.SourceRepo
+		if in.TargetProjectID, err = c.gitLabProjectID(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+
+	created := &struct {
+		IID int `json:"iid"`
+	}{}
+	_, err = c.do(ctx, fmt.Sprintf("failed to create merge request in repo %s", repo), "POST",
+		fmt.Sprintf("api/v4/projects/%s/merge_requests", encodeGitLabRepo(sourceRepo)), in, created)
+	if err != nil {
+		return nil, err
+	}
+	return c.FindPullRequest(ctx, repo, created.IID)
+}
+
+// gitLabUserIDs looks up the IDs of GitLab users, which are needed to assign
+// users to merge requests.
+func (c *SCMClient) gitLabUserIDs(ctx context.Context, usernames []string) ([]int, error) {
+	ids := []int{}
+	for _, username := range usernames {
+		users := []struct {
+			ID int `json:"id"`
+		}{}
+		_, err := c.do(ctx, fmt.Sprintf("failed to find user %s", username), "GET",
+			fmt.Sprintf("api/v4/users?username=%s", url.QueryEscape(username)), nil, &users)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("failed to find user %s", username)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	return ids, nil
+}
+
+// MergeMethod is the way the commits of a PullRequest are added to the target
+// branch.
+type MergeMethod string
+
+const (
+	// MergeMethodMerge creates a merge commit.
+	MergeMethodMerge MergeMethod = "merge"
+	// MergeMethodSquash squashes the commits into a single commit.
+	MergeMethodSquash MergeMethod = "squash"
+	// MergeMethodRebase rebases the commits onto the target branch.
+	MergeMethodRebase MergeMethod = "rebase"
+)
+
+// MergeOptions configures how a PullRequest is merged.
+type MergeOptions struct {
+	Method        MergeMethod // defaults to MergeMethodMerge
+	CommitTitle   string      // optional, only supported by GitHub
+	CommitMessage string      // optional
+	SHA           string      // optional, the merge fails if the head of the PullRequest doesn't match
+	DeleteBranch  bool        // delete the source branch after merging
+}
+
+// ErrBranchNotDeleted is wrapped by errors returned by MergePullRequest when
+// the PullRequest was merged, but the source branch could not be deleted.
+var ErrBranchNotDeleted = errors.New("branch not deleted after merging")
+
+// MergePullRequest merges a PullRequest.
+//
+// This is supported for GitHub and GitLab, other drivers only support merging
+// with the default options, and return an error wrapping scm.ErrNotSupported
+// otherwise. Rebasing is not supported by GitLab.
+//
+// With GitHub, the source branch is deleted from the repository that the
+// PullRequest is from, which may be a fork, and if it can't be deleted, an
+// error wrapping ErrBranchNotDeleted is returned, as the PullRequest was
+// merged.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) MergePullRequest(ctx context.Context, repo string, number int, opts MergeOptions) error {
+	defer c.cache.invalidateRef(repo, "")
+	switch c.scmClient.Driver {
+	case scm.DriverGithub:
+		return c.mergePullRequestGitHub(ctx, repo, number, opts)
+	case scm.DriverGitlab:
+		return c.mergePullRequestGitLab(ctx, repo, number, opts)
+	}
+	if opts != (MergeOptions{}) && opts != (MergeOptions{Method: MergeMethodMerge}) {
+		return fmt.Errorf("merge options with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	r, err := c.scmClient.PullRequests.Merge(ctx, repo, number)
+	return responseError(fmt.Sprintf("failed to merge pull request %d in repo %s", number, repo), r, err)
+}
+
+type ghMergeInput struct {
+	MergeMethod   string `json:"merge_method,omitempty"`
+	CommitTitle   string `json:"commit_title,omitempty"`
+	CommitMessage string `json:"commit_message,omitempty"`
+	SHA           string `json:"sha,omitempty"`
+}
+
+func (c *SCMClient) mergePullRequestGitHub(ctx context.Context, repo string, number int, opts MergeOptions) error {
+	var headRepo, source string
+	if opts.DeleteBranch {
+		pr, err := c.FindPullRequest(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		headRepo, source = repo, pr.Source
+		if pr.Fork != "" {
+			headRepo = pr.Fork
+		}
+	}
+	_, err := c.do(ctx, fmt.Sprintf("failed to merge pull request %d in repo %s", number, repo), "PUT",
+		fmt.Sprintf("repos/%s/pulls/%d/merge", repo, number), &ghMergeInput{
+			MergeMethod:   string(opts.Method),
+			CommitTitle:   opts.CommitTitle,
+			CommitMessage: opts.CommitMessage,
+			SHA:           opts.SHA,
+		}, nil)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return nil
+	}
+	if err := c.DeleteBranch(ctx, headRepo, source); err != nil {
+		return fmt.Errorf("pull request %d in repo %s was merged: %v: %w", number, repo, err, ErrBranchNotDeleted)
+	}
+	return nil
+}
+
+type glMergeInput struct {
+	Squash                   bool   `json:"squash,omitempty"`
+	MergeCommitMessage       string `json:"merge_commit_message,omitempty"`
+	SquashCommitMessage      string `json:"squash_commit_message,omitempty"`
+	SHA                      string `json:"sha,omitempty"`
+	ShouldRemoveSourceBranch bool   `json:"should_remove_source_branch,omitempty"`
+}
+
+func (c *SCMClient) mergePullRequestGitLab(ctx context.Context, repo string, number int, opts MergeOptions) error {
+	if opts.Method == MergeMethodRebase {
+		return fmt.Errorf("rebase merges with driver %s: %w", c.scmClient.Driver, scm.ErrNotSupported)
+	}
+	in := &glMergeInput{
+		Squash:                   opts.Method == MergeMethodSquash,
+		SHA:                      opts.SHA,
+		ShouldRemoveSourceBranch: opts.DeleteBranch,
+	}
+	if in.Squash {
+		in.SquashCommitMessage = opts.CommitMessage
+	} else {
+		in.MergeCommitMessage = opts.CommitMessage
+	}
+	_, err := c.do(ctx, fmt.Sprintf("failed to merge merge request %d in repo %s", number, repo), "PUT",
+		fmt.Sprintf("api/v4/projects/%s/merge_requests/%d/merge", encodeGitLabRepo(repo), number), in, nil)
+	return err
+}
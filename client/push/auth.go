@@ -0,0 +1,100 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultUsername = "git"
+	defaultTokenKey = "token"
+)
+
+// Credentials identifies the secret with the credentials for accessing the
+// repositories.
+//
+// HTTPS URLs are accessed with basic auth, using the token as the password,
+// and SSH URLs with the private key.
+type Credentials struct {
+	Secret        types.NamespacedName
+	Username      string // defaults to "git"
+	TokenKey      string // the key in the Secret with the HTTPS token, defaults to "token"
+	SSHKeyKey     string // the key in the Secret with the PEM encoded SSH private key
+	KnownHostsKey string // optional, the key in the Secret with the SSH known_hosts, the user's known_hosts are used otherwise
+}
+
+// authMethod returns the auth for accessing the endpoint, or nil if no
+// credentials are needed, e.g. for local repositories.
+func (c *Client) authMethod(ctx context.Context, ep *transport.Endpoint) (transport.AuthMethod, error) {
+	if c.secrets == nil {
+		return nil, nil
+	}
+	username := c.creds.Username
+	if username == "" {
+		username = defaultUsername
+	}
+
+	switch ep.Protocol {
+	case "http", "https":
+		key := c.creds.TokenKey
+		if key == "" {
+			key = defaultTokenKey
+		}
+		token, err := c.secrets.SecretToken(ctx, c.creds.Secret, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+		return &http.BasicAuth{Username: username, Password: token}, nil
+	case "ssh":
+		if ep.User != "" {
+			username = ep.User
+		}
+		return c.sshAuth(ctx, username)
+	}
+	return nil, nil
+}
+
+func (c *Client) sshAuth(ctx context.Context, username string) (transport.AuthMethod, error) {
+	key, err := c.secrets.SecretToken(ctx, c.creds.Secret, c.creds.SSHKeyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSH key: %w", err)
+	}
+	auth, err := ssh.NewPublicKeys(username, []byte(key), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+	if c.creds.KnownHostsKey == "" {
+		return auth, nil
+	}
+
+	knownHosts, err := c.secrets.SecretToken(ctx, c.creds.Secret, c.creds.KnownHostsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSH known hosts: %w", err)
+	}
+	// The known hosts can only be loaded from files, they are parsed when the
+	// callback is created, so the file is not needed afterwards.
+	f, err := ioutil.TempFile("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(knownHosts); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	auth.HostKeyCallback, err = ssh.NewKnownHostsCallback(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH known hosts: %w", err)
+	}
+	return auth, nil
+}
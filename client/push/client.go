@@ -0,0 +1,390 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/local"
+	"github.com/ocraviotto/pkg/secrets"
+)
+
+var _ client.GitClient = (*Client)(nil)
+
+// pushRejections are fragments of the errors returned when a push is rejected
+// because the branch was changed by someone else.
+var pushRejections = []string{
+	"non-fast-forward",
+	"required to be", // RequireRemoteRefs
+	"fetch first",
+	"stale info",
+}
+
+// Client implements the client.GitClient interface by fetching from, and
+// pushing to, repositories over the Git protocol, for providers where go-scm's
+// support for the contents API is missing or incomplete.
+//
+// Each operation fetches only the refs it needs, with a depth of one, into
+// memory, so no state is kept between operations.
+//
+// PullRequests and commit statuses are managed with the wrapped GitClient, e.g.
+// a client.SCMClient.
+type Client struct {
+	client.GitClient
+	urlFormat string
+	secrets   secrets.SecretGetter
+	creds     Credentials
+}
+
+// New creates and returns a new Client.
+//
+// The urlFormat is used to get the URL of a repository from its name, e.g.
+// "https://bitbucket.example.com/scm/%s.git" or "git@github.com:%s.git".
+//
+// The credentials are looked up with the SecretGetter for each operation, if
+// it's nil, no credentials are used.
+func New(c client.GitClient, urlFormat string, s secrets.SecretGetter, creds Credentials) *Client {
+	return &Client{
+		GitClient: c,
+		urlFormat: urlFormat,
+		secrets:   s,
+		creds:     creds,
+	}
+}
+
+// GetFile fetches the ref, which can be a branch, tag, or the SHA of the head
+// of a branch, and reads the file at path from it.
+func (c *Client) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	s, err := c.fetchRef(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.local.GetFile(ctx, repo, ref, path)
+}
+
+// UpdateFile fetches the branch, commits the content to the file at path, and
+// pushes the branch.
+func (c *Client) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileUpdate, Path: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// DeleteFile fetches the branch, commits the removal of the file at path, and
+// pushes the branch.
+func (c *Client) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileDelete, Path: path, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// MoveFile fetches the branch, commits the move of the file at path to the
+// newPath, and pushes the branch.
+func (c *Client) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	_, err := c.CommitFiles(ctx, repo, branch, message, signature, []client.FileChange{
+		{Action: client.FileMove, Path: newPath, PreviousPath: path, Content: content, PreviousSHA: previousSHA},
+	})
+	return err
+}
+
+// CommitFiles fetches the branch, creates a single commit that applies all the
+// changes, and pushes the branch.
+//
+// The push only succeeds if the branch was not changed since it was fetched,
+// otherwise an error with a 409 status is returned.
+func (c *Client) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if err := s.fetch(ctx, name); err != nil {
+		return "", err
+	}
+	old, err := s.repo.Reference(name, false)
+	if err != nil {
+		return "", err
+	}
+	sha, err := s.local.CommitFiles(ctx, repo, branch, message, signature, changes)
+	if err != nil {
+		return "", err
+	}
+	return sha, s.push(ctx, name, old.Hash())
+}
+
+// CreateBranch creates a new branch pointing at the commit sha, which must be
+// the head of an existing branch or tag.
+func (c *Client) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return err
+	}
+	refs, err := s.listRemote(ctx)
+	if err != nil {
+		return err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if findRef(refs, name) != nil {
+		return client.SCMError{
+			Msg:         fmt.Sprintf("failed to create branch %s in repo %s", branch, repo),
+			Status:      http.StatusUnprocessableEntity,
+			ResponseMsg: "Reference already exists",
+		}
+	}
+	source := findRefByHash(refs, sha)
+	if source == nil {
+		return client.SCMError{
+			Msg:         fmt.Sprintf("failed to create branch %s in repo %s", branch, repo),
+			Status:      http.StatusUnprocessableEntity,
+			ResponseMsg: fmt.Sprintf("%s is not the head of a branch", sha),
+		}
+	}
+	if err := s.fetch(ctx, source.Name()); err != nil {
+		return err
+	}
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(name, source.Hash())); err != nil {
+		return err
+	}
+	return s.push(ctx, name, plumbing.ZeroHash)
+}
+
+// GetBranchHead returns the SHA of the commit at the head of the branch.
+func (c *Client) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	refs, err := s.listRemote(ctx)
+	if err != nil {
+		return "", err
+	}
+	ref := findRef(refs, plumbing.NewBranchReferenceName(branch))
+	if ref == nil {
+		return "", notFound(fmt.Sprintf("failed to find branch %s in repo %s", branch, repo))
+	}
+	return ref.Hash().String(), nil
+}
+
+// ListBranches returns all the branches in the repository, sorted by name.
+func (c *Client) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+	branches := []*scm.Reference{}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, &scm.Reference{
+				Name: ref.Name().Short(),
+				Path: ref.Name().String(),
+				Sha:  ref.Hash().String(),
+			})
+		}
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, nil
+}
+
+// DeleteBranch deletes a branch from the repository.
+func (c *Client) DeleteBranch(ctx context.Context, repo, branch string) error {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return err
+	}
+	refs, err := s.listRemote(ctx)
+	if err != nil {
+		return err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if findRef(refs, name) == nil {
+		return notFound(fmt.Sprintf("failed to find branch %s in repo %s", branch, repo))
+	}
+	err = s.remote.PushContext(ctx, &git.PushOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(":" + name.String())},
+		Auth:     s.auth,
+	})
+	return convertError(fmt.Sprintf("failed to delete branch %s in repo %s", branch, repo), err)
+}
+
+// GetCommit fetches the ref, which can be a branch, tag, or the SHA of the
+// head of a branch, and returns the commit.
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	s, err := c.fetchRef(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.local.GetCommit(ctx, repo, ref)
+}
+
+// session is an in-memory repository, with the remote for a repository.
+type session struct {
+	repo   *git.Repository
+	remote *git.Remote
+	local  *local.Client
+	auth   transport.AuthMethod
+	url    string
+}
+
+func (c *Client) open(ctx context.Context, repo string) (*session, error) {
+	url := fmt.Sprintf(c.urlFormat, repo)
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL for repo %s: %w", repo, err)
+	}
+	auth, err := c.authMethod(ctx, ep)
+	if err != nil {
+		return nil, err
+	}
+	r, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	if err != nil {
+		return nil, err
+	}
+	l, err := local.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return &session{repo: r, remote: remote, local: l, auth: auth, url: url}, nil
+}
+
+// fetchRef opens the repository and fetches the ref, which is resolved in the
+// same way as Git does, and can also be the SHA of the head of a ref.
+func (c *Client) fetchRef(ctx context.Context, repo, ref string) (*session, error) {
+	s, err := c.open(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found *plumbing.Reference
+	for _, rule := range plumbing.RefRevParseRules {
+		if found = findRef(refs, plumbing.ReferenceName(fmt.Sprintf(rule, ref))); found != nil {
+			break
+		}
+	}
+	if found == nil {
+		found = findRefByHash(refs, ref)
+	}
+	if found == nil {
+		return nil, notFound(fmt.Sprintf("failed to find ref %s in repo %s", ref, repo))
+	}
+	return s, s.fetch(ctx, found.Name())
+}
+
+func (s *session) fetch(ctx context.Context, name plumbing.ReferenceName) error {
+	err := s.remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", name, name))},
+		Depth:    1,
+		Auth:     s.auth,
+		Tags:     git.NoTags,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	if errors.Is(err, git.NoMatchingRefSpecError{}) {
+		return notFound(fmt.Sprintf("failed to find %s in %s", name.Short(), s.url))
+	}
+	return convertError(fmt.Sprintf("failed to fetch %s from %s", name.Short(), s.url), err)
+}
+
+// push updates the remote ref to match the local ref, if the remote ref still
+// points at old, which must be the zero hash if the ref is being created.
+func (s *session) push(ctx context.Context, name plumbing.ReferenceName, old plumbing.Hash) error {
+	opts := &git.PushOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", name, name))},
+		Auth:     s.auth,
+	}
+	if !old.IsZero() {
+		opts.RequireRemoteRefs = []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", old, name))}
+	}
+	err := s.remote.PushContext(ctx, opts)
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return convertError(fmt.Sprintf("failed to push %s to %s", name.Short(), s.url), err)
+}
+
+func (s *session) listRemote(ctx context.Context) ([]*plumbing.Reference, error) {
+	refs, err := s.remote.ListContext(ctx, &git.ListOptions{Auth: s.auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return []*plumbing.Reference{}, nil
+	}
+	if err != nil {
+		return nil, convertError(fmt.Sprintf("failed to list refs in %s", s.url), err)
+	}
+	return refs, nil
+}
+
+func findRef(refs []*plumbing.Reference, name plumbing.ReferenceName) *plumbing.Reference {
+	for _, ref := range refs {
+		if ref.Name() == name && ref.Type() == plumbing.HashReference {
+			return ref
+		}
+	}
+	return nil
+}
+
+func findRefByHash(refs []*plumbing.Reference, sha string) *plumbing.Reference {
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference && ref.Hash().String() == sha && ref.Name() != plumbing.HEAD {
+			return ref
+		}
+	}
+	return nil
+}
+
+// convertError converts errors from the Git protocol to client.SCMErrors with
+// the equivalent HTTP status codes, so that they can be checked with the
+// classifiers in the client package, e.g. client.IsNotFound.
+func convertError(msg string, err error) error {
+	if err == nil {
+		return nil
+	}
+	status := 0
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		status = http.StatusUnauthorized
+	case errors.Is(err, transport.ErrAuthorizationFailed):
+		status = http.StatusForbidden
+	case errors.Is(err, git.ErrForceNeeded):
+		status = http.StatusConflict
+	default:
+		for _, s := range pushRejections {
+			if strings.Contains(err.Error(), s) {
+				status = http.StatusConflict
+			}
+		}
+	}
+	if status == 0 {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	return client.SCMError{Msg: msg, Status: status, ResponseMsg: err.Error()}
+}
+
+func notFound(msg string) client.SCMError {
+	return client.SCMError{Msg: msg, Status: http.StatusNotFound, ResponseMsg: "Not Found"}
+}
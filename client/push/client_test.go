@@ -0,0 +1,364 @@
+package push
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/ocraviotto/go-scm/scm"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/secrets"
+)
+
+const (
+	testRepo   = "testorg/testrepo"
+	testBranch = "main"
+	testFile   = "environments/test/config.yaml"
+	testToken  = "test-token"
+
+	gitHTTPBackend = "/usr/lib/git-core/git-http-backend"
+)
+
+var (
+	testSecret    = types.NamespacedName{Name: "git-credentials", Namespace: "test-ns"}
+	testSignature = scm.Signature{
+		Name:  "Test User",
+		Email: "test@example.com",
+		Date:  time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC),
+	}
+)
+
+func TestGetFile(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	content, err := c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content.Data) != "test: old\n" {
+		t.Fatalf("got %q, want %q", content.Data, "test: old\n")
+	}
+}
+
+func TestGetFileWithSHA(t *testing.T) {
+	c, _ := newTestClient(t)
+	sha := mustBranchHead(t, c, testBranch)
+
+	content, err := c.GetFile(context.Background(), testRepo, sha, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content.Data) != "test: old\n" {
+		t.Fatalf("got %q, want %q", content.Data, "test: old\n")
+	}
+}
+
+func TestGetFileWithMissingRef(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	_, err := c.GetFile(context.Background(), testRepo, "unknown", testFile)
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestGetFileWithMissingRepo(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	_, err := c.GetFile(context.Background(), "testorg/unknown", testBranch, testFile)
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	c, _ := newTestClient(t)
+	current, err := c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", current.Sha, testSignature, []byte("test: new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, testBranch, testFile, "test: new\n")
+	assertFileContent(t, c, testBranch, "README.md", "testing\n")
+	commit, err := c.GetCommit(context.Background(), testRepo, testBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "update config" || commit.Author.Email != testSignature.Email {
+		t.Fatalf("incorrect commit: %#v", commit)
+	}
+}
+
+func TestUpdateFileWithStaleSHA(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	err := c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", "4b825dc642cb6eb9a060e54bf8d69288fbee4904", testSignature, []byte("test: new\n"))
+
+	if !client.IsConflict(err) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	err := c.DeleteFile(context.Background(), testRepo, testBranch, testFile, "remove config", "", testSignature, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.GetFile(context.Background(), testRepo, testBranch, testFile)
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestPushRequiresUnchangedBranch(t *testing.T) {
+	c, _ := newTestClient(t)
+	s, err := c.open(context.Background(), testRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fetch(context.Background(), "refs/heads/main"); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := s.repo.Reference("refs/heads/main", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.local.CommitFiles(context.Background(), testRepo, testBranch, "update config", testSignature, []client.FileChange{
+		{Action: client.FileUpdate, Path: testFile, Content: []byte("test: stale\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The branch is changed after the fetch.
+	err = c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", "", testSignature, []byte("test: new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.push(context.Background(), ref.Name(), ref.Hash())
+
+	if !client.IsConflict(err) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	assertFileContent(t, c, testBranch, testFile, "test: new\n")
+}
+
+func TestBranches(t *testing.T) {
+	c, _ := newTestClient(t)
+	head := mustBranchHead(t, c, testBranch)
+
+	if err := c.CreateBranch(context.Background(), testRepo, "test-branch", head); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateFile(context.Background(), testRepo, "test-branch", testFile, "update config", "", testSignature, []byte("test: new\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, "test-branch", testFile, "test: new\n")
+	assertFileContent(t, c, testBranch, testFile, "test: old\n")
+	branches, err := c.ListBranches(context.Background(), testRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 || branches[0].Name != testBranch || branches[1].Name != "test-branch" {
+		t.Fatalf("incorrect branches: %#v", branches)
+	}
+
+	if err := c.DeleteBranch(context.Background(), testRepo, "test-branch"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetBranchHead(context.Background(), testRepo, "test-branch")
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestCreateBranchThatExists(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	err := c.CreateBranch(context.Background(), testRepo, testBranch, mustBranchHead(t, c, testBranch))
+
+	if !client.IsValidation(err) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+}
+
+func TestDeleteMissingBranch(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	err := c.DeleteBranch(context.Background(), testRepo, "unknown")
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+}
+
+func TestPullRequestsUseGitClient(t *testing.T) {
+	c, m := newTestClient(t)
+	inp := &scm.PullRequestInput{Title: "test", Source: "test-branch", Target: testBranch}
+
+	if _, err := c.CreatePullRequest(context.Background(), testRepo, inp); err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestCreated(testRepo, inp)
+}
+
+func TestOverHTTPWithToken(t *testing.T) {
+	ts := newTestServer(t, newTestRepository(t))
+	s := secrets.NewSecretsStub()
+	s.StubSecret(testSecret, "token", testToken)
+	c := New(mock.New(t), ts.URL+"/%s.git", s, Credentials{Secret: testSecret})
+
+	err := c.UpdateFile(context.Background(), testRepo, testBranch, testFile, "update config", "", testSignature, []byte("test: new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileContent(t, c, testBranch, testFile, "test: new\n")
+}
+
+func TestOverHTTPWithBadToken(t *testing.T) {
+	ts := newTestServer(t, newTestRepository(t))
+	s := secrets.NewSecretsStub()
+	s.StubSecret(testSecret, "token", "bad-token")
+	c := New(mock.New(t), ts.URL+"/%s.git", s, Credentials{Secret: testSecret})
+
+	_, err := c.GetFile(context.Background(), testRepo, testBranch, testFile)
+
+	if !client.IsUnauthorized(err) {
+		t.Fatalf("got %v, want an unauthorized error", err)
+	}
+}
+
+// basicAuth only allows requests with the test token.
+func basicAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != testToken {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newTestServer serves the repositories in root with git http-backend, which
+// only accepts pushes from authenticated users.
+func newTestServer(t *testing.T, root string) *httptest.Server {
+	t.Helper()
+	if _, err := os.Stat(gitHTTPBackend); err != nil {
+		t.Skipf("%s is not available", gitHTTPBackend)
+	}
+	ts := httptest.NewServer(basicAuth(&cgi.Handler{
+		Path: gitHTTPBackend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1", "REMOTE_USER=git"},
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestClient(t *testing.T) (*Client, *mock.MockClient) {
+	t.Helper()
+	root := newTestRepository(t)
+	m := mock.New(t)
+	return New(m, filepath.Join(root, "%s.git"), nil, Credentials{}), m
+}
+
+// newTestRepository creates a bare repository for testRepo, with a commit on
+// the main branch, and returns the directory it's in.
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+	work := t.TempDir()
+	r, err := git.PlainInit(work, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{testFile: "test: old\n", "README.md": "testing\n"}
+	for name, body := range files {
+		if err := os.MkdirAll(filepath.Join(work, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(work, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: testSignature.Name, Email: testSignature.Email, When: testSignature.Date}
+	if _, err := wt.Commit("add files", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	bare, err := git.PlainClone(filepath.Join(root, testRepo+".git"), true, &git.CloneOptions{URL: work})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PlainInit names the default branch master.
+	if head.Name().Short() != testBranch {
+		main := plumbing.NewBranchReferenceName(testBranch)
+		if err := bare.Storer.SetReference(plumbing.NewHashReference(main, head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+		if err := bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, main)); err != nil {
+			t.Fatal(err)
+		}
+		if err := bare.Storer.RemoveReference(head.Name()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func mustBranchHead(t *testing.T, c *Client, branch string) string {
+	t.Helper()
+	sha, err := c.GetBranchHead(context.Background(), testRepo, branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+func assertFileContent(t *testing.T, c *Client, ref, path, want string) {
+	t.Helper()
+	content, err := c.GetFile(context.Background(), testRepo, ref, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.Data) != want {
+		t.Fatalf("got %q in %s, want %q", content.Data, path, want)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+// do sends a request directly to the upstream API, for the operations that
+// go-scm does not provide.
+//
+// The request is made using the transport and base URL of the wrapped
+// scm.Client, so authentication is handled in the same way as for go-scm
+// requests.
+//
+// If in is not nil, it is sent as a JSON body, and if out is not nil, the
+// response body is decoded into it.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code and the provided msg is returned.
+func (c *SCMClient) do(ctx context.Context, msg, method, path string, in, out interface{}) (*scm.Response, error) {
+	req := &scm.Request{
+		Method: method,
+		Path:   path,
+		Header: http.Header{"Accept": {"application/json"}},
+	}
+	if in != nil {
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(in); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Body = buf
+	}
+
+	res, err := c.scmClient.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorStatus(res.Status) {
+		return res, newSCMError(msg, res.Status, res.Body)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if out == nil {
+		return res, nil
+	}
+	return res, json.NewDecoder(res.Body).Decode(out)
+}
+
+// encodeGitLabRepo encodes a repository name as a GitLab project ID.
+func encodeGitLabRepo(repo string) string {
+	return strings.Replace(repo, "/", "%2F", -1)
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitMessages are fragments of the messages in 403 responses that GitHub
+// uses for secondary rate limits, which don't always provide headers.
+var rateLimitMessages = []string{
+	"secondary rate limit",
+	"abuse detection",
+}
+
+// RetryPolicy configures how requests to the upstream service are retried.
+//
+// Requests that are rejected because of rate limiting are retried after the
+// delay indicated by the Retry-After, X-RateLimit-Reset (GitHub) or
+// RateLimit-Reset (GitLab) headers, if it's no longer than MaxRateLimitWait.
+//
+// Requests that fail with a network error or a 5xx status are retried with
+// jittered exponential backoff, starting at MinBackoff. Only GET, HEAD and
+// OPTIONS requests are retried after network errors and 5xx responses, as the
+// change may have been made, e.g. retrying a PUT to the GitHub contents API
+// that was committed would fail with a conflict. The exception is 503
+// responses with a Retry-After header, which indicates that the request was
+// not handled.
+type RetryPolicy struct {
+	MaxRetries       int
+	MinBackoff       time.Duration
+	MaxBackoff       time.Duration
+	MaxRateLimitWait time.Duration
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by Retries if none is
+// provided.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:       5,
+		MinBackoff:       time.Second,
+		MaxBackoff:       30 * time.Second,
+		MaxRateLimitWait: 5 * time.Minute,
+	}
+}
+
+// ClientFunc is an option for creating new SCMClients.
+type ClientFunc func(c *SCMClient)
+
+// Retries is an option func for the SCMClient creation function.
+//
+// It configures the HTTP client of the wrapped scm.Client to retry requests
+// according to the policy, waiting is cancelled if the context of the request
+// is done.
+//
+// The HTTP client of the wrapped scm.Client is replaced, see New.
+func Retries(p RetryPolicy) ClientFunc {
+	return func(c *SCMClient) {
+		httpClient := &http.Client{}
+		if c.scmClient.Client != nil {
+			*httpClient = *c.scmClient.Client
+		}
+		httpClient.Transport = NewRetryTransport(httpClient.Transport, p)
+		c.scmClient.Client = httpClient
+	}
+}
+
+// NewRetryTransport creates and returns an http.RoundTripper that retries
+// requests made with base according to the policy.
+//
+// If base is nil, http.DefaultTransport is used.
+func NewRetryTransport(base http.RoundTripper, p RetryPolicy) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{base: base, policy: p}
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests with bodies can only be retried if the body can be recreated.
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+		res, err := t.base.RoundTrip(r)
+		if !canRetry || attempt > t.policy.MaxRetries {
+			return res, err
+		}
+		delay, retry := t.retryDelay(req, res, err, attempt)
+		if !retry {
+			return res, err
+		}
+		if res != nil {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying the request, and false
+// if the request should not be retried.
+func (t *retryTransport) retryDelay(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if req.Context().Err() != nil || !isSafe(req.Method) {
+			return 0, false
+		}
+		return t.backoff(attempt), true
+	}
+	if delay, limited := rateLimitDelay(res); limited {
+		if delay > t.policy.MaxRateLimitWait {
+			return 0, false
+		}
+		if delay <= 0 {
+			delay = t.backoff(attempt)
+		}
+		return delay, true
+	}
+	switch res.StatusCode {
+	case http.StatusServiceUnavailable:
+		if delay, ok := retryAfter(res); ok {
+			if delay > t.policy.MaxRateLimitWait {
+				return 0, false
+			}
+			if delay <= 0 {
+				delay = t.backoff(attempt)
+			}
+			return delay, true
+		}
+		return t.backoff(attempt), isSafe(req.Method)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return t.backoff(attempt), isSafe(req.Method)
+	}
+	return 0, false
+}
+
+// backoff returns the delay before the attempt is retried, which doubles with
+// each attempt, with jitter so that concurrent clients don't retry in step.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.MinBackoff << (attempt - 1)
+	if d > t.policy.MaxBackoff || d <= 0 {
+		d = t.policy.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// rateLimitDelay returns true if the response was rejected because of rate
+// limiting, along with the delay requested by the upstream service, which is
+// zero if it didn't provide one.
+func rateLimitDelay(res *http.Response) (time.Duration, bool) {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+	if delay, ok := retryAfter(res); ok {
+		return delay, true
+	}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if res.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		reset, err := strconv.ParseInt(res.Header.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			return 0, true
+		}
+		return time.Until(time.Unix(reset, 0)) + time.Second, true
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return 0, true
+	}
+	return 0, isSecondaryRateLimit(res)
+}
+
+// retryAfter returns the delay in the Retry-After header of the response, and
+// false if there is no valid header.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// isSecondaryRateLimit checks the body of a 403 response for the GitHub
+// secondary rate limit messages, the body is replaced so that it can still be
+// read.
+func isSecondaryRateLimit(res *http.Response) bool {
+	b, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+	msg := strings.ToLower(string(b))
+	for _, s := range rateLimitMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafe returns true for methods that don't change anything, which can be
+// retried when it's not known whether the request was handled.
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm/factory"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxRetries:       3,
+	MinBackoff:       time.Millisecond,
+	MaxBackoff:       5 * time.Millisecond,
+	MaxRateLimitWait: 5 * time.Second,
+}
+
+func TestRetryTransport(t *testing.T) {
+	retryTests := []struct {
+		name         string
+		method       string
+		responses    []func(w http.ResponseWriter)
+		wantStatus   int
+		wantRequests int32
+	}{
+		{"retries unavailable", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusServiceUnavailable, nil, ""),
+			respondWith(http.StatusOK, nil, ""),
+		}, http.StatusOK, 2},
+		{"does not retry unavailable with post", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusServiceUnavailable, nil, ""),
+		}, http.StatusServiceUnavailable, 1},
+		{"does not retry bad gateway with post", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusBadGateway, nil, ""),
+		}, http.StatusBadGateway, 1},
+		{"retries unavailable with post after Retry-After", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusServiceUnavailable, map[string]string{"Retry-After": "0"}, ""),
+			respondWith(http.StatusCreated, nil, ""),
+		}, http.StatusCreated, 2},
+		{"retries server error with get", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusInternalServerError, nil, ""),
+			respondWith(http.StatusOK, nil, ""),
+		}, http.StatusOK, 2},
+		{"does not retry bad gateway with put", http.MethodPut, []func(http.ResponseWriter){
+			respondWith(http.StatusBadGateway, nil, ""),
+		}, http.StatusBadGateway, 1},
+		{"does not retry gateway timeout with delete", http.MethodDelete, []func(http.ResponseWriter){
+			respondWith(http.StatusGatewayTimeout, nil, ""),
+		}, http.StatusGatewayTimeout, 1},
+		{"retries rate limit with put", http.MethodPut, []func(http.ResponseWriter){
+			respondWith(http.StatusTooManyRequests, nil, ""),
+			respondWith(http.StatusOK, nil, ""),
+		}, http.StatusOK, 2},
+		{"does not retry server error with post", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusInternalServerError, nil, ""),
+		}, http.StatusInternalServerError, 1},
+		{"retries after Retry-After", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusTooManyRequests, map[string]string{"Retry-After": "0"}, ""),
+			respondWith(http.StatusCreated, nil, ""),
+		}, http.StatusCreated, 2},
+		{"retries after GitHub rate limit reset", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusForbidden, map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10),
+			}, `{"message":"API rate limit exceeded"}`),
+			respondWith(http.StatusOK, nil, ""),
+		}, http.StatusOK, 2},
+		{"retries after GitLab rate limit reset", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusTooManyRequests, map[string]string{
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10),
+			}, ""),
+			respondWith(http.StatusOK, nil, ""),
+		}, http.StatusOK, 2},
+		{"retries GitHub secondary rate limit", http.MethodPost, []func(http.ResponseWriter){
+			respondWith(http.StatusForbidden, nil, `{"message":"You have exceeded a secondary rate limit."}`),
+			respondWith(http.StatusCreated, nil, ""),
+		}, http.StatusCreated, 2},
+		{"does not retry forbidden", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusForbidden, nil, `{"message":"Resource not accessible by integration"}`),
+		}, http.StatusForbidden, 1},
+		{"does not wait longer than the limit", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}, ""),
+		}, http.StatusTooManyRequests, 1},
+		{"gives up after max retries", http.MethodGet, []func(http.ResponseWriter){
+			respondWith(http.StatusBadGateway, nil, ""),
+		}, http.StatusBadGateway, 4},
+	}
+
+	for _, tt := range retryTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			ts, requests := newRetryServer(rt, tt.responses)
+			c := &http.Client{Transport: NewRetryTransport(nil, testRetryPolicy)}
+
+			req, err := http.NewRequest(tt.method, ts.URL, strings.NewReader("testing"))
+			if err != nil {
+				rt.Fatal(err)
+			}
+			res, err := c.Do(req)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			_ = res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				rt.Errorf("got status %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if n := atomic.LoadInt32(requests); n != tt.wantRequests {
+				rt.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryTransportResendsBody(t *testing.T) {
+	var bodies []string
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	c := &http.Client{Transport: NewRetryTransport(nil, testRetryPolicy)}
+
+	res, err := c.Post(ts.URL, "text/plain", strings.NewReader("testing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "testing" || bodies[1] != "testing" {
+		t.Fatalf("incorrect bodies: %q", bodies)
+	}
+}
+
+func TestRetryTransportIsCancellable(t *testing.T) {
+	ts, _ := newRetryServer(t, []func(http.ResponseWriter){
+		respondWith(http.StatusTooManyRequests, map[string]string{"Retry-After": "2"}, ""),
+	})
+	c := &http.Client{Transport: NewRetryTransport(nil, testRetryPolicy)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Do(req)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want a deadline exceeded error", err)
+	}
+}
+
+func TestSCMClientWithRetries(t *testing.T) {
+	ts, requests := newRetryServer(t, []func(http.ResponseWriter){
+		respondWith(http.StatusServiceUnavailable, nil, ""),
+		func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"main","commit":{"sha":"7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"}}`))
+		},
+	})
+	scmClient, err := factory.NewClient("github", ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, Retries(testRetryPolicy))
+
+	sha, err := client.GetBranchHead(context.Background(), "Codertocat/Hello-World", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sha != "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d" {
+		t.Fatalf("got sha %s", sha)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Fatalf("got %d requests, want 2", n)
+	}
+}
+
+// newRetryServer responds to each request with the next response, the last
+// response is repeated.
+func newRetryServer(t *testing.T, responses []func(http.ResponseWriter)) (*httptest.Server, *int32) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n > len(responses) {
+			n = len(responses)
+		}
+		responses[n-1](w)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+func respondWith(status int, headers map[string]string, body string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ocraviotto/pkg/secrets"
+)
+
+// SigningFormat identifies the kind of key that commits are signed with.
+type SigningFormat string
+
+const (
+	// SigningFormatGPG signs commits with an armored OpenPGP private key.
+	SigningFormatGPG SigningFormat = "gpg"
+	// SigningFormatSSH signs commits with a PEM encoded SSH private key, in
+	// the same way as Git's gpg.format=ssh.
+	SigningFormatSSH SigningFormat = "ssh"
+)
+
+const (
+	sshSignatureNamespace = "git"
+	sshSignatureLineWidth = 70
+)
+
+// SigningKey identifies the secret with the private key that commits are
+// signed with.
+type SigningKey struct {
+	Secret        types.NamespacedName
+	Key           string        // the key in the Secret with the private key
+	PassphraseKey string        // optional, the key in the Secret with the passphrase for the private key
+	Format        SigningFormat // defaults to SigningFormatGPG
+}
+
+// CommitSigner signs Git commits.
+type CommitSigner interface {
+	// Sign returns the armored signature of the encoded commit object, which
+	// is added to the commit in the gpgsig header.
+	Sign(ctx context.Context, payload []byte) (string, error)
+}
+
+// SignCommits is an option func for the SCMClient creation function.
+//
+// It configures the client to sign each commit with the CommitSigner, which
+// is only supported for GitHub, where the commits are created with the Git
+// data API, see CommitFiles. Other drivers return an error wrapping
+// scm.ErrNotSupported when committing.
+func SignCommits(s CommitSigner) ClientFunc {
+	return func(c *SCMClient) {
+		c.signer = s
+	}
+}
+
+// NewCommitSigner creates and returns a CommitSigner that signs with the key,
+// which is looked up with the SecretGetter each time a commit is signed.
+func NewCommitSigner(s secrets.SecretGetter, key SigningKey) CommitSigner {
+	return &secretSigner{secrets: s, key: key}
+}
+
+type secretSigner struct {
+	secrets secrets.SecretGetter
+	key     SigningKey
+}
+
+// Sign implements the CommitSigner interface.
+func (s *secretSigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	if s.secrets == nil {
+		return "", errors.New("a signing key is configured but no SecretGetter was provided")
+	}
+	key, err := s.secrets.SecretToken(ctx, s.key.Secret, s.key.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get signing key: %w", err)
+	}
+	var passphrase string
+	if s.key.PassphraseKey != "" {
+		passphrase, err = s.secrets.SecretToken(ctx, s.key.Secret, s.key.PassphraseKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to get signing key passphrase: %w", err)
+		}
+	}
+
+	switch s.key.Format {
+	case SigningFormatGPG, "":
+		return signGPG(payload, key, passphrase)
+	case SigningFormatSSH:
+		return signSSH(payload, key, passphrase)
+	}
+	return "", fmt.Errorf("unknown signing format %q", s.key.Format)
+}
+
+// signGPG returns an armored detached OpenPGP signature of the payload, made
+// with the first key in the armored key ring.
+func signGPG(payload []byte, key, passphrase string) (string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse GPG signing key: %w", err)
+	}
+	if len(entities) == 0 {
+		return "", errors.New("failed to parse GPG signing key: no keys found")
+	}
+	entity := entities[0]
+	if passphrase != "" {
+		if err := decryptEntity(entity, []byte(passphrase)); err != nil {
+			return "", fmt.Errorf("failed to decrypt GPG signing key: %w", err)
+		}
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(payload), nil); err != nil {
+		return "", fmt.Errorf("failed to sign commit: %w", err)
+	}
+	return sig.String(), nil
+}
+
+func decryptEntity(e *openpgp.Entity, passphrase []byte) error {
+	if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+		if err := e.PrivateKey.Decrypt(passphrase); err != nil {
+			return err
+		}
+	}
+	for _, subkey := range e.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// signSSH returns an armored SSH signature of the payload in the "git"
+// namespace, see the SSHSIG format in OpenSSH's PROTOCOL.sshsig.
+func signSSH(payload []byte, key, passphrase string) (string, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(key))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse SSH signing key: %w", err)
+	}
+
+	hash := sha512.Sum512(payload)
+	signed := sshSignedData(hash[:])
+	var sig *ssh.Signature
+	if as, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SHA-1 RSA signatures are rejected by OpenSSH when verifying.
+		sig, err = as.SignWithAlgorithm(rand.Reader, signed, ssh.SigAlgoRSASHA2512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, signed)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign commit: %w", err)
+	}
+
+	blob := ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), sshSignatureNamespace, "", "sha512", ssh.Marshal(sig)})
+	encoded := base64.StdEncoding.EncodeToString(append([]byte("SSHSIG"), blob...))
+
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > sshSignatureLineWidth {
+		armored.WriteString(encoded[:sshSignatureLineWidth] + "\n")
+		encoded = encoded[sshSignatureLineWidth:]
+	}
+	armored.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return armored.String(), nil
+}
+
+// sshSignedData returns the data that is signed for an SSH signature of a
+// message with the hash.
+func sshSignedData(hash []byte) []byte {
+	return append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSignatureNamespace, "", "sha512", hash})...)
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/go-scm/scm/factory"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/ocraviotto/pkg/secrets"
+	"github.com/ocraviotto/pkg/test"
+)
+
+const testCommitSignature = "-----BEGIN PGP SIGNATURE-----\n\ntest-signature\n-----END PGP SIGNATURE-----\n"
+
+type stubSigner struct {
+	payload []byte
+}
+
+func (s *stubSigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	s.payload = payload
+	return testCommitSignature, nil
+}
+
+func TestSignCommitWithGPGKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("John Doe", "", "john.doe@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key bytes.Buffer
+	w, err := armor.Encode(&key, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	s := secrets.NewSecretsStub()
+	s.StubSecret(testSecretID, "signing.key", key.String())
+	signer := NewCommitSigner(s, SigningKey{Secret: testSecretID, Key: "signing.key"})
+	payload := []byte("tree cd8274d15fa3ae2ab983129fb037999f264ba9a7\n")
+
+	sig, err := signer.Sign(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{entity}, bytes.NewReader(payload), strings.NewReader(sig), nil)
+	if err != nil {
+		t.Fatalf("failed to verify signature: %s", err)
+	}
+}
+
+func TestSignCommitWithSSHKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := secrets.NewSecretsStub()
+	s.StubSecret(testSecretID, "signing.key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})))
+	signer := NewCommitSigner(s, SigningKey{Secret: testSecretID, Key: "signing.key", Format: SigningFormatSSH})
+	payload := []byte("tree cd8274d15fa3ae2ab983129fb037999f264ba9a7\n")
+
+	sig, err := signer.Sign(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(sig), "\n")
+	if lines[0] != "-----BEGIN SSH SIGNATURE-----" || lines[len(lines)-1] != "-----END SSH SIGNATURE-----" {
+		t.Fatalf("got signature %q, want an armored SSH signature", sig)
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(lines[1:len(lines)-1], ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(bytes.TrimPrefix(blob, []byte("SSHSIG")), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(parsed.Signature, signature); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha512.Sum512(payload)
+	if err := publicKey.Verify(sshSignedData(hash[:]), signature); err != nil {
+		t.Fatalf("failed to verify signature: %s", err)
+	}
+}
+
+func TestSignCommitWithMissingKey(t *testing.T) {
+	signer := NewCommitSigner(secrets.NewSecretsStub(), SigningKey{Secret: testSecretID, Key: "signing.key"})
+
+	_, err := signer.Sign(context.Background(), []byte("testing"))
+	if !test.MatchError(t, `failed to get signing key: not found`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestCommitFilesWithSignatureInGitHub(t *testing.T) {
+	signature := scm.Signature{
+		Name:  "John Doe",
+		Email: "john.doe@example.com",
+		Date:  time.Date(2014, time.November, 7, 22, 1, 45, 0, time.UTC),
+	}
+	signedSHA := "d626d44bc04a64b0c98336fec7665a35b641e9f4"
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	mockGitHubTrees("100644")
+	mockGitHubBlob([]byte("testing"))
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		MatchType("json").
+		JSON(map[string]interface{}{
+			"message":   "just a test message",
+			"tree":      "cd8274d15fa3ae2ab983129fb037999f264ba9a7",
+			"parents":   []string{"7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"},
+			"author":    map[string]string{"name": signature.Name, "email": signature.Email, "date": "2014-11-07T22:01:45Z"},
+			"committer": map[string]string{"name": signature.Name, "email": signature.Email, "date": "2014-11-07T22:01:45Z"},
+			"signature": testCommitSignature,
+		}).
+		Reply(http.StatusCreated).
+		Type("application/json").
+		BodyString(`{"sha": "` + signedSHA + `"}`)
+	gock.New("https://api.github.com").
+		Patch("/repos/Codertocat/Hello-World/git/refs/heads/master").
+		MatchType("json").
+		JSON(map[string]interface{}{"sha": signedSHA, "force": false}).
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/single_ref.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &stubSigner{}
+	client := New(scmClient, SignCommits(signer))
+
+	sha, err := client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "just a test message", signature, []FileChange{
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing"), PreviousSHA: "980a0d5f19a64b4b30a87d4206aade58726b60e3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gock.IsDone() {
+		t.Fatal("commit was not created")
+	}
+	if sha != signedSHA {
+		t.Fatalf("got a different sha back: %s", sha)
+	}
+	want := "tree cd8274d15fa3ae2ab983129fb037999f264ba9a7\n" +
+		"parent 7fd1a60b01f91b314f59955a4e4d4e80d8edf11d\n" +
+		"author John Doe <john.doe@example.com> 1415397705 +0000\n" +
+		"committer John Doe <john.doe@example.com> 1415397705 +0000\n" +
+		"\n" +
+		"just a test message"
+	if s := string(signer.payload); s != want {
+		t.Fatalf("got signed payload %q, want %q", s, want)
+	}
+}
+
+func TestCommitFilesWithMismatchedSignedCommitInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	mockGitHubTrees("100644")
+	mockGitHubBlob([]byte("testing"))
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/trees").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_tree.json")
+	gock.New("https://api.github.com").
+		Post("/repos/Codertocat/Hello-World/git/commits").
+		Reply(http.StatusCreated).
+		Type("application/json").
+		File("testdata/github_create_commit.json")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, SignCommits(&stubSigner{}))
+	signature := scm.Signature{Name: "John Doe", Email: "john.doe@example.com"}
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "just a test message", signature, []FileChange{
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing")},
+	})
+	if !test.MatchError(t, `the created commit 7638417db6d59f3c431d3e1f261cc637155684cd does not match the signed commit`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestUpdateFileWithSignatureAndConflictInGitHub(t *testing.T) {
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/branches/master").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_branch.json")
+	gock.New("https://api.github.com").
+		Get("/repos/Codertocat/Hello-World/git/commits/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d").
+		Reply(http.StatusOK).
+		Type("application/json").
+		File("testdata/github_get_commit.json")
+	mockGitHubTrees("100644")
+	defer gock.Off()
+
+	scmClient, err := factory.NewClient("github", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, SignCommits(&stubSigner{}))
+	signature := scm.Signature{Name: "John Doe", Email: "john.doe@example.com"}
+
+	err = client.UpdateFile(context.Background(), "Codertocat/Hello-World", "master", "config/my/file.yaml", "just a test message", "4d7a214614ab2935c943f9e0ff69d22eadbb8f32", signature, []byte("testing"))
+
+	if !IsConflict(err) {
+		t.Fatalf("got %v, want a conflict error", err)
+	}
+	if !test.MatchError(t, `config/my/file.yaml does not match 4d7a2146`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestCommitFilesWithSignatureInGitLab(t *testing.T) {
+	scmClient, err := factory.NewClient("gitlab", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, SignCommits(&stubSigner{}))
+
+	_, err = client.CommitFiles(context.Background(), "Codertocat/Hello-World", "master", "test", scm.Signature{}, []FileChange{
+		{Action: FileUpdate, Path: "config/my/file.yaml", Content: []byte("testing")},
+	})
+	if !errors.Is(err, scm.ErrNotSupported) {
+		t.Fatalf("got %v, want %v", err, scm.ErrNotSupported)
+	}
+}
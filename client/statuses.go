@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+const statusPageSize = 100
+
+// CombinedStatus is the overall state of the commit statuses and checks
+// reported for a commit.
+type CombinedStatus struct {
+	SHA      string
+	State    scm.State
+	Statuses []*scm.Status // The most recent status for each context
+}
+
+// Failed returns the labels of the failed statuses.
+func (s *CombinedStatus) Failed() []string {
+	failed := []string{}
+	for _, status := range s.Statuses {
+		if isFailedState(status.State) {
+			failed = append(failed, status.Label)
+		}
+	}
+	return failed
+}
+
+// ListStatuses returns all the commit statuses reported for a ref.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	statuses := []*scm.Status{}
+	opts := scm.ListOptions{Page: 1, Size: statusPageSize}
+	for {
+		page, r, err := c.scmClient.Repositories.ListStatus(ctx, repo, ref, opts)
+		if err := responseError(fmt.Sprintf("failed to list statuses for %s in repo %s", ref, repo), r, err); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, page...)
+		if r.Page.Next == 0 {
+			return statuses, nil
+		}
+		opts.Page = r.Page.Next
+	}
+}
+
+// GetCombinedStatus returns the combined state of the commit statuses for a
+// ref, see CombineStatuses.
+//
+// In GitHub, check runs are included alongside the commit statuses, with the
+// name of the check as the label.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) GetCombinedStatus(ctx context.Context, repo, ref string) (*CombinedStatus, error) {
+	if c.scmClient.Driver == scm.DriverGithub {
+		return c.getCombinedStatusGitHub(ctx, repo, ref)
+	}
+	statuses, err := c.ListStatuses(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	statuses = LatestStatuses(statuses)
+	return &CombinedStatus{SHA: ref, State: CombineStatuses(statuses), Statuses: statuses}, nil
+}
+
+// CombineStatuses returns the overall state of the statuses, only the first
+// status for each label is used, as the most recent status is listed first.
+//
+// The state is StateSuccess only if at least one status is reported and all of
+// them succeeded, StateFailure if any of them failed, and StatePending
+// otherwise.
+func CombineStatuses(statuses []*scm.Status) scm.State {
+	state := scm.StateSuccess
+	if len(statuses) == 0 {
+		state = scm.StatePending
+	}
+	for _, s := range LatestStatuses(statuses) {
+		switch {
+		case isFailedState(s.State):
+			return scm.StateFailure
+		case s.State != scm.StateSuccess:
+			state = scm.StatePending
+		}
+	}
+	return state
+}
+
+// LatestStatuses returns the most recent status for each label, assuming that
+// the most recent status is listed first.
+func LatestStatuses(statuses []*scm.Status) []*scm.Status {
+	seen := map[string]bool{}
+	latest := []*scm.Status{}
+	for _, s := range statuses {
+		if seen[s.Label] {
+			continue
+		}
+		seen[s.Label] = true
+		latest = append(latest, s)
+	}
+	return latest
+}
+
+func isFailedState(s scm.State) bool {
+	return s == scm.StateFailure || s == scm.StateError || s == scm.StateCanceled
+}
+
+type ghStatus struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+type ghCombinedStatus struct {
+	SHA      string     `json:"sha"`
+	Statuses []ghStatus `json:"statuses"`
+}
+
+type ghCheckRun struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HTMLURL    string `json:"html_url"`
+	Output     struct {
+		Title string `json:"title"`
+	} `json:"output"`
+}
+
+type ghCheckRuns struct {
+	TotalCount int          `json:"total_count"`
+	CheckRuns  []ghCheckRun `json:"check_runs"`
+}
+
+func (c *SCMClient) getCombinedStatusGitHub(ctx context.Context, repo, ref string) (*CombinedStatus, error) {
+	combined := &ghCombinedStatus{}
+	_, err := c.do(ctx, fmt.Sprintf("failed to get combined status for %s in repo %s", ref, repo), "GET",
+		fmt.Sprintf("repos/%s/commits/%s/status?per_page=%d", repo, ref, statusPageSize), nil, combined)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []*scm.Status{}
+	for _, s := range combined.Statuses {
+		statuses = append(statuses, &scm.Status{
+			State:  githubStatusState(s.State),
+			Label:  s.Context,
+			Desc:   s.Description,
+			Target: s.TargetURL,
+		})
+	}
+
+	for page := 1; ; page++ {
+		runs := &ghCheckRuns{}
+		_, err := c.do(ctx, fmt.Sprintf("failed to list check runs for %s in repo %s", ref, repo), "GET",
+			fmt.Sprintf("repos/%s/commits/%s/check-runs?per_page=%d&page=%d", repo, ref, statusPageSize, page), nil, runs)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range runs.CheckRuns {
+			statuses = append(statuses, &scm.Status{
+				State:  githubCheckRunState(r.Status, r.Conclusion),
+				Label:  r.Name,
+				Desc:   r.Output.Title,
+				Target: r.HTMLURL,
+			})
+		}
+		if len(runs.CheckRuns) == 0 || page*statusPageSize >= runs.TotalCount {
+			break
+		}
+	}
+
+	statuses = LatestStatuses(statuses)
+	return &CombinedStatus{SHA: combined.SHA, State: CombineStatuses(statuses), Statuses: statuses}, nil
+}
+
+func githubStatusState(s string) scm.State {
+	switch s {
+	case "success":
+		return scm.StateSuccess
+	case "failure":
+		return scm.StateFailure
+	case "error":
+		return scm.StateError
+	case "pending":
+		return scm.StatePending
+	}
+	return scm.StateUnknown
+}
+
+// githubCheckRunState returns the state of a check run, completed check runs
+// with conclusions that are not known to succeed, e.g. stale or
+// startup_failure, have failed.
+func githubCheckRunState(status, conclusion string) scm.State {
+	if status == "in_progress" {
+		return scm.StateRunning
+	}
+	if status != "completed" {
+		return scm.StatePending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return scm.StateSuccess
+	case "cancelled":
+		return scm.StateCanceled
+	}
+	return scm.StateFailure
+}
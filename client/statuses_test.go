@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+func TestCombineStatuses(t *testing.T) {
+	statusTests := []struct {
+		name     string
+		statuses []*scm.Status
+		want     scm.State
+	}{
+		{"no statuses", []*scm.Status{}, scm.StatePending},
+		{"successful", []*scm.Status{{Label: "a", State: scm.StateSuccess}, {Label: "b", State: scm.StateSuccess}}, scm.StateSuccess},
+		{"running", []*scm.Status{{Label: "a", State: scm.StateSuccess}, {Label: "b", State: scm.StateRunning}}, scm.StatePending},
+		{"failed", []*scm.Status{{Label: "a", State: scm.StateRunning}, {Label: "b", State: scm.StateFailure}}, scm.StateFailure},
+		{"cancelled", []*scm.Status{{Label: "a", State: scm.StateCanceled}}, scm.StateFailure},
+		{"retried", []*scm.Status{{Label: "a", State: scm.StateSuccess}, {Label: "a", State: scm.StateFailure}}, scm.StateSuccess},
+	}
+
+	for _, tt := range statusTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if state := CombineStatuses(tt.statuses); state != tt.want {
+				t.Fatalf("got %v, want %v", state, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubCheckRunState(t *testing.T) {
+	stateTests := []struct {
+		status     string
+		conclusion string
+		want       scm.State
+	}{
+		{"queued", "", scm.StatePending},
+		{"waiting", "", scm.StatePending},
+		{"in_progress", "", scm.StateRunning},
+		{"completed", "success", scm.StateSuccess},
+		{"completed", "skipped", scm.StateSuccess},
+		{"completed", "failure", scm.StateFailure},
+		{"completed", "timed_out", scm.StateFailure},
+		{"completed", "cancelled", scm.StateCanceled},
+		{"completed", "stale", scm.StateFailure},
+		{"completed", "startup_failure", scm.StateFailure},
+		{"completed", "unknown", scm.StateFailure},
+	}
+
+	for _, tt := range stateTests {
+		t.Run(tt.status+" "+tt.conclusion, func(t *testing.T) {
+			if state := githubCheckRunState(tt.status, tt.conclusion); state != tt.want {
+				t.Fatalf("got %v, want %v", state, tt.want)
+			}
+		})
+	}
+}
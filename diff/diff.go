@@ -0,0 +1,206 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	contextLines = 3
+
+	// maxTableSize limits the size of the table used to find the longest
+	// common subsequence of lines, beyond this, the changed lines are reported
+	// as removed and then added.
+	maxTableSize = 1 << 22
+)
+
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns a unified diff of the changes from a to b, with three lines
+// of context around each change.
+//
+// The names are used in the "---" and "+++" header lines, e.g. "a/file.yaml"
+// or "/dev/null".
+//
+// If a and b are identical, an empty string is returned.
+func Unified(fromName, toName string, a, b []byte) string {
+	edits := lineEdits(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].kind == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		start, end := hunkBounds(edits, i)
+		writeHunk(&out, edits, start, end)
+		i = end
+	}
+	return out.String()
+}
+
+// hunkBounds returns the range of edits that form a hunk including the change
+// at i, merging changes that are separated by too few lines of context.
+func hunkBounds(edits []edit, i int) (int, int) {
+	start := i - contextLines
+	if start < 0 {
+		start = 0
+	}
+	end := i
+	for {
+		for end < len(edits) && edits[end].kind != ' ' {
+			end++
+		}
+		next := end
+		for next < len(edits) && edits[next].kind == ' ' {
+			next++
+		}
+		if next < len(edits) && next-end <= 2*contextLines {
+			end = next
+			continue
+		}
+		end += contextLines
+		if end > len(edits) {
+			end = len(edits)
+		}
+		return start, end
+	}
+}
+
+func writeHunk(out *strings.Builder, edits []edit, start, end int) {
+	var fromLine, toLine int
+	for _, e := range edits[:start] {
+		if e.kind != '+' {
+			fromLine++
+		}
+		if e.kind != '-' {
+			toLine++
+		}
+	}
+	var fromCount, toCount int
+	for _, e := range edits[start:end] {
+		if e.kind != '+' {
+			fromCount++
+		}
+		if e.kind != '-' {
+			toCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	for _, e := range edits[start:end] {
+		out.WriteByte(e.kind)
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of lines in a hunk header, start is the number
+// of lines preceding the hunk.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// lineEdits returns the edits needed to turn a into b.
+func lineEdits(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		edits = append(edits, edit{' ', l})
+	}
+	edits = append(edits, middleEdits(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', l})
+	}
+	return edits
+}
+
+// middleEdits finds the edits between a and b using the longest common
+// subsequence of lines.
+func middleEdits(a, b []string) []edit {
+	edits := []edit{}
+	if (len(a)+1)*(len(b)+1) > maxTableSize {
+		for _, l := range a {
+			edits = append(edits, edit{'-', l})
+		}
+		for _, l := range b {
+			edits = append(edits, edit{'+', l})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, edit{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, edit{'+', b[j]})
+	}
+	return edits
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
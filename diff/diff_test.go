@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnified(t *testing.T) {
+	diffTests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "no changes",
+			a:    "test:\n  image: old-image\n",
+			b:    "test:\n  image: old-image\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "test:\n  image: old-image\n",
+			b:    "test:\n  image: new-image\n",
+			want: "--- a/test.yaml\n+++ b/test.yaml\n@@ -1,2 +1,2 @@\n test:\n-  image: old-image\n+  image: new-image\n",
+		},
+		{
+			name: "created file",
+			a:    "",
+			b:    "new content\n",
+			want: "--- a/test.yaml\n+++ b/test.yaml\n@@ -0,0 +1 @@\n+new content\n",
+		},
+		{
+			name: "missing newline",
+			a:    "old content\n",
+			b:    "new content",
+			want: "--- a/test.yaml\n+++ b/test.yaml\n@@ -1 +1 @@\n-old content\n+new content\n\\ No newline at end of file\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n",
+			b:    "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nK\nl\n",
+			want: "--- a/test.yaml\n+++ b/test.yaml\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -8,5 +8,5 @@\n h\n i\n j\n-k\n+K\n l\n",
+		},
+		{
+			name: "merged hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\n",
+			b:    "a\nB\nc\nd\ne\nf\nG\nh\n",
+			want: "--- a/test.yaml\n+++ b/test.yaml\n@@ -1,8 +1,8 @@\n a\n-b\n+B\n c\n d\n e\n f\n-g\n+G\n h\n",
+		},
+	}
+
+	for _, tt := range diffTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			got := Unified("a/test.yaml", "b/test.yaml", []byte(tt.a), []byte(tt.b))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				rt.Errorf("diff failed:\n%s", diff)
+			}
+		})
+	}
+}
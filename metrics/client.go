@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+var _ client.GitClient = (*Client)(nil)
+
+// Client is a client.GitClient that records the number, duration and errors
+// of the requests made with the wrapped GitClient.
+type Client struct {
+	gitClient client.GitClient
+	metrics   *Metrics
+	driver    string
+}
+
+// NewClient creates and returns a new Client that records the requests made
+// with the GitClient, labelled with the driver, e.g. "github".
+func (m *Metrics) NewClient(c client.GitClient, driver string) *Client {
+	return &Client{gitClient: c, metrics: m, driver: driver}
+}
+
+// GetFile implements the client.GitClient interface.
+func (c *Client) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	start := time.Now()
+	content, err := c.gitClient.GetFile(ctx, repo, ref, path)
+	c.record("GetFile", repo, start, err)
+	return content, err
+}
+
+// UpdateFile implements the client.GitClient interface.
+func (c *Client) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	start := time.Now()
+	err := c.gitClient.UpdateFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+	c.record("UpdateFile", repo, start, err)
+	return err
+}
+
+// DeleteFile implements the client.GitClient interface.
+func (c *Client) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	start := time.Now()
+	err := c.gitClient.DeleteFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+	c.record("DeleteFile", repo, start, err)
+	return err
+}
+
+// MoveFile implements the client.GitClient interface.
+func (c *Client) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) error {
+	start := time.Now()
+	err := c.gitClient.MoveFile(ctx, repo, branch, path, newPath, message, previousSHA, signature, content)
+	c.record("MoveFile", repo, start, err)
+	return err
+}
+
+// ListFiles implements the client.GitClient interface.
+func (c *Client) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) ([]*client.FileInfo, error) {
+	start := time.Now()
+	files, err := c.gitClient.ListFiles(ctx, repo, ref, dir, recursive)
+	c.record("ListFiles", repo, start, err)
+	return files, err
+}
+
+// CommitFiles implements the client.GitClient interface.
+func (c *Client) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	start := time.Now()
+	sha, err := c.gitClient.CommitFiles(ctx, repo, branch, message, signature, changes)
+	c.record("CommitFiles", repo, start, err)
+	return sha, err
+}
+
+// CreatePullRequest implements the client.GitClient interface.
+func (c *Client) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	start := time.Now()
+	pr, err := c.gitClient.CreatePullRequest(ctx, repo, inp)
+	c.record("CreatePullRequest", repo, start, err)
+	return pr, err
+}
+
+// CreatePullRequestWithOptions implements the client.GitClient interface.
+func (c *Client) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (*scm.PullRequest, error) {
+	start := time.Now()
+	pr, err := c.gitClient.CreatePullRequestWithOptions(ctx, repo, inp, opts)
+	c.record("CreatePullRequestWithOptions", repo, start, err)
+	return pr, err
+}
+
+// FindPullRequest implements the client.GitClient interface.
+func (c *Client) FindPullRequest(ctx context.Context, repo string, number int) (*scm.PullRequest, error) {
+	start := time.Now()
+	pr, err := c.gitClient.FindPullRequest(ctx, repo, number)
+	c.record("FindPullRequest", repo, start, err)
+	return pr, err
+}
+
+// ListPullRequests implements the client.GitClient interface.
+func (c *Client) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	start := time.Now()
+	prs, err := c.gitClient.ListPullRequests(ctx, repo, opts)
+	c.record("ListPullRequests", repo, start, err)
+	return prs, err
+}
+
+// UpdatePullRequest implements the client.GitClient interface.
+func (c *Client) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	start := time.Now()
+	pr, err := c.gitClient.UpdatePullRequest(ctx, repo, number, inp)
+	c.record("UpdatePullRequest", repo, start, err)
+	return pr, err
+}
+
+// MergePullRequest implements the client.GitClient interface.
+func (c *Client) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) error {
+	start := time.Now()
+	err := c.gitClient.MergePullRequest(ctx, repo, number, opts)
+	c.record("MergePullRequest", repo, start, err)
+	return err
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (c *Client) ListStatuses(ctx context.Context, repo, ref string) ([]*scm.Status, error) {
+	start := time.Now()
+	statuses, err := c.gitClient.ListStatuses(ctx, repo, ref)
+	c.record("ListStatuses", repo, start, err)
+	return statuses, err
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (c *Client) GetCombinedStatus(ctx context.Context, repo, ref string) (*client.CombinedStatus, error) {
+	start := time.Now()
+	status, err := c.gitClient.GetCombinedStatus(ctx, repo, ref)
+	c.record("GetCombinedStatus", repo, start, err)
+	return status, err
+}
+
+// CreateBranch implements the client.GitClient interface.
+func (c *Client) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	start := time.Now()
+	err := c.gitClient.CreateBranch(ctx, repo, branch, sha)
+	c.record("CreateBranch", repo, start, err)
+	return err
+}
+
+// GetBranchHead implements the client.GitClient interface.
+func (c *Client) GetBranchHead(ctx context.Context, repo, branch string) (string, error) {
+	start := time.Now()
+	sha, err := c.gitClient.GetBranchHead(ctx, repo, branch)
+	c.record("GetBranchHead", repo, start, err)
+	return sha, err
+}
+
+// ListBranches implements the client.GitClient interface.
+func (c *Client) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	start := time.Now()
+	branches, err := c.gitClient.ListBranches(ctx, repo)
+	c.record("ListBranches", repo, start, err)
+	return branches, err
+}
+
+// DeleteBranch implements the client.GitClient interface.
+func (c *Client) DeleteBranch(ctx context.Context, repo, branch string) error {
+	start := time.Now()
+	err := c.gitClient.DeleteBranch(ctx, repo, branch)
+	c.record("DeleteBranch", repo, start, err)
+	return err
+}
+
+// CreateFork implements the client.GitClient interface.
+func (c *Client) CreateFork(ctx context.Context, repo, owner string) (string, error) {
+	start := time.Now()
+	fork, err := c.gitClient.CreateFork(ctx, repo, owner)
+	c.record("CreateFork", repo, start, err)
+	return fork, err
+}
+
+// SyncFork implements the client.GitClient interface.
+func (c *Client) SyncFork(ctx context.Context, fork, branch string) error {
+	start := time.Now()
+	err := c.gitClient.SyncFork(ctx, fork, branch)
+	c.record("SyncFork", fork, start, err)
+	return err
+}
+
+// GetCommit implements the client.GitClient interface.
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (*scm.Commit, error) {
+	start := time.Now()
+	commit, err := c.gitClient.GetCommit(ctx, repo, ref)
+	c.record("GetCommit", repo, start, err)
+	return commit, err
+}
+
+func (c *Client) record(operation, repo string, start time.Time, err error) {
+	c.metrics.requests.WithLabelValues(operation, c.driver, repo).Inc()
+	c.metrics.duration.WithLabelValues(operation, c.driver, repo).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.metrics.errors.WithLabelValues(operation, c.driver, repo, ErrorClass(err)).Inc()
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/updater"
+)
+
+var _ updater.MetricsRecorder = (*Metrics)(nil)
+
+// Metrics are the Prometheus metrics for GitClient requests and Updater
+// operations.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	branchesCreated     *prometheus.CounterVec
+	pullRequestsCreated *prometheus.CounterVec
+	updatesSkipped      *prometheus.CounterVec
+	conflictsRetried    *prometheus.CounterVec
+}
+
+// New creates and returns new Metrics, registered with the Registerer, e.g.
+// controller-runtime's metrics.Registry.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitclient_requests_total",
+			Help: "Number of GitClient requests.",
+		}, []string{"operation", "driver", "repo"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitclient_errors_total",
+			Help: "Number of GitClient requests that failed, by the class of the error.",
+		}, []string{"operation", "driver", "repo", "class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gitclient_request_duration_seconds",
+			Help:    "Duration of GitClient requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "driver", "repo"}),
+		branchesCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updater_branches_created_total",
+			Help: "Number of branches created for updates.",
+		}, []string{"repo"}),
+		pullRequestsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updater_pull_requests_created_total",
+			Help: "Number of PullRequests created.",
+		}, []string{"repo"}),
+		updatesSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updater_noop_updates_total",
+			Help: "Number of updates that were skipped because they made no changes.",
+		}, []string{"repo"}),
+		conflictsRetried: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "updater_conflicts_retried_total",
+			Help: "Number of changes that were retried because they conflicted with other changes.",
+		}, []string{"repo"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.errors, m.duration, m.branchesCreated, m.pullRequestsCreated, m.updatesSkipped, m.conflictsRetried} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// BranchCreated implements the updater.MetricsRecorder interface.
+func (m *Metrics) BranchCreated(repo string) {
+	m.branchesCreated.WithLabelValues(repo).Inc()
+}
+
+// PullRequestCreated implements the updater.MetricsRecorder interface.
+func (m *Metrics) PullRequestCreated(repo string) {
+	m.pullRequestsCreated.WithLabelValues(repo).Inc()
+}
+
+// UpdateSkipped implements the updater.MetricsRecorder interface.
+func (m *Metrics) UpdateSkipped(repo string) {
+	m.updatesSkipped.WithLabelValues(repo).Inc()
+}
+
+// ConflictRetried implements the updater.MetricsRecorder interface.
+func (m *Metrics) ConflictRetried(repo string) {
+	m.conflictsRetried.WithLabelValues(repo).Inc()
+}
+
+// ErrorClass returns the class of an error returned by a GitClient, which is
+// used as the class label of the errors metric.
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case client.IsNotFound(err):
+		return "not_found"
+	case client.IsConflict(err):
+		return "conflict"
+	case client.IsUnauthorized(err):
+		return "unauthorized"
+	case client.IsRateLimited(err):
+		return "rate_limited"
+	case client.IsForbidden(err):
+		return "forbidden"
+	case client.IsValidation(err):
+		return "validation"
+	case client.IsNotSupported(err):
+		return "not_supported"
+	}
+	var e client.SCMError
+	if errors.As(err, &e) && e.Status >= 500 {
+		return "server"
+	}
+	return "other"
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+)
+
+const testRepo = "testorg/testrepo"
+
+func TestClientRecordsRequests(t *testing.T) {
+	m := newTestMetrics(t)
+	mc := mock.New(t)
+	mc.AddFileContents(testRepo, "README.md", "main", []byte("testing"))
+	c := m.NewClient(mc, "github")
+
+	if _, err := c.GetFile(context.Background(), testRepo, "main", "README.md"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.GetFile(context.Background(), testRepo, "main", "unknown.md")
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("GetFile", "github", testRepo)); n != 2 {
+		t.Errorf("got %v requests, want 2", n)
+	}
+	if n := testutil.ToFloat64(m.errors.WithLabelValues("GetFile", "github", testRepo, "not_found")); n != 1 {
+		t.Errorf("got %v errors, want 1", n)
+	}
+	if n := testutil.CollectAndCount(m.duration); n != 1 {
+		t.Errorf("got %v duration series, want 1", n)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	m := newTestMetrics(t)
+
+	m.BranchCreated(testRepo)
+	m.PullRequestCreated(testRepo)
+	m.UpdateSkipped(testRepo)
+	m.UpdateSkipped(testRepo)
+	m.ConflictRetried(testRepo)
+
+	counters := []struct {
+		c    *prometheus.CounterVec
+		want float64
+	}{
+		{m.branchesCreated, 1}, {m.pullRequestsCreated, 1}, {m.updatesSkipped, 2}, {m.conflictsRetried, 1},
+	}
+	for _, tt := range counters {
+		if n := testutil.ToFloat64(tt.c.WithLabelValues(testRepo)); n != tt.want {
+			t.Errorf("got %v, want %v", n, tt.want)
+		}
+	}
+}
+
+func TestNewWithRegisteredMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := New(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(reg)
+
+	var registered prometheus.AlreadyRegisteredError
+	if !errors.As(err, &registered) {
+		t.Fatalf("got %v, want an already registered error", err)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	classTests := []struct {
+		err  error
+		want string
+	}{
+		{client.SCMError{Status: http.StatusNotFound}, "not_found"},
+		{fmt.Errorf("failed to update file: %w", client.SCMError{Status: http.StatusConflict}), "conflict"},
+		{client.SCMError{Status: http.StatusUnauthorized}, "unauthorized"},
+		{client.SCMError{Status: http.StatusForbidden}, "forbidden"},
+		{client.SCMError{Status: http.StatusTooManyRequests}, "rate_limited"},
+		{client.SCMError{Status: http.StatusUnprocessableEntity}, "validation"},
+		{client.SCMError{Status: http.StatusBadGateway}, "server"},
+		{fmt.Errorf("testing: %w", scm.ErrNotSupported), "not_supported"},
+		{context.Canceled, "canceled"},
+		{errors.New("connection refused"), "other"},
+	}
+
+	for _, tt := range classTests {
+		if got := ErrorClass(tt.err); got != tt.want {
+			t.Errorf("ErrorClass(%v) got %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func newTestMetrics(t *testing.T) *Metrics {
+	t.Helper()
+	m, err := New(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -1,6 +1,9 @@
 package syaml
 
 import (
+	"bytes"
+	"reflect"
+
 	"github.com/tidwall/sjson"
 	"sigs.k8s.io/yaml"
 )
@@ -34,3 +37,29 @@ func DeleteBytes(y []byte, path string) ([]byte, error) {
 	}
 	return yaml.JSONToYAML(updated)
 }
+
+// Equal accepts two YAML bodies and returns true if they are semantically
+// identical, i.e. they differ only in formatting, comments or key order.
+//
+// Bodies that can't be parsed, or that contain multiple documents, are only
+// equal if they are byte-for-byte identical.
+func Equal(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if isMultiDocument(a) || isMultiDocument(b) {
+		return false
+	}
+	var av, bv interface{}
+	if err := yaml.Unmarshal(a, &av); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(b, &bv); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func isMultiDocument(y []byte) bool {
+	return bytes.HasPrefix(y, []byte("---")) || bytes.Contains(y, []byte("\n---"))
+}
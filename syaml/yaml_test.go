@@ -105,3 +105,28 @@ func TestDelete(t *testing.T) {
 		}
 	}
 }
+
+func TestEqual(t *testing.T) {
+	equalTests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{"identical", "name: testing\n", "name: testing\n", true},
+		{"reformatted", "person: {name: John, age: 30}\n", "person:\n  age: 30\n  name: John\n", true},
+		{"comments", "# the name\nname: testing\n", "name: testing\n", true},
+		{"different values", "name: testing\n", "name: other\n", false},
+		{"different types", "age: 30\n", "age: \"30\"\n", false},
+		{"multiple documents", "name: testing\n---\nname: a\n", "name: testing\n---\nname: b\n", false},
+		{"unparseable", ": testing\n", ":  testing\n", false},
+	}
+
+	for _, tt := range equalTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			if got := Equal([]byte(tt.a), []byte(tt.b)); got != tt.want {
+				rt.Errorf("Equal(%#v, %#v) got %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
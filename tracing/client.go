@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+var _ client.GitClient = (*Client)(nil)
+
+// Client is a client.GitClient that creates a span for each request made with
+// the wrapped GitClient, as a child of the span in the request context.
+type Client struct {
+	gitClient client.GitClient
+	tracer    trace.Tracer
+}
+
+// NewClient creates and returns a new Client that creates spans with the
+// TracerProvider, or the global TracerProvider if it's nil.
+func NewClient(c client.GitClient, tp trace.TracerProvider) *Client {
+	return &Client{gitClient: c, tracer: Tracer(tp)}
+}
+
+// GetFile implements the client.GitClient interface.
+func (c *Client) GetFile(ctx context.Context, repo, ref, path string) (content *scm.Content, err error) {
+	ctx, span := c.start(ctx, "GetFile", repo, RefKey.String(ref), PathKey.String(path))
+	defer func() {
+		if content != nil {
+			span.SetAttributes(SHAKey.String(content.Sha))
+		}
+		End(span, err)
+	}()
+	return c.gitClient.GetFile(ctx, repo, ref, path)
+}
+
+// UpdateFile implements the client.GitClient interface.
+func (c *Client) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) (err error) {
+	ctx, span := c.start(ctx, "UpdateFile", repo, BranchKey.String(branch), PathKey.String(path))
+	defer func() { End(span, err) }()
+	return c.gitClient.UpdateFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+}
+
+// DeleteFile implements the client.GitClient interface.
+func (c *Client) DeleteFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) (err error) {
+	ctx, span := c.start(ctx, "DeleteFile", repo, BranchKey.String(branch), PathKey.String(path))
+	defer func() { End(span, err) }()
+	return c.gitClient.DeleteFile(ctx, repo, branch, path, message, previousSHA, signature, content)
+}
+
+// MoveFile implements the client.GitClient interface.
+func (c *Client) MoveFile(ctx context.Context, repo, branch, path, newPath, message, previousSHA string, signature scm.Signature, content []byte) (err error) {
+	ctx, span := c.start(ctx, "MoveFile", repo, BranchKey.String(branch), PathKey.String(path), NewPathKey.String(newPath))
+	defer func() { End(span, err) }()
+	return c.gitClient.MoveFile(ctx, repo, branch, path, newPath, message, previousSHA, signature, content)
+}
+
+// ListFiles implements the client.GitClient interface.
+func (c *Client) ListFiles(ctx context.Context, repo, ref, dir string, recursive bool) (files []*client.FileInfo, err error) {
+	ctx, span := c.start(ctx, "ListFiles", repo, RefKey.String(ref), PathKey.String(dir))
+	defer func() { End(span, err) }()
+	return c.gitClient.ListFiles(ctx, repo, ref, dir, recursive)
+}
+
+// CommitFiles implements the client.GitClient interface.
+func (c *Client) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (sha string, err error) {
+	paths := make([]string, len(changes))
+	for i := range changes {
+		paths[i] = changes[i].Path
+	}
+	ctx, span := c.start(ctx, "CommitFiles", repo, BranchKey.String(branch), PathKey.StringSlice(paths))
+	defer func() {
+		span.SetAttributes(SHAKey.String(sha))
+		End(span, err)
+	}()
+	return c.gitClient.CommitFiles(ctx, repo, branch, message, signature, changes)
+}
+
+// CreatePullRequest implements the client.GitClient interface.
+func (c *Client) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (pr *scm.PullRequest, err error) {
+	ctx, span := c.start(ctx, "CreatePullRequest", repo, BranchKey.String(inp.Source))
+	defer func() {
+		setPullRequest(span, pr)
+		End(span, err)
+	}()
+	return c.gitClient.CreatePullRequest(ctx, repo, inp)
+}
+
+// CreatePullRequestWithOptions implements the client.GitClient interface.
+func (c *Client) CreatePullRequestWithOptions(ctx context.Context, repo string, inp *scm.PullRequestInput, opts client.PullRequestOptions) (pr *scm.PullRequest, err error) {
+	ctx, span := c.start(ctx, "CreatePullRequestWithOptions", repo, BranchKey.String(inp.Source))
+	defer func() {
+		setPullRequest(span, pr)
+		End(span, err)
+	}()
+	return c.gitClient.CreatePullRequestWithOptions(ctx, repo, inp, opts)
+}
+
+// FindPullRequest implements the client.GitClient interface.
+func (c *Client) FindPullRequest(ctx context.Context, repo string, number int) (pr *scm.PullRequest, err error) {
+	ctx, span := c.start(ctx, "FindPullRequest", repo, PullRequestKey.Int(number))
+	defer func() {
+		setPullRequest(span, pr)
+		End(span, err)
+	}()
+	return c.gitClient.FindPullRequest(ctx, repo, number)
+}
+
+// ListPullRequests implements the client.GitClient interface.
+func (c *Client) ListPullRequests(ctx context.Context, repo string, opts scm.PullRequestListOptions) (prs []*scm.PullRequest, err error) {
+	ctx, span := c.start(ctx, "ListPullRequests", repo)
+	defer func() { End(span, err) }()
+	return c.gitClient.ListPullRequests(ctx, repo, opts)
+}
+
+// UpdatePullRequest implements the client.GitClient interface.
+func (c *Client) UpdatePullRequest(ctx context.Context, repo string, number int, inp *scm.PullRequestInput) (pr *scm.PullRequest, err error) {
+	ctx, span := c.start(ctx, "UpdatePullRequest", repo, PullRequestKey.Int(number))
+	defer func() {
+		setPullRequest(span, pr)
+		End(span, err)
+	}()
+	return c.gitClient.UpdatePullRequest(ctx, repo, number, inp)
+}
+
+// MergePullRequest implements the client.GitClient interface.
+func (c *Client) MergePullRequest(ctx context.Context, repo string, number int, opts client.MergeOptions) (err error) {
+	ctx, span := c.start(ctx, "MergePullRequest", repo, PullRequestKey.Int(number))
+	defer func() { End(span, err) }()
+	return c.gitClient.MergePullRequest(ctx, repo, number, opts)
+}
+
+// ListStatuses implements the client.GitClient interface.
+func (c *Client) ListStatuses(ctx context.Context, repo, ref string) (statuses []*scm.Status, err error) {
+	ctx, span := c.start(ctx, "ListStatuses", repo, RefKey.String(ref))
+	defer func() { End(span, err) }()
+	return c.gitClient.ListStatuses(ctx, repo, ref)
+}
+
+// GetCombinedStatus implements the client.GitClient interface.
+func (c *Client) GetCombinedStatus(ctx context.Context, repo, ref string) (status *client.CombinedStatus, err error) {
+	ctx, span := c.start(ctx, "GetCombinedStatus", repo, RefKey.String(ref))
+	defer func() { End(span, err) }()
+	return c.gitClient.GetCombinedStatus(ctx, repo, ref)
+}
+
+// CreateBranch implements the client.GitClient interface.
+func (c *Client) CreateBranch(ctx context.Context, repo, branch, sha string) (err error) {
+	ctx, span := c.start(ctx, "CreateBranch", repo, BranchKey.String(branch), SHAKey.String(sha))
+	defer func() { End(span, err) }()
+	return c.gitClient.CreateBranch(ctx, repo, branch, sha)
+}
+
+// GetBranchHead implements the client.GitClient interface.
+func (c *Client) GetBranchHead(ctx context.Context, repo, branch string) (sha string, err error) {
+	ctx, span := c.start(ctx, "GetBranchHead", repo, BranchKey.String(branch))
+	defer func() {
+		span.SetAttributes(SHAKey.String(sha))
+		End(span, err)
+	}()
+	return c.gitClient.GetBranchHead(ctx, repo, branch)
+}
+
+// ListBranches implements the client.GitClient interface.
+func (c *Client) ListBranches(ctx context.Context, repo string) (branches []*scm.Reference, err error) {
+	ctx, span := c.start(ctx, "ListBranches", repo)
+	defer func() { End(span, err) }()
+	return c.gitClient.ListBranches(ctx, repo)
+}
+
+// DeleteBranch implements the client.GitClient interface.
+func (c *Client) DeleteBranch(ctx context.Context, repo, branch string) (err error) {
+	ctx, span := c.start(ctx, "DeleteBranch", repo, BranchKey.String(branch))
+	defer func() { End(span, err) }()
+	return c.gitClient.DeleteBranch(ctx, repo, branch)
+}
+
+// CreateFork implements the client.GitClient interface.
+func (c *Client) CreateFork(ctx context.Context, repo, owner string) (fork string, err error) {
+	ctx, span := c.start(ctx, "CreateFork", repo, OwnerKey.String(owner))
+	defer func() {
+		span.SetAttributes(ForkKey.String(fork))
+		End(span, err)
+	}()
+	return c.gitClient.CreateFork(ctx, repo, owner)
+}
+
+// SyncFork implements the client.GitClient interface.
+func (c *Client) SyncFork(ctx context.Context, fork, branch string) (err error) {
+	ctx, span := c.start(ctx, "SyncFork", fork, BranchKey.String(branch))
+	defer func() { End(span, err) }()
+	return c.gitClient.SyncFork(ctx, fork, branch)
+}
+
+// GetCommit implements the client.GitClient interface.
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (commit *scm.Commit, err error) {
+	ctx, span := c.start(ctx, "GetCommit", repo, RefKey.String(ref))
+	defer func() {
+		if commit != nil {
+			span.SetAttributes(SHAKey.String(commit.Sha))
+		}
+		End(span, err)
+	}()
+	return c.gitClient.GetCommit(ctx, repo, ref)
+}
+
+func (c *Client) start(ctx context.Context, operation, repo string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "GitClient."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append([]attribute.KeyValue{RepoKey.String(repo)}, attrs...)...))
+}
+
+func setPullRequest(span trace.Span, pr *scm.PullRequest) {
+	if pr != nil {
+		span.SetAttributes(PullRequestKey.Int(pr.Number), SHAKey.String(pr.Sha))
+	}
+}
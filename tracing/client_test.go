@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+)
+
+const testRepo = "testorg/testrepo"
+
+func TestClientCreatesSpans(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	mc := mock.New(t)
+	mc.AddFileContents(testRepo, "README.md", "main", []byte("testing"))
+	c := NewClient(mc, tp)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	content, err := c.GetFile(ctx, testRepo, "main", "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GitClient.GetFile" {
+		t.Errorf("got span name %q", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span is not a child of the span in the context")
+	}
+	want := map[attribute.Key]string{
+		RepoKey: testRepo,
+		RefKey:  "main",
+		PathKey: "README.md",
+		SHAKey:  content.Sha,
+	}
+	if diff := cmp.Diff(want, attributes(span)); diff != "" {
+		t.Fatalf("incorrect attributes:\n%s", diff)
+	}
+	if span.Status().Code != codes.Unset {
+		t.Errorf("got status %v, want unset", span.Status().Code)
+	}
+}
+
+func TestClientRecordsErrors(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	c := NewClient(mock.New(t), sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	_, err := c.GetFile(context.Background(), testRepo, "main", "unknown.md")
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+
+	span := sr.Ended()[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v, want error", span.Status().Code)
+	}
+	if events := span.Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("error was not recorded, got events %v", events)
+	}
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	return attrs
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the Tracers that spans are created with.
+const InstrumentationName = "github.com/ocraviotto/pkg"
+
+// The keys of the attributes that are recorded on spans.
+const (
+	RepoKey        = attribute.Key("scm.repo")
+	BranchKey      = attribute.Key("scm.branch")
+	RefKey         = attribute.Key("scm.ref")
+	PathKey        = attribute.Key("scm.path")
+	NewPathKey     = attribute.Key("scm.new_path")
+	SHAKey         = attribute.Key("scm.sha")
+	PullRequestKey = attribute.Key("scm.pull_request")
+	OwnerKey       = attribute.Key("scm.owner")
+	ForkKey        = attribute.Key("scm.fork")
+)
+
+// Tracer returns the Tracer for the package from the TracerProvider, or from
+// the global TracerProvider if it's nil.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(InstrumentationName)
+}
+
+// End records the error on the span, if there is one, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+)
+
+// CleanupInput provides configuration for deleting generated branches.
+type CleanupInput struct {
+	Repo               string        // e.g. my-org/my-repo
+	BranchGenerateName string        // e.g. gitops-, only branches with this prefix are deleted
+	MaxAge             time.Duration // Optional, branches without PullRequests are deleted when their head is older
+}
+
+// CleanupBranches deletes the branches created with the BranchGenerateName
+// prefix that are no longer needed, and returns the names of the deleted
+// branches.
+//
+// A branch is deleted if all the PullRequests from it are merged or closed.
+// Branches with no PullRequests are deleted if MaxAge is set, and the last
+// commit to the branch is older than MaxAge. Branches with open PullRequests
+// are never deleted.
+//
+// If forking, the branches are deleted from the fork, see Fork.
+func (u *Updater) CleanupBranches(ctx context.Context, input CleanupInput) ([]string, error) {
+	if input.BranchGenerateName == "" {
+		return nil, errors.New("a BranchGenerateName is required to cleanup branches")
+	}
+	headRepo, err := u.headRepo(ctx, input.Repo)
+	if err != nil {
+		return nil, err
+	}
+	branches, err := u.gitClient.ListBranches(ctx, headRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	prs, err := u.listPRsByHead(ctx, input.Repo, headRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+
+	deleted := []string{}
+	for _, branch := range branches {
+		if !strings.HasPrefix(branch.Name, input.BranchGenerateName) {
+			continue
+		}
+		remove, err := u.isBranchRemovable(ctx, input, headRepo, branch, prs[prHead{headRepo, branch.Name}])
+		if err != nil {
+			return deleted, err
+		}
+		if !remove {
+			continue
+		}
+		if err := u.gitClient.DeleteBranch(ctx, headRepo, branch.Name); err != nil {
+			return deleted, fmt.Errorf("failed to delete branch %s: %w", branch.Name, err)
+		}
+		u.log.Info("deleted branch", "branch", branch.Name)
+		deleted = append(deleted, branch.Name)
+	}
+	return deleted, nil
+}
+
+func (u *Updater) isBranchRemovable(ctx context.Context, input CleanupInput, headRepo string, branch *scm.Reference, prs []*scm.PullRequest) (bool, error) {
+	for _, pr := range prs {
+		if !pr.Closed {
+			return false, nil
+		}
+	}
+	if len(prs) > 0 {
+		return true, nil
+	}
+	if input.MaxAge == 0 {
+		return false, nil
+	}
+	commit, err := u.gitClient.GetCommit(ctx, headRepo, branch.Sha)
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit for branch %s: %w", branch.Name, err)
+	}
+	return time.Since(commit.Committer.Date) > input.MaxAge, nil
+}
+
+// prHead identifies the source branch of a PullRequest, along with the
+// repository of the branch, as forks can have branches with the same names.
+type prHead struct {
+	repo   string
+	branch string
+}
+
+// listPRsByHead returns all the PullRequests in the repo, open or closed,
+// indexed by their source repository and branch.
+//
+// PullRequests from an unknown repository are assumed to be from the
+// headRepo, so that their branches are not deleted.
+func (u *Updater) listPRsByHead(ctx context.Context, repo, headRepo string) (map[prHead][]*scm.PullRequest, error) {
+	byHead := map[prHead][]*scm.PullRequest{}
+	for page := 1; ; page++ {
+		prs, err := u.gitClient.ListPullRequests(ctx, repo, scm.PullRequestListOptions{Page: page, Size: pullRequestPageSize, Open: true, Closed: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			head := prHead{repo: headRepo, branch: pr.Source}
+			if !isFrom(pr, headRepo) {
+				head.repo = pr.Fork
+			}
+			byHead[head] = append(byHead[head], pr)
+		}
+		if len(prs) < pullRequestPageSize {
+			return byHead, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package updater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/test"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestCleanupBranches(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	m.AddBranchHead(testGitHubRepo, testBranch, "sha-main")
+	m.AddBranchHead(testGitHubRepo, "test-branch-merged", "sha-merged")
+	m.AddBranchHead(testGitHubRepo, "test-branch-closed", "sha-closed")
+	m.AddBranchHead(testGitHubRepo, "test-branch-open", "sha-open")
+	m.AddBranchHead(testGitHubRepo, "test-branch-old", "sha-old")
+	m.AddBranchHead(testGitHubRepo, "test-branch-new", "sha-new")
+	m.AddBranchHead(testGitHubRepo, "other-old", "sha-other")
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 1, Source: "test-branch-merged", Target: testBranch, Merged: true, Closed: true})
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 2, Source: "test-branch-closed", Target: testBranch, Closed: true})
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 3, Source: "test-branch-open", Target: testBranch})
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 4, Source: "test-branch-closed", Target: testBranch, Fork: "other-org/testrepo"})
+	m.AddCommit(testGitHubRepo, "sha-old", time.Now().Add(-48*time.Hour))
+	m.AddCommit(testGitHubRepo, "sha-new", time.Now().Add(-time.Hour))
+	m.AddCommit(testGitHubRepo, "sha-other", time.Now().Add(-48*time.Hour))
+
+	deleted, err := updater.CleanupBranches(context.Background(), CleanupInput{
+		Repo:               testGitHubRepo,
+		BranchGenerateName: "test-branch-",
+		MaxAge:             24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"test-branch-closed", "test-branch-merged", "test-branch-old"}
+	if diff := cmp.Diff(want, deleted); diff != "" {
+		t.Fatalf("incorrect branches deleted:\n%s", diff)
+	}
+	for _, b := range want {
+		m.AssertBranchDeleted(testGitHubRepo, b)
+	}
+	for _, b := range []string{testBranch, "test-branch-open", "test-branch-new", "other-old"} {
+		m.RefuteBranchDeleted(testGitHubRepo, b)
+	}
+}
+
+func TestCleanupBranchesWithoutMaxAge(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	m.AddBranchHead(testGitHubRepo, "test-branch-old", "sha-old")
+	m.AddCommit(testGitHubRepo, "sha-old", time.Now().Add(-48*time.Hour))
+
+	deleted, err := updater.CleanupBranches(context.Background(), CleanupInput{
+		Repo:               testGitHubRepo,
+		BranchGenerateName: "test-branch-",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 0 {
+		t.Fatalf("got %v deleted, want none", deleted)
+	}
+}
+
+func TestCleanupBranchesWithNoPrefix(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+
+	_, err := updater.CleanupBranches(context.Background(), CleanupInput{Repo: testGitHubRepo})
+
+	if !test.MatchError(t, "BranchGenerateName is required", err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
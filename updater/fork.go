@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ocraviotto/pkg/client"
+)
+
+const (
+	defaultForkTimeout      = 5 * time.Minute
+	defaultForkPollInterval = 5 * time.Second
+)
+
+// Fork is an option func for the Updater creation function.
+//
+// It configures the Updater to create branches in a fork of the repository,
+// and to open PullRequests from the fork, for repositories that can't be
+// pushed to. The fork is owned by the owner, or by the authenticated user if
+// the owner is empty, and it's created if it doesn't exist, see
+// client.GitClient.CreateFork.
+//
+// Files are still read from the source branch in the repository, and updates
+// with DisablePRCreation are still committed directly to it. The source branch
+// in the fork is synced before branches are created from it, see
+// client.GitClient.SyncFork, forks that can't be synced, e.g. with GitLab,
+// only have the commits from when they were created, and branches can't be
+// created in them once the source branch has moved on.
+func Fork(owner string) UpdaterFunc {
+	return func(u *Updater) {
+		u.fork = true
+		u.forkOwner = owner
+	}
+}
+
+// headRepo returns the repository that branches are created in, which is the
+// fork of the repository if forking.
+//
+// The name of the fork is only looked up once for each repository, and the
+// first time, it waits until the fork has branches, as forks are created
+// asynchronously.
+func (u *Updater) headRepo(ctx context.Context, repo string) (string, error) {
+	if !u.fork {
+		return repo, nil
+	}
+	if fork, ok := u.forks.Load(repo); ok {
+		return fork.(string), nil
+	}
+	fork, err := u.gitClient.CreateFork(ctx, repo, u.forkOwner)
+	if err != nil {
+		return "", fmt.Errorf("failed to fork repo %s: %w", repo, err)
+	}
+	if err := u.waitForFork(ctx, fork); err != nil {
+		return "", err
+	}
+	u.log.Info("using fork", "repo", repo, "fork", fork)
+	u.forks.Store(repo, fork)
+	return fork, nil
+}
+
+// branchRepo returns the repository that the changes in the input are
+// committed to.
+func (u *Updater) branchRepo(ctx context.Context, input CommitInput) (string, error) {
+	if input.DisablePRCreation {
+		return input.Repo, nil
+	}
+	return u.headRepo(ctx, input.Repo)
+}
+
+// waitForFork polls the branches of the fork until there are some, or the
+// timeout expires.
+func (u *Updater) waitForFork(ctx context.Context, fork string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultForkTimeout)
+	defer cancel()
+	t := time.NewTicker(u.forkPollInterval)
+	defer t.Stop()
+	for {
+		branches, err := u.gitClient.ListBranches(ctx, fork)
+		if err == nil && len(branches) > 0 {
+			return nil
+		}
+		u.log.Info("waiting for fork", "fork", fork, "err", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed waiting for fork %s: %w", fork, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// syncFork syncs the source branch in the fork, so that the branch for the
+// update can be created from the head of the source branch in the repository,
+// and returns false if the fork can't be synced.
+func (u *Updater) syncFork(ctx context.Context, input CommitInput, fork string) (bool, error) {
+	err := u.gitClient.SyncFork(ctx, fork, input.Branch)
+	if client.IsNotSupported(err) {
+		u.log.Info("unable to sync fork", "fork", fork, "branch", input.Branch, "err", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to sync fork %s: %w", fork, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,221 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/test"
+)
+
+func TestApplyUpdateToFileInFork(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), Fork("octo-bots"))
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AssertForkCreated(testGitHubRepo, "octo-bots/testrepo")
+	m.AssertBranchCreated("octo-bots/testrepo", branch, testSHA)
+	m.RefuteBranchCreated(testGitHubRepo, branch, testSHA)
+	if s := string(m.GetUpdatedContents("octo-bots/testrepo", testFilePath, branch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("update failed, got %#v", s)
+	}
+}
+
+func TestApplyUpdateToFileWaitingForFork(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	c := &newForkClient{MockClient: m}
+	updater := New(zap.New(), c, NameGenerator(stubNameGenerator{"a"}), Fork("octo-bots"))
+	updater.forkPollInterval = time.Millisecond
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.listed != 2 {
+		t.Fatalf("branches of the fork were listed %d times, want 2", c.listed)
+	}
+	m.AssertBranchCreated("octo-bots/testrepo", branch, testSHA)
+}
+
+func TestApplyUpdatesToFilesInExistingFork(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	m.AddFork(testGitHubRepo, "", "gitops-bot/testrepo-fork")
+	m.AddBranchHead("gitops-bot/testrepo-fork", testBranch, "4a6c1f2c0dd4e6a6a5cc6ad5e32ad4c9be1bbd7c")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), Fork(""))
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), []FileUpdate{
+		{Filename: testFilePath, Update: UpdateYAML("test.image", "new-image")},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AssertForkSynced("gitops-bot/testrepo-fork", testBranch)
+	m.AssertBranchCreated("gitops-bot/testrepo-fork", branch, testSHA)
+	if s := string(m.GetUpdatedContents("gitops-bot/testrepo-fork", testFilePath, branch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("update failed, got %#v", s)
+	}
+}
+
+func TestApplyUpdateToFileInForkThatCantBeSynced(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	m.SyncForkErr = fmt.Errorf("syncing forks with driver gitlab: %w", scm.ErrNotSupported)
+	m.CreateBranchErr = client.SCMError{Msg: "failed to create branch", Status: http.StatusBadRequest, ResponseMsg: "Invalid reference name"}
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), Fork("octo-bots"))
+
+	_, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if !test.MatchError(t, `failed to create branch in fork octo-bots/testrepo, which can't be synced, and may not have commit 980a0d5f.*Invalid reference name`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
+
+func TestApplyUpdateToFileInForkWithSyncFailure(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	m.SyncForkErr = client.SCMError{Msg: "failed to sync branch main in fork octo-bots/testrepo", Status: http.StatusConflict}
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), Fork("octo-bots"))
+
+	_, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if !client.IsConflict(err) {
+		t.Fatalf("got %v, want a conflict error", err)
+	}
+	if !test.MatchError(t, `failed to sync fork octo-bots/testrepo`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+	m.AssertNoBranchesCreated()
+}
+
+func TestApplyUpdateToFileInForkCommittingDirectly(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, Fork("octo-bots"))
+	input := makeCommitInput()
+	input.DisablePRCreation = true
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), input, UpdateYAML("test.image", "new-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != testBranch {
+		t.Fatalf("got branch %s, want %s", branch, testBranch)
+	}
+	m.AssertNoBranchesCreated()
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, testBranch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("update failed, got %#v", s)
+	}
+}
+
+func TestCreatePRFromFork(t *testing.T) {
+	m := mock.New(t)
+	m.AddFork(testGitHubRepo, "octo-bots", "octo-bots/testrepo")
+	m.AddBranchHead("octo-bots/testrepo", "test-branch-a", "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), m, Fork("octo-bots"))
+
+	pr, err := updater.CreatePR(context.Background(), makePullRequestInput())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title:  "This is a test PR",
+		Body:   "This is the body",
+		Source: "test-branch-a",
+		Target: testBranch,
+	})
+	m.AssertPullRequestOptions(testGitHubRepo, pr.Number, client.PullRequestOptions{SourceRepo: "octo-bots/testrepo"})
+}
+
+func TestCreateOrUpdatePRIgnoresPRsFromOtherRepos(t *testing.T) {
+	m := mock.New(t)
+	m.AddFork(testGitHubRepo, "octo-bots", "octo-bots/testrepo")
+	m.AddBranchHead("octo-bots/testrepo", "test-branch-a", "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{
+		Number: 1,
+		Title:  "This is a test PR",
+		Source: "test-branch-a",
+		Target: testBranch,
+		Fork:   testGitHubRepo,
+	})
+	updater := New(zap.New(), m, Fork("octo-bots"))
+
+	pr, err := updater.CreateOrUpdatePR(context.Background(), makePullRequestInput())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 2 {
+		t.Fatalf("got pull request %d, want a new pull request", pr.Number)
+	}
+	if pr.Fork != "octo-bots/testrepo" {
+		t.Fatalf("got pull request from %s, want octo-bots/testrepo", pr.Fork)
+	}
+}
+
+func TestCleanupBranchesInFork(t *testing.T) {
+	m := mock.New(t)
+	m.AddFork(testGitHubRepo, "octo-bots", "octo-bots/testrepo")
+	m.AddBranchHead("octo-bots/testrepo", testBranch, "1111111111111111111111111111111111111111")
+	m.AddBranchHead("octo-bots/testrepo", "test-branch-merged", "2222222222222222222222222222222222222222")
+	m.AddBranchHead(testGitHubRepo, "test-branch-merged", "3333333333333333333333333333333333333333")
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 1, Source: "test-branch-merged", Closed: true, Merged: true, Fork: "octo-bots/testrepo"})
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 2, Source: "test-branch-merged", Fork: testGitHubRepo})
+	updater := New(zap.New(), m, Fork("octo-bots"))
+
+	deleted, err := updater.CleanupBranches(context.Background(), CleanupInput{
+		Repo:               testGitHubRepo,
+		BranchGenerateName: "test-branch-",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "test-branch-merged" {
+		t.Fatalf("got deleted branches %v, want [test-branch-merged]", deleted)
+	}
+	m.AssertBranchDeleted("octo-bots/testrepo", "test-branch-merged")
+	m.RefuteBranchDeleted(testGitHubRepo, "test-branch-merged")
+}
+
+// newForkClient simulates a fork that has no branches the first time they are
+// listed, as forks are created asynchronously.
+type newForkClient struct {
+	*mock.MockClient
+	listed int
+}
+
+func (c *newForkClient) ListBranches(ctx context.Context, repo string) ([]*scm.Reference, error) {
+	c.listed++
+	if c.listed == 1 {
+		return []*scm.Reference{}, nil
+	}
+	return c.MockClient.ListBranches(ctx, repo)
+}
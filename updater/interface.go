@@ -2,12 +2,23 @@ package updater
 
 import (
 	"context"
+	"time"
 
 	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
 )
 
 // GitUpdater defines the way to apply changes to files in Git.
 type GitUpdater interface {
 	ApplyUpdateToFile(ctx context.Context, input CommitInput, f ContentUpdater) (string, error)
+	ApplyUpdate(ctx context.Context, input CommitInput, f ContentUpdater) (*UpdateResult, error)
+	ApplyUpdatesToFiles(ctx context.Context, input CommitInput, updates []FileUpdate) (string, error)
+	ApplyUpdateToMatchingFiles(ctx context.Context, input CommitInput, pattern string, f ContentUpdater) (string, error)
+	MatchFiles(ctx context.Context, repo, ref, pattern string) ([]string, error)
 	CreatePR(ctx context.Context, input PullRequestInput) (*scm.PullRequest, error)
+	CreateOrUpdatePR(ctx context.Context, input PullRequestInput) (*scm.PullRequest, error)
+	MergePR(ctx context.Context, input MergeInput) error
+	CleanupBranches(ctx context.Context, input CleanupInput) ([]string, error)
+	WaitForBranchStatus(ctx context.Context, repo, branch string, timeout time.Duration) (*client.CombinedStatus, error)
 }
@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/tracing"
+)
+
+// ApplyUpdateToMatchingFiles does the job of finding the files in the branch
+// that match the pattern, passing each of them to the user-provided function,
+// and committing all the changes in a single commit, see ApplyUpdatesToFiles.
+//
+// The pattern is matched against the path of each file in the repository
+// using path.Match, e.g. envs/*/values.yaml, a * does not match a "/", so each
+// directory level must be matched separately.
+//
+// The Filename, CreateMissing, RemoveFile and MoveTo fields of the input are
+// ignored. If no files match the pattern, an error is returned.
+func (u *Updater) ApplyUpdateToMatchingFiles(ctx context.Context, input CommitInput, pattern string, f ContentUpdater) (branch string, err error) {
+	ctx, span := u.startSpan(ctx, "ApplyUpdateToMatchingFiles", input.Repo,
+		tracing.BranchKey.String(input.Branch), tracing.PathKey.String(pattern))
+	defer func() {
+		span.SetAttributes(newBranchKey.String(branch))
+		tracing.End(span, err)
+	}()
+	headRepo, err := u.branchRepo(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	repo, ref, err := u.baseRef(ctx, input, headRepo)
+	if err != nil {
+		return "", err
+	}
+	filenames, err := u.MatchFiles(ctx, repo, ref, pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(filenames) == 0 {
+		return "", fmt.Errorf("no files match %s in repo %s branch %s", pattern, input.Repo, input.Branch)
+	}
+	u.log.Info("found matching files", "pattern", pattern, "count", len(filenames))
+
+	updates := make([]FileUpdate, len(filenames))
+	for i, filename := range filenames {
+		updates[i] = FileUpdate{Filename: filename, Update: f}
+	}
+	return u.ApplyUpdatesToFiles(ctx, input, updates)
+}
+
+// MatchFiles returns the paths of the files at the ref that match the pattern,
+// see ApplyUpdateToMatchingFiles.
+//
+// Only the directory before the first wildcard in the pattern is listed, and
+// if the directory does not exist, no paths are returned.
+func (u *Updater) MatchFiles(ctx context.Context, repo, ref, pattern string) ([]string, error) {
+	pattern = strings.Trim(pattern, "/")
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	dir := patternDir(pattern)
+	rest := strings.TrimPrefix(strings.TrimPrefix(pattern, dir), "/")
+	files, err := u.gitClient.ListFiles(ctx, repo, ref, dir, strings.Contains(rest, "/"))
+	if client.IsNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", dir, err)
+	}
+
+	matches := []string{}
+	for _, file := range files {
+		if file.Kind != client.KindFile {
+			continue
+		}
+		if ok, _ := path.Match(pattern, file.Path); ok {
+			matches = append(matches, file.Path)
+		}
+	}
+	return matches, nil
+}
+
+// patternDir returns the directory in the pattern before the first element
+// with a wildcard.
+func patternDir(pattern string) string {
+	elements := strings.Split(pattern, "/")
+	for i, element := range elements {
+		if strings.ContainsAny(element, `*?[\`) {
+			return strings.Join(elements[:i], "/")
+		}
+	}
+	if dir := path.Dir(pattern); dir != "." {
+		return dir
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/test"
+)
+
+func TestApplyUpdateToMatchingFiles(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	matchingPaths := []string{"envs/dev/values.yaml", "envs/prod/values.yaml"}
+	m := mock.New(t)
+	for _, path := range matchingPaths {
+		m.AddFileContents(testGitHubRepo, path, testBranch, []byte("image: old-image\n"))
+	}
+	m.AddFileContents(testGitHubRepo, "envs/dev/other.yaml", testBranch, []byte("image: old-image\n"))
+	m.AddFileContents(testGitHubRepo, "envs/prod/nested/values.yaml", testBranch, []byte("image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	branch, err := updater.ApplyUpdateToMatchingFiles(context.Background(), makeCommitInput(), "envs/*/values.yaml", UpdateYAML("image", "new-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range matchingPaths {
+		if s := string(m.GetUpdatedContents(testGitHubRepo, path, branch)); s != "image: new-image\n" {
+			t.Fatalf("update to %s failed, got %#v, want %#v", path, s, "image: new-image\n")
+		}
+	}
+	for _, path := range []string{"envs/dev/other.yaml", "envs/prod/nested/values.yaml"} {
+		if b := m.GetUpdatedContents(testGitHubRepo, path, branch); b != nil {
+			t.Fatalf("%s was updated", path)
+		}
+	}
+	m.AssertCommitCount(1)
+	m.AssertBranchCreated(testGitHubRepo, branch, testSHA)
+}
+
+func TestApplyUpdateToMatchingFilesWithNoMatches(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, "envs/dev/values.yaml", testBranch, []byte("image: old-image\n"))
+	updater := New(zap.New(), m)
+
+	_, err := updater.ApplyUpdateToMatchingFiles(context.Background(), makeCommitInput(), "services/*/values.yaml", UpdateYAML("image", "new-image"))
+
+	if !test.MatchError(t, "no files match services/\\*/values.yaml", err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+	m.AssertNoInteractions()
+}
+
+func TestMatchFiles(t *testing.T) {
+	m := mock.New(t)
+	for _, path := range []string{"README.md", "envs/dev/values.yaml", "envs/prod/values.yaml", "envs/prod/nested/values.yaml", "apps/dev.yaml"} {
+		m.AddFileContents(testGitHubRepo, path, testBranch, []byte("testing"))
+	}
+	updater := New(zap.New(), m)
+
+	matchTests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"envs/*/values.yaml", []string{"envs/dev/values.yaml", "envs/prod/values.yaml"}},
+		{"envs/prod/*/values.yaml", []string{"envs/prod/nested/values.yaml"}},
+		{"*.md", []string{"README.md"}},
+		{"apps/[a-e]*.yaml", []string{"apps/dev.yaml"}},
+		{"envs/dev/values.yaml", []string{"envs/dev/values.yaml"}},
+		{"unknown/*.yaml", []string{}},
+	}
+
+	for _, tt := range matchTests {
+		t.Run(tt.pattern, func(rt *testing.T) {
+			matches, err := updater.MatchFiles(context.Background(), testGitHubRepo, testBranch, tt.pattern)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, matches); diff != "" {
+				rt.Fatalf("incorrect matches:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchFilesWithInvalidPattern(t *testing.T) {
+	updater := New(zap.New(), mock.New(t))
+
+	_, err := updater.MatchFiles(context.Background(), testGitHubRepo, testBranch, "envs/[/values.yaml")
+
+	if !test.MatchError(t, "invalid pattern", err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+}
@@ -0,0 +1,136 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ocraviotto/go-scm/scm"
+
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/tracing"
+)
+
+const (
+	defaultStatusTimeout      = 10 * time.Minute
+	defaultStatusPollInterval = 10 * time.Second
+)
+
+// MergeInput provides configuration for merging a PullRequest.
+type MergeInput struct {
+	Repo            string              // e.g. my-org/my-repo
+	Number          int                 // The number of the PullRequest to merge
+	Options         client.MergeOptions // e.g. the merge method
+	WaitForStatuses bool                // Whether to wait for the commit statuses to succeed before merging
+	StatusTimeout   time.Duration       // How long to wait for the commit statuses, defaults to 10 minutes
+}
+
+// StatusPollInterval is an option func for the Updater creation function.
+//
+// It configures how often the commit statuses are checked when waiting for
+// them to succeed, intervals that are not positive are ignored.
+func StatusPollInterval(d time.Duration) UpdaterFunc {
+	return func(u *Updater) {
+		if d > 0 {
+			u.statusPollInterval = d
+		}
+	}
+}
+
+// MergePR merges a PullRequest, e.g. one opened with CreatePR.
+//
+// If WaitForStatuses is set, the commit statuses of the head of the
+// PullRequest are checked until all of them succeed, and the PullRequest is
+// only merged if its head has not changed since. An error is returned if any
+// status fails, or no successful statuses are reported before the
+// StatusTimeout.
+//
+// If the source branch can't be deleted after merging, the error is logged,
+// and not returned, as the PullRequest was merged.
+func (u *Updater) MergePR(ctx context.Context, input MergeInput) (err error) {
+	ctx, span := u.startSpan(ctx, "MergePR", input.Repo, tracing.PullRequestKey.Int(input.Number))
+	defer func() { tracing.End(span, err) }()
+	opts := input.Options
+	if input.WaitForStatuses {
+		pr, err := u.gitClient.FindPullRequest(ctx, input.Repo, input.Number)
+		if err != nil {
+			return fmt.Errorf("failed to find pull request %d: %w", input.Number, err)
+		}
+		timeout := input.StatusTimeout
+		if timeout == 0 {
+			timeout = defaultStatusTimeout
+		}
+		status, err := u.waitForStatus(ctx, input.Repo, timeout, func(context.Context) (string, error) {
+			return pr.Sha, nil
+		})
+		if err != nil {
+			return err
+		}
+		if status.State == scm.StateFailure {
+			return fmt.Errorf("commit %s has failed statuses: %s", pr.Sha, strings.Join(status.Failed(), ", "))
+		}
+		if opts.SHA == "" {
+			opts.SHA = pr.Sha
+		}
+	}
+	err = u.gitClient.MergePullRequest(ctx, input.Repo, input.Number, opts)
+	if errors.Is(err, client.ErrBranchNotDeleted) {
+		u.log.Info("failed to delete branch after merging", "err", err, "number", input.Number)
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to merge pull request %d: %w", input.Number, err)
+	}
+	u.log.Info("merged PullRequest", "number", input.Number)
+	return nil
+}
+
+// WaitForBranchStatus waits until the commit statuses for the head of the
+// branch either succeed or fail, and returns the combined status, see
+// client.CombineStatuses.
+//
+// The head of the branch is looked up each time the statuses are checked, so
+// if the branch is updated while waiting, the status of the new head is
+// returned.
+//
+// An error is returned if the timeout expires or the context is cancelled
+// before the statuses complete.
+func (u *Updater) WaitForBranchStatus(ctx context.Context, repo, branch string, timeout time.Duration) (*client.CombinedStatus, error) {
+	return u.waitForStatus(ctx, repo, timeout, func(ctx context.Context) (string, error) {
+		sha, err := u.gitClient.GetBranchHead(ctx, repo, branch)
+		if err != nil {
+			return "", fmt.Errorf("failed to get branch head for %s: %w", branch, err)
+		}
+		return sha, nil
+	})
+}
+
+// waitForStatus polls the combined status for the commit returned by
+// headSHA until it completes, or the timeout expires.
+func (u *Updater) waitForStatus(ctx context.Context, repo string, timeout time.Duration, headSHA func(context.Context) (string, error)) (*client.CombinedStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	t := time.NewTicker(u.statusPollInterval)
+	defer t.Stop()
+	for {
+		sha, err := headSHA(ctx)
+		if err != nil {
+			return nil, err
+		}
+		status, err := u.gitClient.GetCombinedStatus(ctx, repo, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get combined status for %s: %w", sha, err)
+		}
+		if status.State == scm.StateSuccess || status.State == scm.StateFailure {
+			return status, nil
+		}
+		u.log.Info("waiting for commit statuses", "sha", sha)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed waiting for statuses for %s: %w", sha, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ocraviotto/go-scm/scm"
+	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/test"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const testHeadSHA = "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+
+func TestMergePR(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	pr := createTestPR(t, m, updater)
+	opts := client.MergeOptions{Method: client.MergeMethodSquash, DeleteBranch: true}
+
+	err := updater.MergePR(context.Background(), MergeInput{Repo: testGitHubRepo, Number: pr.Number, Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestMerged(testGitHubRepo, pr.Number, opts)
+}
+
+func TestMergePRFromFork(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	m.AddBranchHead("testuser/testrepo", "test-branch", testHeadSHA)
+	m.AddBranchHead(testGitHubRepo, "test-branch", testHeadSHA)
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 1, Source: "test-branch", Target: testBranch, Fork: "testuser/testrepo"})
+	opts := client.MergeOptions{DeleteBranch: true}
+
+	err := updater.MergePR(context.Background(), MergeInput{Repo: testGitHubRepo, Number: 1, Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestMerged(testGitHubRepo, 1, opts)
+	m.AssertBranchDeleted("testuser/testrepo", "test-branch")
+	m.RefuteBranchDeleted(testGitHubRepo, "test-branch")
+}
+
+func TestMergePRWithBranchDeletionFailure(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	pr := createTestPR(t, m, updater)
+	m.DeleteBranchErr = errors.New("mock error")
+	opts := client.MergeOptions{DeleteBranch: true}
+
+	err := updater.MergePR(context.Background(), MergeInput{Repo: testGitHubRepo, Number: pr.Number, Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestMerged(testGitHubRepo, pr.Number, opts)
+}
+
+func TestMergePRWaitingForStatuses(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	pr := createTestPR(t, m, updater)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StateSuccess})
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StateFailure})
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/lint", State: scm.StateSuccess})
+
+	err := updater.MergePR(context.Background(), MergeInput{Repo: testGitHubRepo, Number: pr.Number, WaitForStatuses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestMerged(testGitHubRepo, pr.Number, client.MergeOptions{SHA: testHeadSHA})
+}
+
+func TestMergePRWithFailedStatuses(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m)
+	pr := createTestPR(t, m, updater)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StateSuccess})
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/lint", State: scm.StateError})
+
+	err := updater.MergePR(context.Background(), MergeInput{Repo: testGitHubRepo, Number: pr.Number, WaitForStatuses: true})
+
+	if !test.MatchError(t, `commit 980a0d5f.* has failed statuses: ci/lint$`, err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+	m.RefutePullRequestMerged(testGitHubRepo, pr.Number)
+}
+
+func TestMergePRWithPendingStatuses(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, StatusPollInterval(time.Millisecond))
+	pr := createTestPR(t, m, updater)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StatePending})
+
+	err := updater.MergePR(context.Background(), MergeInput{
+		Repo:            testGitHubRepo,
+		Number:          pr.Number,
+		WaitForStatuses: true,
+		StatusTimeout:   10 * time.Millisecond,
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	m.RefutePullRequestMerged(testGitHubRepo, pr.Number)
+}
+
+func TestMergePRWithInvalidStatusPollInterval(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, StatusPollInterval(0))
+	pr := createTestPR(t, m, updater)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StatePending})
+
+	err := updater.MergePR(context.Background(), MergeInput{
+		Repo:            testGitHubRepo,
+		Number:          pr.Number,
+		WaitForStatuses: true,
+		StatusTimeout:   10 * time.Millisecond,
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitForBranchStatus(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, StatusPollInterval(time.Millisecond))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testHeadSHA)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StateFailure})
+
+	status, err := updater.WaitForBranchStatus(context.Background(), testGitHubRepo, "test-branch-a", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &client.CombinedStatus{
+		SHA:      testHeadSHA,
+		State:    scm.StateFailure,
+		Statuses: []*scm.Status{{Label: "ci/build", State: scm.StateFailure}},
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Fatalf("incorrect status:\n%s", diff)
+	}
+}
+
+func TestWaitForBranchStatusWithCancelledContext(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, StatusPollInterval(time.Millisecond))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testHeadSHA)
+	m.AddStatus(testGitHubRepo, testHeadSHA, &scm.Status{Label: "ci/build", State: scm.StateRunning})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := updater.WaitForBranchStatus(ctx, testGitHubRepo, "test-branch-a", time.Minute)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func createTestPR(t *testing.T, m *mock.MockClient, u *Updater) *scm.PullRequest {
+	t.Helper()
+	input := makePullRequestInput()
+	m.AddBranchHead(testGitHubRepo, input.NewBranch, testHeadSHA)
+	pr, err := u.CreatePR(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
@@ -0,0 +1,32 @@
+package updater
+
+// MetricsRecorder records the outcomes of the Updater's operations, see the
+// metrics package for an implementation that uses Prometheus.
+type MetricsRecorder interface {
+	// BranchCreated is called when a branch is created for an update.
+	BranchCreated(repo string)
+	// PullRequestCreated is called when a PullRequest is created.
+	PullRequestCreated(repo string)
+	// UpdateSkipped is called when an update makes no changes.
+	UpdateSkipped(repo string)
+	// ConflictRetried is called when a change is retried because it was
+	// rejected as a conflict.
+	ConflictRetried(repo string)
+}
+
+// Metrics is an option func for the Updater creation function.
+//
+// It configures the recorder that the outcomes of operations are recorded
+// with, by default they are not recorded.
+func Metrics(r MetricsRecorder) UpdaterFunc {
+	return func(u *Updater) {
+		u.metrics = r
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) BranchCreated(string)      {}
+func (noopMetrics) PullRequestCreated(string) {}
+func (noopMetrics) UpdateSkipped(string)      {}
+func (noopMetrics) ConflictRetried(string)    {}
@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client/mock"
+)
+
+func TestMetricsAreRecorded(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	metrics := &stubMetrics{}
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond), Metrics(metrics))
+	calls := 0
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), func(b []byte) ([]byte, error) {
+		calls++
+		if calls == 1 {
+			// simulate another commit to the file after it was read.
+			m.AddFileContents(testGitHubRepo, testFilePath, "test-branch-a", []byte("test:\n  image: old-image\n  replicas: 2\n"))
+		}
+		return UpdateYAML("test.image", "new-image")(b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = updater.CreatePR(context.Background(), PullRequestInput{Repo: testGitHubRepo, Title: "testing", NewBranch: branch, SourceBranch: testBranch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = updater.ApplyUpdate(context.Background(), makeCommitInput(), ReplaceContents([]byte("test:\n  image: old-image\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &stubMetrics{branches: 1, pullRequests: 1, skipped: 1, conflicts: 1}
+	if diff := cmp.Diff(want, metrics, cmp.AllowUnexported(stubMetrics{})); diff != "" {
+		t.Fatalf("incorrect metrics:\n%s", diff)
+	}
+}
+
+type stubMetrics struct {
+	branches     int
+	pullRequests int
+	skipped      int
+	conflicts    int
+}
+
+func (s *stubMetrics) BranchCreated(string)      { s.branches++ }
+func (s *stubMetrics) PullRequestCreated(string) { s.pullRequests++ }
+func (s *stubMetrics) UpdateSkipped(string)      { s.skipped++ }
+func (s *stubMetrics) ConflictRetried(string)    { s.conflicts++ }
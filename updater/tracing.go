@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"context"
+
+	"github.com/ocraviotto/go-scm/scm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ocraviotto/pkg/tracing"
+)
+
+// newBranchKey is the attribute for the branch that changes are committed to,
+// see UpdateResult.Branch.
+const newBranchKey = attribute.Key("updater.new_branch")
+
+// TracerProvider is an option func for the Updater creation function.
+//
+// It configures the TracerProvider that spans for the Updater's operations are
+// created with, by default the global TracerProvider is used. Wrap the
+// GitClient with tracing.NewClient to also create spans for each request.
+func TracerProvider(tp trace.TracerProvider) UpdaterFunc {
+	return func(u *Updater) {
+		u.tracer = tracing.Tracer(tp)
+	}
+}
+
+func (u *Updater) startSpan(ctx context.Context, operation, repo string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return u.tracer.Start(ctx, "Updater."+operation,
+		trace.WithAttributes(append([]attribute.KeyValue{tracing.RepoKey.String(repo)}, attrs...)...))
+}
+
+// endUpdateSpan records the branch and commit of the result on the span, and
+// ends it.
+func endUpdateSpan(span trace.Span, res *UpdateResult, err error) {
+	if res != nil {
+		span.SetAttributes(
+			newBranchKey.String(res.Branch),
+			tracing.SHAKey.String(res.CommitSHA),
+			attribute.Bool("updater.changed", res.Changed))
+	}
+	tracing.End(span, err)
+}
+
+// endPullRequestSpan records the number and head of the PullRequest on the
+// span, and ends it.
+func endPullRequestSpan(span trace.Span, pr *scm.PullRequest, err error) {
+	if pr != nil {
+		span.SetAttributes(tracing.PullRequestKey.Int(pr.Number), tracing.SHAKey.String(pr.Sha))
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/tracing"
+)
+
+func TestSpansAreCreated(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), tracing.NewClient(m, tp), NameGenerator(stubNameGenerator{"a"}), TracerProvider(tp))
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = updater.CreatePR(context.Background(), PullRequestInput{Repo: testGitHubRepo, Title: "testing", NewBranch: branch, SourceBranch: testBranch})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Updater.ApplyUpdate",
+		"  GitClient.GetFile",
+		"  GitClient.GetBranchHead",
+		"  GitClient.CreateBranch",
+		"  GitClient.UpdateFile",
+		"  GitClient.GetBranchHead",
+		"Updater.CreatePR",
+		"  GitClient.CreatePullRequest",
+	}
+	if diff := cmp.Diff(want, spanTree(sr.Ended())); diff != "" {
+		t.Fatalf("incorrect spans:\n%s", diff)
+	}
+
+	spans := spansByName(sr.Ended())
+	wantAttrs := map[attribute.Key]string{
+		tracing.RepoKey:   testGitHubRepo,
+		tracing.BranchKey: testBranch,
+		tracing.PathKey:   testFilePath,
+		newBranchKey:      "test-branch-a",
+		tracing.SHAKey:    "ec5f1b38ac9ed6031957f3c96476a742158b52ca",
+		"updater.changed": "true",
+	}
+	if diff := cmp.Diff(wantAttrs, spanAttributes(spans["Updater.ApplyUpdate"])); diff != "" {
+		t.Fatalf("incorrect attributes:\n%s", diff)
+	}
+}
+
+// spanTree returns the names of the spans, ordered by start time, indented by
+// their depth.
+func spanTree(spans []sdktrace.ReadOnlySpan) []string {
+	children := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		parent := ""
+		if s.Parent().IsValid() {
+			parent = s.Parent().SpanID().String()
+		}
+		children[parent] = append(children[parent], s)
+	}
+	var names []string
+	var walk func(id, indent string)
+	walk = func(id, indent string) {
+		c := children[id]
+		sort.Slice(c, func(i, j int) bool { return c[i].StartTime().Before(c[j].StartTime()) })
+		for _, s := range c {
+			names = append(names, indent+s.Name())
+			walk(s.SpanContext().SpanID().String(), indent+"  ")
+		}
+	}
+	walk("", "")
+	return names
+}
+
+func spansByName(spans []sdktrace.ReadOnlySpan) map[string]sdktrace.ReadOnlySpan {
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+	return byName
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	return attrs
+}
@@ -1,16 +1,24 @@
 package updater
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/rand"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/ocraviotto/go-scm/scm"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ocraviotto/pkg/client"
+	"github.com/ocraviotto/pkg/diff"
 	"github.com/ocraviotto/pkg/names"
+	"github.com/ocraviotto/pkg/syaml"
+	"github.com/ocraviotto/pkg/tracing"
 )
 
 // ContentUpdater takes an existing body, it should transform it, and return the
@@ -22,15 +30,56 @@ type UpdaterFunc func(u *Updater)
 
 // CommitInput is used to configure the commit and pull request.
 type CommitInput struct {
-	Repo               string        // e.g. my-org/my-repo
-	Filename           string        // relative path to the file in the repository
-	Branch             string        // e.g. main
-	BranchGenerateName string        // e.g. gitops-
-	DisablePRCreation  bool          // Whether to disable PR creation
-	CreateMissing      bool          // Whether to create the target file if it's missing
-	RemoveFile         bool          // Whether to remove the target file
-	CommitMessage      string        // This is used for the commit when updating the file
-	Signature          scm.Signature // This identifies a git commit creator
+	Repo               string          // e.g. my-org/my-repo
+	Filename           string          // relative path to the file in the repository
+	Branch             string          // e.g. main
+	BranchGenerateName string          // e.g. gitops-
+	DisablePRCreation  bool            // Whether to disable PR creation
+	CreateMissing      bool            // Whether to create the target file if it's missing
+	RemoveFile         bool            // Whether to remove the target file
+	MoveTo             string          // relative path to move the target file to, the update is applied to its content
+	Mode               client.FileMode // optional, the mode to set when the file is changed, this requires client.GitClient.CommitFiles
+	CommitMessage      string          // This is used for the commit when updating the file
+	Signature          scm.Signature   // This identifies a git commit creator
+	ChangeID           string          // e.g. bump-service-a, reuses the branch of earlier updates with the same ID
+}
+
+// FileUpdate describes the change to a single file when updating multiple
+// files in one commit.
+type FileUpdate struct {
+	Filename      string          // relative path to the file in the repository
+	Update        ContentUpdater  // This is not called when removing the file
+	CreateMissing bool            // Whether to create the target file if it's missing
+	RemoveFile    bool            // Whether to remove the target file
+	MoveTo        string          // relative path to move the target file to, the update is applied to its content
+	Mode          client.FileMode // optional, the mode to set when the file is changed
+}
+
+// Operation identifies the change that an update made to a file.
+type Operation string
+
+const (
+	// OperationCreate is used when an update created a missing file.
+	OperationCreate Operation = "create"
+	// OperationUpdate is used when an update changed an existing file.
+	OperationUpdate Operation = "update"
+	// OperationDelete is used when an update removed a file.
+	OperationDelete Operation = "delete"
+	// OperationMove is used when an update moved a file.
+	OperationMove Operation = "move"
+)
+
+// UpdateResult describes the outcome of applying an update to a file.
+type UpdateResult struct {
+	Branch       string    // The branch the update was committed to, empty if no branch was needed
+	SourceBranch string    // The branch the update was based on, e.g. main
+	SourceSHA    string    // The commit the branch was at before the update, e.g. the head of the source branch
+	CommitSHA    string    // The commit created for the update
+	PreviousSHA  string    // The blob SHA of the file before the update, empty if it was created
+	NewSHA       string    // The blob SHA of the file after the update, empty if it was deleted
+	Changed      bool      // Whether the update changed the file
+	Operation    Operation // What the update did to the file
+	Diff         string    // A unified diff of the change to the file
 }
 
 // PullRequestInput provides configuration for the PullRequest to be opened.
@@ -40,10 +89,20 @@ type PullRequestInput struct {
 	Repo         string // e.g. my-org/my-repo
 	Title        string
 	Body         string
+	// Options are applied when the PullRequest is created, e.g. labels,
+	// reviewers and draft.
+	Options client.PullRequestOptions
 }
 
 var timeSeed = rand.New(rand.NewSource(time.Now().UnixNano()))
 
+const (
+	defaultConflictRetries = 3
+	defaultConflictBackoff = time.Second
+	maxConflictBackoff     = 30 * time.Second
+	pullRequestPageSize    = 100
+)
+
 // NameGenerator is an option func for the Updater creation function.
 func NameGenerator(g names.Generator) UpdaterFunc {
 	return func(u *Updater) {
@@ -51,9 +110,31 @@ func NameGenerator(g names.Generator) UpdaterFunc {
 	}
 }
 
+// ConflictRetries is an option func for the Updater creation function.
+//
+// It configures how many times a change is retried when it's rejected because
+// the files were changed by someone else, and the delay before the first
+// retry, which doubles for each subsequent retry.
+func ConflictRetries(n int, backoff time.Duration) UpdaterFunc {
+	return func(u *Updater) {
+		u.conflictRetries = n
+		u.conflictBackoff = backoff
+	}
+}
+
 // New creates and returns a new Updater.
 func New(l logr.Logger, c client.GitClient, opts ...UpdaterFunc) *Updater {
-	u := &Updater{gitClient: c, nameGenerator: names.New(timeSeed), log: l}
+	u := &Updater{
+		gitClient:          c,
+		nameGenerator:      names.New(timeSeed),
+		log:                l,
+		conflictRetries:    defaultConflictRetries,
+		conflictBackoff:    defaultConflictBackoff,
+		statusPollInterval: defaultStatusPollInterval,
+		forkPollInterval:   defaultForkPollInterval,
+		metrics:            noopMetrics{},
+		tracer:             tracing.Tracer(nil),
+	}
 	for _, o := range opts {
 		o(u)
 	}
@@ -62,103 +143,698 @@ func New(l logr.Logger, c client.GitClient, opts ...UpdaterFunc) *Updater {
 
 // Updater can update a Git repo with an updated version of a file.
 type Updater struct {
-	gitClient     client.GitClient
-	nameGenerator names.Generator
-	log           logr.Logger
+	gitClient          client.GitClient
+	nameGenerator      names.Generator
+	log                logr.Logger
+	conflictRetries    int
+	conflictBackoff    time.Duration
+	statusPollInterval time.Duration
+	metrics            MetricsRecorder
+	tracer             trace.Tracer
+	fork               bool
+	forkOwner          string
+	forkPollInterval   time.Duration
+	forks              sync.Map // the name of the fork by repo
 }
 
 // ApplyUpdateToFile does the job of fetching a file, passing it to a
 // user-provided function if not deleting it, and optionally creating a PR.
+//
+// If the update makes no changes to the file, no branch is created and an
+// empty branch name is returned, even if DisablePRCreation is set, use
+// ApplyUpdate to get more details.
 func (u *Updater) ApplyUpdateToFile(ctx context.Context, input CommitInput, f ContentUpdater) (string, error) {
-	var (
-		updated         []byte
-		isNotFoundError bool
-		currentSHA      string
-	)
-
-	isFileOp := input.RemoveFile || input.CreateMissing
-	current, err := u.gitClient.GetFile(ctx, input.Repo, input.Branch, input.Filename)
-	if err != nil {
-		isNotFoundError = client.IsNotFound(err)
-		if !isNotFoundError || (isNotFoundError && !isFileOp) {
-			u.log.Info("failed to get file from repo", "err", err)
+	res, err := u.ApplyUpdate(ctx, input, f)
+	if err != nil {
+		return "", err
+	}
+	return res.Branch, nil
+}
+
+// ApplyUpdate does the job of fetching a file, passing it to a user-provided
+// function if not deleting it, and committing the change, to a new branch
+// unless DisablePRCreation is set.
+//
+// If a ChangeID is provided, and a branch for the change already exists, with
+// an open PullRequest, the update is applied to the file in that branch, and
+// committed to it. If the PullRequest was merged or closed, the branch is
+// deleted and created again from the source branch.
+//
+// If the update makes no changes to the file, nothing is committed, no branch
+// is created, and the Branch of the result is empty, or the existing branch for
+// the ChangeID. The same applies if the update makes no changes when it's
+// reapplied after a conflict, and the branch that was created for it is
+// deleted, unless it was committed to, e.g. if the conflict was caused by the
+// update itself being committed. YAML files are compared semantically, so an
+// update that only changes formatting is not applied.
+//
+// If MoveTo is set, the file is moved in a single commit, with the content
+// returned by the user-provided function, this can't be combined with
+// CreateMissing or RemoveFile.
+//
+// If the file is changed by someone else before the update is written, it's
+// fetched again and the user-provided function is reapplied, see
+// ConflictRetries.
+func (u *Updater) ApplyUpdate(ctx context.Context, input CommitInput, f ContentUpdater) (res *UpdateResult, err error) {
+	ctx, span := u.startSpan(ctx, "ApplyUpdate", input.Repo,
+		tracing.BranchKey.String(input.Branch), tracing.PathKey.String(input.Filename))
+	defer func() { endUpdateSpan(span, res, err) }()
+	if err := validateMove(input.Filename, input.MoveTo, input.CreateMissing, input.RemoveFile); err != nil {
+		return nil, err
+	}
+	headRepo, err := u.branchRepo(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	repo, ref, err := u.baseRef(ctx, input, headRepo)
+	if err != nil {
+		return nil, err
+	}
+	update, err := u.prepareUpdate(ctx, input, repo, ref, f)
+	if err != nil {
+		return nil, err
+	}
+	if !update.changed {
+		u.log.Info("update made no changes, skipping", "filename", input.Filename)
+		u.metrics.UpdateSkipped(input.Repo)
+		res = update.result(input)
+		if ref != input.Branch {
+			res.Branch = ref
+		}
+		return res, nil
+	}
+
+	return u.applyUpdate(ctx, input, headRepo, update, f)
+}
+
+// ApplyUpdatesToFiles does the job of fetching each of the files, passing them
+// to the user-provided functions if not deleting them, and committing all the
+// changes in a single commit.
+//
+// The Filename, CreateMissing, RemoveFile and MoveTo fields of the input are
+// ignored, and are taken from each FileUpdate instead.
+//
+// The changes are committed to a single new branch, or directly to the source
+// branch if DisablePRCreation is set, and the name of the branch is returned.
+//
+// Files that are not changed by their update are left out of the commit, and
+// if no files are changed, no branch is created and an empty branch name is
+// returned, as for ApplyUpdate.
+func (u *Updater) ApplyUpdatesToFiles(ctx context.Context, input CommitInput, updates []FileUpdate) (branch string, err error) {
+	paths := make([]string, len(updates))
+	for i := range updates {
+		paths[i] = updates[i].Filename
+	}
+	ctx, span := u.startSpan(ctx, "ApplyUpdatesToFiles", input.Repo,
+		tracing.BranchKey.String(input.Branch), tracing.PathKey.StringSlice(paths))
+	var sha string
+	defer func() {
+		span.SetAttributes(newBranchKey.String(branch), tracing.SHAKey.String(sha))
+		tracing.End(span, err)
+	}()
+	headRepo, err := u.branchRepo(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	repo, ref, err := u.baseRef(ctx, input, headRepo)
+	if err != nil {
+		return "", err
+	}
+	changes, err := u.prepareChanges(ctx, input, repo, ref, updates)
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		u.log.Info("updates made no changes, skipping")
+		u.metrics.UpdateSkipped(input.Repo)
+		if ref != input.Branch {
+			return ref, nil
+		}
+		return "", nil
+	}
+
+	branchRef, err := u.gitClient.GetBranchHead(ctx, input.Repo, input.Branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch head: %v", err)
+	}
+	target, err := u.createBranchIfNecessary(ctx, input, headRepo, branchRef)
+	if err != nil {
+		return "", err
+	}
+	newBranchName := target.name
+
+	for attempt := 1; ; attempt++ {
+		sha, err = u.gitClient.CommitFiles(ctx, headRepo, newBranchName, input.CommitMessage, input.Signature, changes)
+		if !client.IsConflict(err) || attempt > u.conflictRetries {
+			break
+		}
+		u.log.Info("files changed concurrently, retrying", "branch", newBranchName, "attempt", attempt)
+		u.metrics.ConflictRetried(input.Repo)
+		if err := u.backoff(ctx, attempt); err != nil {
+			return "", err
+		}
+		changes, err = u.prepareChanges(ctx, input, headRepo, newBranchName, updates)
+		if err != nil {
 			return "", err
 		}
+		if len(changes) == 0 {
+			u.log.Info("updates made no changes after retrying, skipping", "branch", newBranchName)
+			u.metrics.UpdateSkipped(input.Repo)
+			return u.skippedBranch(ctx, input, headRepo, target)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to commit files: %w", err)
+	}
+	u.log.Info("committed files", "count", len(changes), "sha", sha)
+	return newBranchName, nil
+}
+
+// pendingUpdate is an update to a file that is ready to be written.
+type pendingUpdate struct {
+	previousSHA string
+	previous    *scm.Content
+	body        []byte
+	operation   Operation
+	changed     bool
+}
+
+// result returns an UpdateResult describing the update, the branch and
+// commit details are only populated if the update changed the file.
+func (p *pendingUpdate) result(input CommitInput) *UpdateResult {
+	res := &UpdateResult{
+		SourceBranch: input.Branch,
+		Changed:      p.changed,
+		Operation:    p.operation,
+	}
+	from, to := "a/"+input.Filename, "b/"+input.Filename
+	if input.MoveTo != "" {
+		to = "b/" + input.MoveTo
+	}
+	var after []byte
+	switch p.operation {
+	case OperationCreate:
+		from = "/dev/null"
+		res.NewSHA = client.BlobSHA(p.body)
+		after = p.body
+	case OperationDelete:
+		to = "/dev/null"
+		res.PreviousSHA = p.previousBlobSHA()
+	default:
+		res.PreviousSHA = p.previousBlobSHA()
+		res.NewSHA = client.BlobSHA(p.body)
+		after = p.body
+	}
+	if p.changed {
+		res.Diff = diff.Unified(from, to, p.previous.Data, after)
+	}
+	return res
+}
+
+func (p *pendingUpdate) previousBlobSHA() string {
+	if p.previous.BlobID != "" {
+		return p.previous.BlobID
+	}
+	return client.BlobSHA(p.previous.Data)
+}
+
+// contentSHA returns the SHA to pass as the previous SHA when changing the
+// file, this is the BlobID for GitHub, which doesn't return a Sha.
+func contentSHA(c *scm.Content) string {
+	if c.Sha != "" {
+		return c.Sha
 	}
-	if isNotFoundError && input.RemoveFile {
-		return "", fmt.Errorf("removing a non-existing file %s in branch %s is not necessary", input.Filename, input.Branch)
+	return c.BlobID
+}
+
+// prepareUpdate fetches the file from the ref in the repo, and applies the
+// update to it.
+func (u *Updater) prepareUpdate(ctx context.Context, input CommitInput, repo, ref string, f ContentUpdater) (*pendingUpdate, error) {
+	current, isNotFoundError, err := u.getFile(ctx, repo, ref, input.Filename, input.CreateMissing, input.RemoveFile)
+	if err != nil {
+		return nil, err
 	}
-	if current.Sha != "" {
-		currentSHA = current.Sha
-		u.log.Info("got existing file", "sha", current.Sha)
+	currentSHA := contentSHA(current)
+	if currentSHA != "" {
+		u.log.Info("got existing file", "sha", currentSHA)
 	} else if isNotFoundError {
-		currentSHA, err = u.gitClient.GetBranchHead(ctx, input.Repo, input.Branch)
+		currentSHA, err = u.gitClient.GetBranchHead(ctx, repo, ref)
 		if err != nil {
-			u.log.Info("unable to get parent sha for branch, if branch is main, it may still succeed", "err", err, "branch", input.Branch)
+			u.log.Info("unable to get parent sha for branch, if branch is main, it may still succeed", "err", err, "branch", ref)
 		}
 	}
-	updated, err = f(current.Data)
+	updated, err := f(current.Data)
 	if err != nil {
-		return "", fmt.Errorf("failed to apply update: %v", err)
+		return nil, fmt.Errorf("failed to apply update: %v", err)
+	}
+	update := &pendingUpdate{
+		previousSHA: currentSHA,
+		previous:    current,
+		body:        updated,
+		operation:   OperationUpdate,
+		changed:     true,
+	}
+	switch {
+	case input.RemoveFile:
+		update.operation = OperationDelete
+	case input.MoveTo != "":
+		update.operation = OperationMove
+	case isNotFoundError:
+		update.operation = OperationCreate
+	default:
+		update.changed = !isUnchanged(input.Filename, current.Data, updated)
+	}
+	return update, nil
+}
+
+// prepareChanges fetches each of the files from the ref in the repo, and
+// returns the changes to commit.
+func (u *Updater) prepareChanges(ctx context.Context, input CommitInput, repo, ref string, updates []FileUpdate) ([]client.FileChange, error) {
+	changes := []client.FileChange{}
+	for _, update := range updates {
+		if err := validateMove(update.Filename, update.MoveTo, update.CreateMissing, update.RemoveFile); err != nil {
+			return nil, err
+		}
+		current, isNotFoundError, err := u.getFile(ctx, repo, ref, update.Filename, update.CreateMissing, update.RemoveFile)
+		if err != nil {
+			return nil, err
+		}
+		change := client.FileChange{Path: update.Filename, PreviousSHA: contentSHA(current)}
+		switch {
+		case update.RemoveFile:
+			change.Action = client.FileDelete
+		case update.MoveTo != "":
+			change.Action = client.FileMove
+			change.Path = update.MoveTo
+			change.PreviousPath = update.Filename
+		case isNotFoundError:
+			change.Action = client.FileCreate
+		default:
+			change.Action = client.FileUpdate
+		}
+		if !update.RemoveFile {
+			change.Mode = update.Mode
+			change.Content, err = update.Update(current.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply update to %s: %v", update.Filename, err)
+			}
+		}
+		if change.Action == client.FileUpdate && isUnchanged(update.Filename, current.Data, change.Content) {
+			u.log.Info("update made no changes, skipping", "filename", update.Filename)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// getFile fetches the file from the branch.
+//
+// If the file does not exist, and it is to be created, an empty file is
+// returned, along with true to indicate that it was not found.
+//
+// Files that are stored in Git LFS can only be removed, as updating them would
+// replace the pointer to the file.
+func (u *Updater) getFile(ctx context.Context, repo, branch, filename string, createMissing, removeFile bool) (*scm.Content, bool, error) {
+	current, err := u.gitClient.GetFile(ctx, repo, branch, filename)
+	if err == nil {
+		if p, ok := client.ParseLFSPointer(current.Data); ok && !removeFile {
+			return nil, false, fmt.Errorf("failed to update file %s in branch %s with object %s: %w", filename, branch, p.OID, client.ErrLFSPointer)
+		}
+		return current, false, nil
 	}
+	if !client.IsNotFound(err) || !(createMissing || removeFile) {
+		u.log.Info("failed to get file from repo", "err", err)
+		return nil, false, err
+	}
+	if removeFile {
+		return nil, true, fmt.Errorf("removing a non-existing file %s in branch %s is not necessary", filename, branch)
+	}
+	if current == nil {
+		current = &scm.Content{}
+	}
+	return current, true, nil
+}
+
+// validateMove returns an error if a file that is moved is also to be created
+// or removed.
+func validateMove(filename, moveTo string, createMissing, removeFile bool) error {
+	switch {
+	case moveTo == "":
+		return nil
+	case createMissing || removeFile:
+		return fmt.Errorf("moving %s can't be combined with creating or removing it", filename)
+	case path.Clean(moveTo) == path.Clean(filename):
+		return fmt.Errorf("moving %s to the same path is not necessary", filename)
+	}
+	return nil
+}
 
-	return u.applyUpdate(ctx, input, currentSHA, updated)
+// isUnchanged returns true if the updated body is the same as the original.
+//
+// YAML files are compared semantically, ignoring formatting changes.
+func isUnchanged(filename string, original, updated []byte) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".yaml", ".yml":
+		return syaml.Equal(original, updated)
+	}
+	return bytes.Equal(original, updated)
 }
 
-func (u *Updater) applyUpdate(ctx context.Context, input CommitInput, currentSHA string, newBody []byte) (string, error) {
+func (u *Updater) applyUpdate(ctx context.Context, input CommitInput, headRepo string, update *pendingUpdate, f ContentUpdater) (*UpdateResult, error) {
 	branchRef, err := u.gitClient.GetBranchHead(ctx, input.Repo, input.Branch)
 	if err != nil {
-		return "", fmt.Errorf("failed to get branch head: %v", err)
+		return nil, fmt.Errorf("failed to get branch head: %v", err)
 	}
-	newBranchName, err := u.createBranchIfNecessary(ctx, input, branchRef)
+	target, err := u.createBranchIfNecessary(ctx, input, headRepo, branchRef)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	newBranchName := target.name
+
+	var sha string
+	for attempt := 1; ; attempt++ {
+		sha, err = u.writeFile(ctx, input, headRepo, newBranchName, update)
+		if !client.IsConflict(err) || attempt > u.conflictRetries {
+			break
+		}
+		u.log.Info("file changed concurrently, retrying", "filename", input.Filename, "branch", newBranchName, "attempt", attempt)
+		u.metrics.ConflictRetried(input.Repo)
+		if err := u.backoff(ctx, attempt); err != nil {
+			return nil, err
+		}
+		update, err = u.prepareUpdate(ctx, input, headRepo, newBranchName, f)
+		if err != nil {
+			return nil, err
+		}
+		if !update.changed {
+			u.log.Info("update made no changes after retrying, skipping", "filename", input.Filename, "branch", newBranchName)
+			u.metrics.UpdateSkipped(input.Repo)
+			res := update.result(input)
+			if res.Branch, err = u.skippedBranch(ctx, input, headRepo, target); err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := update.result(input)
+	res.Branch = newBranchName
+	res.SourceSHA = target.sha
+	res.CommitSHA = sha
+	if sha == "" {
+		// Only CommitFiles returns the commit, this may be a later commit to
+		// the branch.
+		res.CommitSHA, err = u.gitClient.GetBranchHead(ctx, headRepo, newBranchName)
+		if err != nil {
+			u.log.Info("unable to get the head of the branch after committing", "err", err, "branch", newBranchName)
+		}
 	}
+	return res, nil
+}
 
+// writeFile writes the update to the file in the branch, and returns the SHA
+// of the commit if the client returns it.
+func (u *Updater) writeFile(ctx context.Context, input CommitInput, repo, branch string, update *pendingUpdate) (string, error) {
+	currentSHA, newBody := update.previousSHA, update.body
+	if input.Mode != "" && !input.RemoveFile {
+		// Only CommitFiles can set the mode of a file.
+		change := client.FileChange{Action: client.FileUpdate, Path: input.Filename, Content: newBody, Mode: input.Mode, PreviousSHA: currentSHA}
+		switch {
+		case input.MoveTo != "":
+			change.Action = client.FileMove
+			change.Path = input.MoveTo
+			change.PreviousPath = input.Filename
+		case update.operation == OperationCreate:
+			change.Action = client.FileCreate
+			change.PreviousSHA = ""
+		}
+		sha, err := u.gitClient.CommitFiles(ctx, repo, branch, input.CommitMessage, input.Signature, []client.FileChange{change})
+		if err != nil {
+			return "", fmt.Errorf("failed to commit file: %w", err)
+		}
+		u.log.Info("committed file", "filename", input.Filename, "mode", input.Mode, "sha", sha)
+		return sha, nil
+	}
+	if input.MoveTo != "" {
+		err := u.gitClient.MoveFile(ctx, repo, branch, input.Filename, input.MoveTo, input.CommitMessage, currentSHA, input.Signature, newBody)
+		if err != nil {
+			return "", fmt.Errorf("failed to move file: %w", err)
+		}
+		u.log.Info("moved file", "filename", input.Filename, "newFilename", input.MoveTo)
+		return "", nil
+	}
 	if input.RemoveFile {
-		err = u.gitClient.DeleteFile(ctx, input.Repo, newBranchName, input.Filename, input.CommitMessage, currentSHA, input.Signature, newBody)
+		err := u.gitClient.DeleteFile(ctx, repo, branch, input.Filename, input.CommitMessage, currentSHA, input.Signature, newBody)
 		if err != nil {
 			return "", fmt.Errorf("failed to delete file: %w", err)
 		}
 		u.log.Info("deleted file", "filename", input.Filename)
-		return newBranchName, nil
+		return "", nil
 	}
 
-	err = u.gitClient.UpdateFile(ctx, input.Repo, newBranchName, input.Filename, input.CommitMessage, currentSHA, input.Signature, newBody)
+	err := u.gitClient.UpdateFile(ctx, repo, branch, input.Filename, input.CommitMessage, currentSHA, input.Signature, newBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to update file: %w", err)
 	}
 	u.log.Info("updated file", "filename", input.Filename)
-	return newBranchName, nil
+	return "", nil
+}
+
+// backoff waits before retrying a conflicting change, doubling the delay for
+// each attempt up to maxConflictBackoff, or until the context is done.
+func (u *Updater) backoff(ctx context.Context, attempt int) error {
+	d := u.conflictBackoff << (attempt - 1)
+	if d > maxConflictBackoff || d <= 0 {
+		d = maxConflictBackoff
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// baseRef returns the repo and ref to apply updates to, this is the existing
+// branch for the change in the headRepo if there is one, or the source branch.
+//
+// The existing branch is only used while there is an open PullRequest from
+// it, otherwise it's deleted, so that it's created again from the source
+// branch.
+func (u *Updater) baseRef(ctx context.Context, input CommitInput, headRepo string) (string, string, error) {
+	if input.ChangeID == "" || input.DisablePRCreation {
+		return input.Repo, input.Branch, nil
+	}
+	branch := changeBranchName(input)
+	_, err := u.gitClient.GetBranchHead(ctx, headRepo, branch)
+	if client.IsNotFound(err) {
+		return input.Repo, input.Branch, nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get branch head: %w", err)
+	}
+	pr, err := u.findOpenPR(ctx, input.Repo, headRepo, branch, input.Branch)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if pr == nil {
+		u.log.Info("deleting branch for change with no open PullRequest", "branch", branch, "changeID", input.ChangeID)
+		if err := u.gitClient.DeleteBranch(ctx, headRepo, branch); err != nil && !client.IsNotFound(err) {
+			return "", "", fmt.Errorf("failed to delete branch %s: %w", branch, err)
+		}
+		return input.Repo, input.Branch, nil
+	}
+	u.log.Info("found existing branch for change", "branch", branch, "changeID", input.ChangeID, "number", pr.Number)
+	return headRepo, branch, nil
+}
+
+func changeBranchName(input CommitInput) string {
+	return input.BranchGenerateName + input.ChangeID
+}
 
+// targetBranch is the branch that an update is committed to.
+type targetBranch struct {
+	name    string
+	sha     string // the head of the branch before the update
+	created bool   // whether the branch was created for the update
 }
 
-func (u *Updater) createBranchIfNecessary(ctx context.Context, input CommitInput, sourceRef string) (string, error) {
+func (u *Updater) createBranchIfNecessary(ctx context.Context, input CommitInput, repo, sourceRef string) (*targetBranch, error) {
 	if input.DisablePRCreation {
 		u.log.Info("DisablePRCreation set, committing directly to source branch", "branch", input.Branch)
-		return input.Branch, nil
+		return &targetBranch{name: input.Branch, sha: sourceRef}, nil
 	}
 
-	newBranchName := u.nameGenerator.PrefixedName(input.BranchGenerateName)
+	var newBranchName string
+	if input.ChangeID != "" {
+		newBranchName = changeBranchName(input)
+		sha, err := u.gitClient.GetBranchHead(ctx, repo, newBranchName)
+		if err == nil {
+			u.log.Info("reusing existing branch for change", "branch", newBranchName, "changeID", input.ChangeID)
+			return &targetBranch{name: newBranchName, sha: sha}, nil
+		}
+		if !client.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get branch head: %w", err)
+		}
+	} else {
+		newBranchName = u.nameGenerator.PrefixedName(input.BranchGenerateName)
+	}
 	u.log.Info("generating new branch", "name", newBranchName)
-	err := u.gitClient.CreateBranch(ctx, input.Repo, newBranchName, sourceRef)
+	synced := true
+	if repo != input.Repo {
+		var err error
+		if synced, err = u.syncFork(ctx, input, repo); err != nil {
+			return nil, err
+		}
+	}
+	err := u.gitClient.CreateBranch(ctx, repo, newBranchName, sourceRef)
+	if err != nil && !synced {
+		return nil, fmt.Errorf("failed to create branch in fork %s, which can't be synced, and may not have commit %s: %w", repo, sourceRef, err)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create branch: %w", err)
+		return nil, fmt.Errorf("failed to create branch: %w", err)
 	}
-	u.log.Info("created branch", "branch", newBranchName, "ref", sourceRef)
-	return newBranchName, nil
+	u.log.Info("created branch", "repo", repo, "branch", newBranchName, "ref", sourceRef)
+	u.metrics.BranchCreated(input.Repo)
+	return &targetBranch{name: newBranchName, sha: sourceRef, created: true}, nil
 }
 
-func (u *Updater) CreatePR(ctx context.Context, input PullRequestInput) (*scm.PullRequest, error) {
-	pr, err := u.gitClient.CreatePullRequest(ctx, input.Repo, &scm.PullRequestInput{
+// skippedBranch returns the branch name to return for an update that made no
+// changes after retrying a conflict, the same as for updates that make no
+// changes in the first place.
+//
+// The branch is deleted if it was created for the update, and nothing was
+// committed to it. If the head of the branch has moved, the conflict may have
+// been caused by the update being committed, e.g. if the response was lost,
+// so the branch is kept.
+func (u *Updater) skippedBranch(ctx context.Context, input CommitInput, repo string, target *targetBranch) (string, error) {
+	if input.DisablePRCreation {
+		return "", nil
+	}
+	if !target.created {
+		return target.name, nil
+	}
+	head, err := u.gitClient.GetBranchHead(ctx, repo, target.name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch head: %w", err)
+	}
+	if head != target.sha {
+		u.log.Info("keeping branch with commits", "repo", repo, "branch", target.name, "sha", head)
+		return target.name, nil
+	}
+	if err := u.gitClient.DeleteBranch(ctx, repo, target.name); err != nil {
+		return "", fmt.Errorf("failed to delete branch %s with no changes: %w", target.name, err)
+	}
+	u.log.Info("deleted branch with no changes", "repo", repo, "branch", target.name)
+	return "", nil
+}
+
+// CreatePR creates a PullRequest from the NewBranch to the SourceBranch.
+//
+// If forking, the PullRequest is created from the NewBranch in the fork, see
+// Fork.
+//
+// If the PullRequest is created, but the Options can't be applied, the
+// PullRequest is returned along with the error.
+func (u *Updater) CreatePR(ctx context.Context, input PullRequestInput) (pr *scm.PullRequest, err error) {
+	ctx, span := u.startSpan(ctx, "CreatePR", input.Repo,
+		tracing.BranchKey.String(input.SourceBranch), newBranchKey.String(input.NewBranch))
+	defer func() { endPullRequestSpan(span, pr, err) }()
+	inp := &scm.PullRequestInput{
 		Title:  input.Title,
 		Body:   input.Body,
 		Source: input.NewBranch,
 		Target: input.SourceBranch,
+	}
+	opts := input.Options
+	if u.fork {
+		if opts.SourceRepo, err = u.headRepo(ctx, input.Repo); err != nil {
+			return nil, err
+		}
+	}
+	if opts.IsZero() {
+		pr, err := u.gitClient.CreatePullRequest(ctx, input.Repo, inp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a pull request: %w", err)
+		}
+		u.log.Info("created PullRequest", "number", pr.Number)
+		u.metrics.PullRequestCreated(input.Repo)
+		return pr, nil
+	}
+	pr, err = u.gitClient.CreatePullRequestWithOptions(ctx, input.Repo, inp, opts)
+	if pr != nil {
+		u.log.Info("created PullRequest", "number", pr.Number)
+		u.metrics.PullRequestCreated(input.Repo)
+	}
+	if err != nil {
+		return pr, fmt.Errorf("failed to create a pull request: %w", err)
+	}
+	return pr, nil
+}
+
+// CreateOrUpdatePR updates the title and body of the open PullRequest from
+// the NewBranch to the SourceBranch, or creates one if there is none.
+//
+// This is intended for updates with a ChangeID, where each update for the
+// change is committed to the same branch.
+func (u *Updater) CreateOrUpdatePR(ctx context.Context, input PullRequestInput) (pr *scm.PullRequest, err error) {
+	ctx, span := u.startSpan(ctx, "CreateOrUpdatePR", input.Repo,
+		tracing.BranchKey.String(input.SourceBranch), newBranchKey.String(input.NewBranch))
+	defer func() { endPullRequestSpan(span, pr, err) }()
+	headRepo, err := u.headRepo(ctx, input.Repo)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := u.findOpenPR(ctx, input.Repo, headRepo, input.NewBranch, input.SourceBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if existing == nil {
+		return u.CreatePR(ctx, input)
+	}
+	if existing.Title == input.Title && existing.Body == input.Body {
+		u.log.Info("found existing PullRequest", "number", existing.Number)
+		return existing, nil
+	}
+	pr, err = u.gitClient.UpdatePullRequest(ctx, input.Repo, existing.Number, &scm.PullRequestInput{
+		Title: input.Title,
+		Body:  input.Body,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a pull request: %w", err)
+		return nil, fmt.Errorf("failed to update pull request %d: %w", existing.Number, err)
 	}
-	u.log.Info("created PullRequest", "number", pr.Number)
+	u.log.Info("updated PullRequest", "number", pr.Number)
 	return pr, nil
 }
+
+// isFrom returns true if the source branch of the PullRequest is in the repo,
+// or if the repo of the source branch is not known.
+func isFrom(pr *scm.PullRequest, repo string) bool {
+	return pr.Fork == "" || strings.EqualFold(pr.Fork, repo)
+}
+
+// findOpenPR returns the open PullRequest from the source branch in the
+// headRepo to the target branch, the repo of the source branch is only
+// compared if it's known.
+func (u *Updater) findOpenPR(ctx context.Context, repo, headRepo, source, target string) (*scm.PullRequest, error) {
+	for page := 1; ; page++ {
+		prs, err := u.gitClient.ListPullRequests(ctx, repo, scm.PullRequestListOptions{Page: page, Size: pullRequestPageSize, Open: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			if !pr.Closed && pr.Source == source && pr.Target == target && isFrom(pr, headRepo) {
+				return pr, nil
+			}
+		}
+		if len(prs) < pullRequestPageSize {
+			return nil, nil
+		}
+	}
+}
@@ -3,11 +3,16 @@ package updater
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/ocraviotto/go-scm/scm"
 	"github.com/ocraviotto/pkg/client"
 	"github.com/ocraviotto/pkg/client/mock"
+	"github.com/ocraviotto/pkg/test"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
@@ -203,6 +208,694 @@ func TestApplyUpdateToFileWithBranchCreationFailure(t *testing.T) {
 	m.AssertNoBranchesCreated()
 }
 
+func TestApplyUpdatesToFiles(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	otherFilePath := "environments/staging/services/service-a/test.yaml"
+	newFilePath := "environments/prod/services/service-a/test.yaml"
+	removedFilePath := "environments/dev/services/service-a/test.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddFileContents(testGitHubRepo, otherFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddFileContents(testGitHubRepo, removedFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), []FileUpdate{
+		{Filename: testFilePath, Update: UpdateYAML("test.image", "new-image")},
+		{Filename: otherFilePath, Update: UpdateYAML("test.image", "new-image")},
+		{Filename: newFilePath, Update: ReplaceContents([]byte("new content")), CreateMissing: true},
+		{Filename: removedFilePath, RemoveFile: true},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "test-branch-a" {
+		t.Fatalf("newly created branch, got %#v, want %#v", branch, "test-branch-a")
+	}
+	for _, path := range []string{testFilePath, otherFilePath} {
+		updated := m.GetUpdatedContents(testGitHubRepo, path, branch)
+		if s := string(updated); s != "test:\n  image: new-image\n" {
+			t.Fatalf("update failed, got %#v, want %#v", s, "test:\n  image: new-image\n")
+		}
+	}
+	if s := string(m.GetUpdatedContents(testGitHubRepo, newFilePath, branch)); s != "new content" {
+		t.Fatalf("create failed, got %#v, want %#v", s, "new content")
+	}
+	m.AssertFileDeleted(testGitHubRepo, removedFilePath, branch)
+	m.AssertCommitCount(1)
+	m.AssertBranchCreated(testGitHubRepo, branch, testSHA)
+}
+
+func TestApplyUpdatesToFilesWithMissingFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	_, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), []FileUpdate{
+		{Filename: testFilePath, Update: UpdateYAML("test.image", "new-image")},
+		{Filename: "unknown.yaml", Update: UpdateYAML("test.image", "new-image")},
+	})
+
+	if !client.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error", err)
+	}
+	m.AssertNoInteractions()
+}
+
+func TestApplyUpdateToFileRetriesConflicts(t *testing.T) {
+	conflictTests := []struct {
+		name        string
+		onlyBlobIDs bool
+	}{
+		{"file sha", false},
+		{"blob ID", true},
+	}
+
+	for _, tt := range conflictTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+			m := mock.New(rt)
+			m.OnlyBlobIDs = tt.onlyBlobIDs
+			m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+			updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond))
+			input := makeCommitInput()
+			input.DisablePRCreation = true
+			update := UpdateYAML("test.image", "new-image")
+			calls := 0
+
+			branch, err := updater.ApplyUpdateToFile(context.Background(), input, func(b []byte) ([]byte, error) {
+				calls++
+				if calls == 1 {
+					// simulate another commit to the file after it was read.
+					m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n  replicas: 2\n"))
+				}
+				return update(b)
+			})
+
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if branch != testBranch {
+				rt.Fatalf("got %#v, want %#v", branch, testBranch)
+			}
+			if calls != 2 {
+				rt.Fatalf("update was called %d times, want 2", calls)
+			}
+			want := "test:\n  image: new-image\n  replicas: 2\n"
+			if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, testBranch)); s != want {
+				rt.Fatalf("update failed, got %#v, want %#v", s, want)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateToFileWithRepeatedConflicts(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond))
+	input := makeCommitInput()
+	input.DisablePRCreation = true
+	calls := 0
+
+	_, err := updater.ApplyUpdateToFile(context.Background(), input, func(b []byte) ([]byte, error) {
+		calls++
+		m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte(fmt.Sprintf("test:\n  replicas: %d\n", calls)))
+		return []byte("new content"), nil
+	})
+
+	if !client.IsConflict(err) {
+		t.Fatalf("got %v, want a conflict error", err)
+	}
+	if calls != 3 {
+		t.Fatalf("update was called %d times, want 3", calls)
+	}
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, testBranch)); s != "" {
+		t.Fatalf("update failed, got %#v, want %#v", s, "")
+	}
+}
+
+func TestApplyUpdateWithNoChanges(t *testing.T) {
+	noChangeTests := []struct {
+		name    string
+		updater ContentUpdater
+	}{
+		{"identical content", ReplaceContents([]byte("test:\n  image: old-image\n"))},
+		{"identical yaml value", UpdateYAML("test.image", "old-image")},
+		{"reformatted yaml", ReplaceContents([]byte("test: {image: old-image}\n"))},
+	}
+
+	for _, tt := range noChangeTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			m := mock.New(rt)
+			m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+			updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+			res, err := updater.ApplyUpdate(context.Background(), makeCommitInput(), tt.updater)
+
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if res.Changed || res.Branch != "" {
+				rt.Fatalf("got %#v, want no changes", res)
+			}
+			m.AssertNoInteractions()
+		})
+	}
+}
+
+func TestApplyUpdateToFileWithNoChanges(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.DisablePRCreation = true
+
+	branch, err := updater.ApplyUpdateToFile(context.Background(), input, UpdateYAML("test.image", "old-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "" {
+		t.Fatalf("got branch %#v, want no branch", branch)
+	}
+	m.AssertNoInteractions()
+}
+
+func TestApplyUpdateWithNoChangesAfterConflict(t *testing.T) {
+	conflictTests := []struct {
+		name              string
+		disablePRCreation bool
+		branch            string
+	}{
+		{"new branch", false, "test-branch-a"},
+		{"committing directly", true, testBranch},
+	}
+
+	for _, tt := range conflictTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			m := mock.New(rt)
+			m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+			updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond))
+			input := makeCommitInput()
+			input.DisablePRCreation = tt.disablePRCreation
+			update := UpdateYAML("test.image", "new-image")
+			calls := 0
+
+			res, err := updater.ApplyUpdate(context.Background(), input, func(b []byte) ([]byte, error) {
+				calls++
+				if calls == 1 {
+					// simulate the same change being committed after the file was read.
+					m.AddFileContents(testGitHubRepo, testFilePath, tt.branch, []byte("test:\n  image: new-image\n"))
+				}
+				return update(b)
+			})
+
+			if err != nil {
+				rt.Fatal(err)
+			}
+			if res.Changed || res.Branch != "" {
+				rt.Fatalf("got %#v, want no changes", res)
+			}
+			if tt.disablePRCreation {
+				m.RefuteBranchDeleted(testGitHubRepo, tt.branch)
+			} else {
+				m.AssertBranchDeleted(testGitHubRepo, tt.branch)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateWithNoChangesAfterCommittingToBranch(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), lostResponseClient{m}, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond))
+
+	res, err := updater.ApplyUpdate(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Branch != "test-branch-a" {
+		t.Fatalf("got branch %#v, want %#v", res.Branch, "test-branch-a")
+	}
+	m.RefuteBranchDeleted(testGitHubRepo, res.Branch)
+	want := "test:\n  image: new-image\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, res.Branch)); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+}
+
+func TestApplyUpdatesToFilesRetriesConflicts(t *testing.T) {
+	m := mock.New(t)
+	m.OnlyBlobIDs = true
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}), ConflictRetries(2, time.Millisecond))
+	input := makeCommitInput()
+	input.DisablePRCreation = true
+	update := UpdateYAML("test.image", "new-image")
+	calls := 0
+	updates := []FileUpdate{
+		{Filename: testFilePath, Update: func(b []byte) ([]byte, error) {
+			calls++
+			if calls == 1 {
+				// simulate another commit to the file after it was read.
+				m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n  replicas: 2\n"))
+			}
+			return update(b)
+		}},
+	}
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), input, updates)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != testBranch {
+		t.Fatalf("got %#v, want %#v", branch, testBranch)
+	}
+	if calls != 2 {
+		t.Fatalf("update was called %d times, want 2", calls)
+	}
+	want := "test:\n  image: new-image\n  replicas: 2\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, testBranch)); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+}
+
+func TestApplyUpdatesToFilesWithNoChanges(t *testing.T) {
+	otherFilePath := "environments/staging/services/service-a/test.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddFileContents(testGitHubRepo, otherFilePath, testBranch, []byte("test:\n  image: new-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	updates := []FileUpdate{
+		{Filename: testFilePath, Update: UpdateYAML("test.image", "new-image")},
+		{Filename: otherFilePath, Update: UpdateYAML("test.image", "new-image")},
+	}
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), updates[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "" {
+		t.Fatalf("got branch %#v, want no branch", branch)
+	}
+	m.AssertNoInteractions()
+
+	branch, err = updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, branch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("update failed, got %#v, want %#v", s, "test:\n  image: new-image\n")
+	}
+	if b := m.GetUpdatedContents(testGitHubRepo, otherFilePath, branch); b != nil {
+		t.Fatalf("unchanged file was committed: %#v", string(b))
+	}
+}
+
+func TestApplyUpdateResult(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	res, err := updater.ApplyUpdate(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &UpdateResult{
+		Branch:       "test-branch-a",
+		SourceBranch: testBranch,
+		SourceSHA:    testSHA,
+		PreviousSHA:  "8df714b598f7d681ba48165aa1a5a5e406fa0bfa",
+		NewSHA:       "64d8acc85f113e532575ad2dcbbcf902b4eeb875",
+		Changed:      true,
+		Operation:    OperationUpdate,
+		Diff:         "--- a/" + testFilePath + "\n+++ b/" + testFilePath + "\n@@ -1,2 +1,2 @@\n test:\n-  image: old-image\n+  image: new-image\n",
+	}
+	if res.CommitSHA == "" || res.CommitSHA == testSHA {
+		t.Fatalf("got commit SHA %#v, want the new head of the branch", res.CommitSHA)
+	}
+	res.CommitSHA = ""
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("incorrect result:\n%s", diff)
+	}
+}
+
+func TestApplyUpdateResultWithLaterCommit(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), laterCommitClient{m}, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.Mode = client.ModeRegular
+
+	res, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.CommitSHA == "" || res.CommitSHA == laterCommitSHA {
+		t.Fatalf("got commit SHA %#v, want the commit created for the update", res.CommitSHA)
+	}
+}
+
+func TestApplyUpdateResultCreatingFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.CreateMissing = true
+
+	res, err := updater.ApplyUpdate(context.Background(), input, ReplaceContents([]byte("new content\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Operation != OperationCreate || res.PreviousSHA != "" || !res.Changed {
+		t.Fatalf("incorrect result for created file: %#v", res)
+	}
+	if res.NewSHA != "b66ba06d315d46280bb09d54614cc52d1677809f" {
+		t.Fatalf("got new SHA %#v, want %#v", res.NewSHA, "b66ba06d315d46280bb09d54614cc52d1677809f")
+	}
+	wantDiff := "--- /dev/null\n+++ b/" + testFilePath + "\n@@ -0,0 +1 @@\n+new content\n"
+	if res.Diff != wantDiff {
+		t.Fatalf("got diff %#v, want %#v", res.Diff, wantDiff)
+	}
+}
+
+func TestApplyUpdateMovingFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	newFilePath := "environments/staging/services/service-a/test.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.MoveTo = newFilePath
+
+	res, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertFileMoved(testGitHubRepo, testFilePath, newFilePath, res.Branch)
+	m.AssertCommitCount(1)
+	if s := string(m.GetUpdatedContents(testGitHubRepo, newFilePath, res.Branch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("move failed, got %#v, want %#v", s, "test:\n  image: new-image\n")
+	}
+	wantDiff := "--- a/" + testFilePath + "\n+++ b/" + newFilePath + "\n@@ -1,2 +1,2 @@\n test:\n-  image: old-image\n+  image: new-image\n"
+	if res.Operation != OperationMove || !res.Changed || res.Diff != wantDiff {
+		t.Fatalf("incorrect result for moved file: %#v", res)
+	}
+}
+
+func TestApplyUpdatesToFilesMovingFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	newFilePath := "environments/staging/services/service-a/test.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), []FileUpdate{
+		{Filename: testFilePath, MoveTo: newFilePath, Update: func(b []byte) ([]byte, error) { return b, nil }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertFileMoved(testGitHubRepo, testFilePath, newFilePath, branch)
+	m.AssertCommitCount(1)
+}
+
+func TestApplyUpdateMovingFileWithInvalidInput(t *testing.T) {
+	moveTests := []struct {
+		name   string
+		modify func(*CommitInput)
+		want   string
+	}{
+		{"creating", func(i *CommitInput) { i.CreateMissing = true }, "can't be combined with creating or removing it"},
+		{"removing", func(i *CommitInput) { i.RemoveFile = true }, "can't be combined with creating or removing it"},
+		{"same path", func(i *CommitInput) { i.MoveTo = "./" + testFilePath }, "to the same path is not necessary"},
+	}
+
+	for _, tt := range moveTests {
+		t.Run(tt.name, func(rt *testing.T) {
+			m := mock.New(rt)
+			m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+			updater := New(zap.New(), m)
+			input := makeCommitInput()
+			input.MoveTo = "environments/staging/test.yaml"
+			tt.modify(&input)
+
+			_, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+
+			if !test.MatchError(rt, tt.want, err) {
+				rt.Fatalf("failed to match error: %s", err)
+			}
+			m.AssertNoInteractions()
+		})
+	}
+}
+
+func TestApplyUpdateWithMode(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.Mode = client.ModeExecutable
+
+	res, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertCommitCount(1)
+	if head, _ := m.GetBranchHead(context.Background(), testGitHubRepo, res.Branch); res.CommitSHA != head {
+		t.Fatalf("got commit SHA %#v, want %#v", res.CommitSHA, head)
+	}
+	if mode := m.GetUpdatedMode(testGitHubRepo, testFilePath, res.Branch); mode != client.ModeExecutable {
+		t.Fatalf("got mode %q, want %q", mode, client.ModeExecutable)
+	}
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, res.Branch)); s != "test:\n  image: new-image\n" {
+		t.Fatalf("update failed, got %#v", s)
+	}
+}
+
+func TestApplyUpdatesToFilesWithMode(t *testing.T) {
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+
+	branch, err := updater.ApplyUpdatesToFiles(context.Background(), makeCommitInput(), []FileUpdate{
+		{Filename: "scripts/run.sh", CreateMissing: true, Mode: client.ModeExecutable, Update: func([]byte) ([]byte, error) { return []byte("#!/bin/sh\n"), nil }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mode := m.GetUpdatedMode(testGitHubRepo, "scripts/run.sh", branch); mode != client.ModeExecutable {
+		t.Fatalf("got mode %q, want %q", mode, client.ModeExecutable)
+	}
+}
+
+func TestApplyUpdateToLFSPointer(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"))
+	updater := New(zap.New(), m)
+
+	_, err := updater.ApplyUpdate(context.Background(), makeCommitInput(), UpdateYAML("test.image", "new-image"))
+
+	if !errors.Is(err, client.ErrLFSPointer) {
+		t.Fatalf("got %v, want %v", err, client.ErrLFSPointer)
+	}
+	m.AssertNoInteractions()
+}
+
+func TestApplyUpdateWithChangeID(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	changeBranch := "test-branch-bump-service-a"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.ChangeID = "bump-service-a"
+
+	res, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Branch != changeBranch {
+		t.Fatalf("got branch %#v, want %#v", res.Branch, changeBranch)
+	}
+	m.AssertBranchCreated(testGitHubRepo, changeBranch, testSHA)
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 1, Source: changeBranch, Target: testBranch})
+
+	// The next update for the change is applied to the file in the change branch.
+	m.AddFileContents(testGitHubRepo, testFilePath, changeBranch, []byte("test:\n  image: new-image\n"))
+	changeSHA := res.CommitSHA
+	res, err = updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "newer-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Branch != changeBranch {
+		t.Fatalf("got branch %#v, want %#v", res.Branch, changeBranch)
+	}
+	if res.SourceSHA != changeSHA {
+		t.Fatalf("got source SHA %#v, want the head of the change branch %#v", res.SourceSHA, changeSHA)
+	}
+	want := "test:\n  image: newer-image\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, changeBranch)); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+
+	// Repeating the update makes no changes, but returns the change branch.
+	m.AddFileContents(testGitHubRepo, testFilePath, changeBranch, []byte(want))
+	res, err = updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "newer-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed || res.Branch != changeBranch {
+		t.Fatalf("got %#v, want no changes in branch %#v", res, changeBranch)
+	}
+}
+
+func TestApplyUpdateWithChangeIDAndClosedPullRequest(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	changeBranch := "test-branch-bump-service-a"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, testSHA)
+	m.AddBranchHead(testGitHubRepo, changeBranch, "4d7a214614ab2935c943f9e0ff69d22eadbb8f32")
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{Number: 1, Source: changeBranch, Target: testBranch, Merged: true, Closed: true})
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.ChangeID = "bump-service-a"
+
+	res, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Changed || res.Branch != changeBranch || res.SourceSHA != testSHA {
+		t.Fatalf("got %#v, want a change in a new branch %#v", res, changeBranch)
+	}
+	m.AssertBranchDeleted(testGitHubRepo, changeBranch)
+	m.AssertBranchCreated(testGitHubRepo, changeBranch, testSHA)
+}
+
+func TestApplyUpdateWithChangeIDHandlingErrors(t *testing.T) {
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, testBranch, []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, testBranch, "980a0d5f19a64b4b30a87d4206aade58726b60e3")
+	m.GetBranchHeadErr = errors.New("mock error")
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makeCommitInput()
+	input.ChangeID = "bump-service-a"
+
+	_, err := updater.ApplyUpdate(context.Background(), input, UpdateYAML("test.image", "new-image"))
+
+	if !test.MatchError(t, "failed to get branch head: mock error", err) {
+		t.Fatalf("failed to match error: %s", err)
+	}
+	m.AssertNoBranchesCreated()
+}
+
+func TestCreatePullRequestWithOptions(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makePullRequestInput()
+	input.Options = client.PullRequestOptions{
+		Labels:    []string{"dependencies"},
+		Reviewers: []string{"octocat"},
+		Draft:     true,
+	}
+
+	pr, err := updater.CreatePR(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertPullRequestOptions(testGitHubRepo, pr.Number, input.Options)
+	if diff := cmp.Diff([]scm.Label{{Name: "dependencies"}}, pr.Labels); diff != "" {
+		t.Fatalf("failed to label the PullRequest:\n%s", diff)
+	}
+}
+
+func TestCreateOrUpdatePullRequest(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makePullRequestInput()
+
+	created, err := updater.CreateOrUpdatePR(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input.Title = "This is an updated test PR"
+	updated, err := updater.CreateOrUpdatePR(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Number != created.Number {
+		t.Fatalf("got PR %d, want the existing PR %d", updated.Number, created.Number)
+	}
+	m.AssertPullRequestUpdated(testGitHubRepo, created.Number, &scm.PullRequestInput{
+		Title: input.Title,
+		Body:  input.Body,
+	})
+	if prs, _ := m.ListPullRequests(context.Background(), testGitHubRepo, scm.PullRequestListOptions{}); len(prs) != 1 {
+		t.Fatalf("got %d PullRequests, want 1", len(prs))
+	}
+}
+
+func TestCreateOrUpdatePullRequestIgnoresClosed(t *testing.T) {
+	m := mock.New(t)
+	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
+	input := makePullRequestInput()
+	m.AddPullRequest(testGitHubRepo, &scm.PullRequest{
+		Number: 1,
+		Source: input.NewBranch,
+		Target: input.SourceBranch,
+		Closed: true,
+	})
+
+	pr, err := updater.CreateOrUpdatePR(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pr.Number != 2 {
+		t.Fatalf("got PR %d, want a new PR", pr.Number)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title:  input.Title,
+		Body:   input.Body,
+		Source: input.NewBranch,
+		Target: input.SourceBranch,
+	})
+}
+
 func TestCreatePullRequest(t *testing.T) {
 	m := mock.New(t)
 	updater := New(zap.New(), m, NameGenerator(stubNameGenerator{"a"}))
@@ -240,6 +933,37 @@ func TestCreatePullRequestHandlingErrors(t *testing.T) {
 	m.AssertNoPullRequestsCreated()
 }
 
+const laterCommitSHA = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32"
+
+// laterCommitClient simulates another commit to the branch right after each
+// commit with CommitFiles.
+type laterCommitClient struct {
+	*mock.MockClient
+}
+
+func (c laterCommitClient) CommitFiles(ctx context.Context, repo, branch, message string, signature scm.Signature, changes []client.FileChange) (string, error) {
+	sha, err := c.MockClient.CommitFiles(ctx, repo, branch, message, signature, changes)
+	c.AddBranchHead(repo, branch, laterCommitSHA)
+	return sha, err
+}
+
+// lostResponseClient simulates the response to each UpdateFile being lost
+// after the file was updated, and the retried request failing with a conflict.
+type lostResponseClient struct {
+	*mock.MockClient
+}
+
+func (c lostResponseClient) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, signature scm.Signature, content []byte) error {
+	if err := c.MockClient.UpdateFile(ctx, repo, branch, path, message, previousSHA, signature, content); err != nil {
+		return err
+	}
+	c.AddFileContents(repo, path, branch, content)
+	return client.SCMError{
+		Msg:    fmt.Sprintf("failed to update file %s in repo %s branch %s", path, repo, branch),
+		Status: http.StatusConflict,
+	}
+}
+
 type stubNameGenerator struct {
 	name string
 }